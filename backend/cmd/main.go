@@ -1,17 +1,24 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"net/http"
+	"os"
+	"os/signal"
+	"sykell-backend/internal/blobstore"
 	"sykell-backend/internal/crawl"
 	"sykell-backend/internal/config"	
+	"sykell-backend/internal/database"
 	"sykell-backend/internal/logger"
 	sykellMiddleware "sykell-backend/internal/middleware"
+	"sykell-backend/internal/quota"
 	"sykell-backend/internal/temporal"
 	"sykell-backend/internal/url"
 	"sykell-backend/internal/user"
+	"syscall"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"go.uber.org/zap"
@@ -33,17 +40,16 @@ func main() {
 
 	logger.Info("Starting Sykell Backend", zap.String("version", "1.0.0"))
 
+	// Stopped on SIGINT or SIGTERM, crawls run by the local executor stop with it
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Connect to database
-	db, err := sql.Open("mysql", cfg.DatabaseURL)
+	db, err := database.Open(cfg)
 	if err != nil {
 		logger.Fatal("Failed to connect to database", zap.Error(err))
 	}
 	defer db.Close()
-
-	// Test database connection
-	if err := db.Ping(); err != nil {
-		logger.Fatal("Failed to ping database", zap.Error(err))
-	}
 	logger.Info("Database connected successfully")
 
 	// Initialize services
@@ -53,18 +59,40 @@ func main() {
 
 	// Initialize Temporal client with better connection settings
 	temporalService := temporal.NewService(cfg)
-	temporalService.Setup()
+	// The API starts without Temporal, crawl actions answer 503 until it can be reached. Crawls run by the
+	// local executor do not need it
+	if cfg.CrawlExecutor == crawl.ExecutorLocal {
+		logger.Info("Crawls run in the API process by the local executor")
+	} else if err := temporalService.Setup(); err != nil {
+		logger.Warn("Temporal is not reachable yet", zap.Error(err))
+	}
 	
 	// Ensure proper cleanup on shutdown	
 	defer temporalService.Close()	
 
+	// Quotas of the users' plans, enforced when adding URLs and starting crawls
+	quotaService := quota.NewService(quota.NewRepo(db))
+	quotaHandler := quota.NewHandler(quotaService)
+
 	// Initialize Temporal service
 	urlRepo := url.NewRepo(db)
-	urlService := url.NewService(urlRepo, cfg)
+	urlService := url.NewService(urlRepo, cfg, quotaService)
 	urlHandler := url.NewHandler(urlService)
 
+	// Blob store holding the raw HTML snapshots archived by the worker
+	snapshots, err := blobstore.New(cfg)
+	if err != nil {
+		logger.Fatal("Failed to open blob store", zap.Error(err))
+	}
+
 	crawlRepo := crawl.NewRepo(db)
-	crawlService := crawl.NewCrawlService(crawlRepo, cfg, temporalService)
+	crawlActivities := crawl.NewActivities(cfg, db, snapshots)
+	crawlService := crawl.NewCrawlService(ctx, crawlRepo, cfg, temporalService, snapshots, quotaService, crawlActivities)
+	if failed, err := crawlService.FailOrphanedCrawls(ctx); err != nil {
+		logger.Error("Failed to fail the crawls of the previous run", zap.Error(err))
+	} else if failed > 0 {
+		logger.Warn("Crawls of the previous run were lost", zap.Int64("crawls", failed))
+	}
 	crawlHandler := crawl.NewCrawlHandler(crawlService)
 	
 	
@@ -111,17 +139,34 @@ func main() {
 	protected := api.Group("", sykellMiddleware.JWTMiddleware([]byte(cfg.JWTSecret), false))
 	// Profile route
 	protected.GET("/auth/me", userHandler.GetProfile)
+	protected.GET("/usage", quotaHandler.GetUsage)
 	
 	// Url routes
 	protected.GET("/urls", urlHandler.ListURLs)
+	protected.GET("/urls/duplicates", urlHandler.FindDuplicates)
 	protected.POST("/urls", urlHandler.AddURL)
 	protected.DELETE("/urls/:id", urlHandler.RemoveURL)
+	protected.GET("/urls/:id/profile", urlHandler.GetRequestProfile)
+	protected.PUT("/urls/:id/profile", urlHandler.SaveRequestProfile)
+	protected.DELETE("/urls/:id/profile", urlHandler.DeleteRequestProfile)
 	
 	// Crawl routes (only if Temporal is available)
 	
 	logger.Debug("Registering crawl routes...")
 	protected.POST("/crawl/start/:id", crawlHandler.StartCrawl)
 	protected.POST("/crawl/stop/:id", crawlHandler.StopCrawl)
+	protected.POST("/crawl/pause/:id", crawlHandler.PauseCrawl)
+	protected.POST("/crawl/resume/:id", crawlHandler.ResumeCrawl)
+	protected.GET("/crawl/progress/:id", crawlHandler.GetCrawlProgress)
+	protected.GET("/crawl/forms/:id", crawlHandler.GetForms)
+	protected.GET("/crawl/snapshot/:id", crawlHandler.GetSnapshot)
+	protected.GET("/crawl/snapshot/:id/download", crawlHandler.DownloadSnapshot)
+	protected.POST("/crawl/bulk/start", crawlHandler.StartBulkCrawl)
+	protected.POST("/crawl/bulk/stop", crawlHandler.StopBulkCrawl)
+	protected.GET("/crawl/bulk/:id", crawlHandler.GetBulkCrawlProgress)
+	protected.POST("/crawl/reanalyze", crawlHandler.StartReanalysis)
+	protected.GET("/crawl/link-cache/stats", crawlHandler.GetLinkCacheStats)
+	protected.GET("/crawl/repairs", crawlHandler.GetCrawlRepairs)
 	
 	// Stream endpoint with cookie-based authentication
 	streamProtected := api.Group("", sykellMiddleware.JWTMiddleware([]byte(cfg.JWTSecret), true))
@@ -135,5 +180,17 @@ func main() {
 
 	// Start server
 	logger.Info("Server starting", zap.String("port", cfg.Port))
-	e.Logger.Fatal(e.Start(":" + cfg.Port))
+	go func() {
+		if err := e.Start(":" + cfg.Port); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal("Server failed", zap.Error(err))
+		}
+	}()
+
+	<-ctx.Done()
+	logger.Info("Server shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Failed to shut down server", zap.Error(err))
+	}
 }
\ No newline at end of file
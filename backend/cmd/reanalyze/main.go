@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"sykell-backend/internal/config"
+	"sykell-backend/internal/crawl"
+	"sykell-backend/internal/logger"
+	"sykell-backend/internal/temporal"
+
+	"go.uber.org/zap"
+)
+
+// Starts a re-analysis of archived crawls across every user, typically after an extractor was added or fixed
+func main() {
+	crawlIDs := flag.String("crawl-ids", "", "Comma separated crawl IDs to re-analyze, every archived crawl when empty")
+	batchSize := flag.Int("batch-size", crawl.DefaultReanalyzeBatchSize, "Number of crawls re-analyzed per batch")
+	batchDelay := flag.Duration("batch-delay", crawl.DefaultReanalyzeBatchDelay, "Pause between two batches")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		panic("Failed to load configuration: " + err.Error())
+	}
+
+	if err := logger.InitLogger(cfg.LogLevel, cfg.LogFormat, cfg.Environment); err != nil {
+		panic("Failed to initialize logger: " + err.Error())
+	}
+	defer logger.Sync()
+
+	temporalService := temporal.NewService(cfg)
+	defer temporalService.Close()
+	temporalClient, err := temporalService.Client()
+	if err != nil {
+		logger.Fatal("Failed to connect to Temporal", zap.Error(err))
+	}
+
+	input := crawl.ReanalyzeInput{
+		TargetVersion: crawl.AnalysisVersion,
+		BatchSize:     *batchSize,
+		BatchDelay:    *batchDelay,
+	}
+	for _, id := range strings.Split(*crawlIDs, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			input.CrawlIDs = append(input.CrawlIDs, id)
+		}
+	}
+
+	run, err := crawl.StartReanalysisWorkflow(context.Background(), temporalClient, "reanalyze_all", input)
+	if err != nil {
+		logger.Fatal("Failed to start re-analysis", zap.Error(err))
+	}
+	logger.Info("Re-analysis started",
+		zap.String("workflow_id", run.GetID()),
+		zap.String("run_id", run.GetRunID()),
+		zap.Int("target_version", input.TargetVersion),
+		zap.Int("selected_crawls", len(input.CrawlIDs)))
+}
@@ -0,0 +1,101 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// validKey restricts keys to names that cannot escape the store directory
+var validKey = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// LocalStore stores blobs as files on the local filesystem
+type LocalStore struct {
+	root string
+}
+
+// NewLocalStore creates a LocalStore rooted at the given directory, creating it if needed
+func NewLocalStore(root string) (*LocalStore, error) {
+	if root == "" {
+		return nil, errors.New("blob store directory is not configured")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create blob store directory: %w", err)
+	}
+	return &LocalStore{root: root}, nil
+}
+
+// Put writes the blob to a temporary file and renames it into place so readers never see partial blobs
+func (s *LocalStore) Put(ctx context.Context, key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create blob file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to store blob: %w", err)
+	}
+	return nil
+}
+
+// Get reads the blob stored under the key
+func (s *LocalStore) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob: %w", err)
+	}
+	return data, nil
+}
+
+// Exists reports whether a blob file exists for the key
+func (s *LocalStore) Exists(ctx context.Context, key string) (bool, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to stat blob: %w", err)
+	}
+	return true, nil
+}
+
+// path maps a key to its file, fanning out into subdirectories by the first characters of the key
+func (s *LocalStore) path(key string) (string, error) {
+	if !validKey.MatchString(key) {
+		return "", fmt.Errorf("invalid blob key: %q", key)
+	}
+	if len(key) < 4 {
+		return filepath.Join(s.root, key), nil
+	}
+	return filepath.Join(s.root, key[:2], key[2:4], key), nil
+}
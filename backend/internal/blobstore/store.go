@@ -0,0 +1,87 @@
+package blobstore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sykell-backend/internal/config"
+)
+
+// ErrNotFound is returned when no blob is stored under the requested key
+var ErrNotFound = errors.New("blob not found")
+
+// Store is a pluggable key-value storage for immutable blobs
+type Store interface {
+	// Put stores the data under the key, overwriting any existing blob
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns the data stored under the key, or ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Exists reports whether a blob is stored under the key
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+// New creates the blob store selected by the configuration
+func New(cfg *config.Config) (Store, error) {
+	switch cfg.BlobStoreBackend {
+	case "", "local":
+		return NewLocalStore(cfg.BlobStoreDir)
+	default:
+		return nil, fmt.Errorf("unsupported blob store backend: %s", cfg.BlobStoreBackend)
+	}
+}
+
+// Hash returns the content address of the data, the hex encoded SHA-256 of its uncompressed bytes
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// PutCompressed gzip-compresses the data and stores it under its content address,
+// data that is already stored is not written again
+func PutCompressed(ctx context.Context, store Store, data []byte) (string, error) {
+	hash := Hash(data)
+	exists, err := store.Exists(ctx, hash)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return hash, nil
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		return "", fmt.Errorf("failed to compress blob: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return "", fmt.Errorf("failed to compress blob: %w", err)
+	}
+
+	if err := store.Put(ctx, hash, buf.Bytes()); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// GetCompressed loads the blob stored under the content address and decompresses it
+func GetCompressed(ctx context.Context, store Store, hash string) ([]byte, error) {
+	compressed, err := store.Get(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress blob: %w", err)
+	}
+	defer gz.Close()
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress blob: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,64 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutCompressedDeduplicates(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	data := []byte("<html><body>snapshot</body></html>")
+	hash, err := PutCompressed(ctx, store, data)
+	require.NoError(t, err)
+	assert.Equal(t, Hash(data), hash)
+
+	path, err := store.path(hash)
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	// Storing the same content again returns the same address without rewriting the blob
+	again, err := PutCompressed(ctx, store, data)
+	require.NoError(t, err)
+	assert.Equal(t, hash, again)
+	infoAgain, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, info.ModTime(), infoAgain.ModTime())
+
+	loaded, err := GetCompressed(ctx, store, hash)
+	require.NoError(t, err)
+	assert.Equal(t, data, loaded)
+
+	// The stored blob is compressed
+	raw, err := store.Get(ctx, hash)
+	require.NoError(t, err)
+	assert.NotEqual(t, data, raw)
+}
+
+func TestLocalStoreMissingAndInvalidKeys(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocalStore(root)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = store.Get(ctx, Hash([]byte("missing")))
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	exists, err := store.Exists(ctx, Hash([]byte("missing")))
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	err = store.Put(ctx, "../escape", []byte("data"))
+	assert.Error(t, err)
+	_, err = os.Stat(filepath.Join(filepath.Dir(root), "escape"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"errors"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -17,11 +20,49 @@ type Config struct {
 	Namespace		string
 	LogLevel    string
 	LogFormat   string
+	CrawlMaxBodyBytes int64
+	BlobStoreBackend string
+	BlobStoreDir     string
+	SecretsKey       string
+	CrawlProxyURL    string
+	CrawlAllowedNetworks []string
+	CrawlHostInterval time.Duration
+	CrawlHostBurst    int
+	CrawlHostMaxWait  time.Duration
+	LinkCacheTTL         time.Duration
+	LinkCacheNegativeTTL time.Duration
+	LinkRecheckDelay         time.Duration
+	LinkRecheckMaxDelay      time.Duration
+	LinkRecheckConfirmations int
+	BulkCrawlParallelism     int
+	LinkCheckBatchSize       int
+	CrawlMaxPause            time.Duration
+	CrawlUserLimitInteractive int
+	CrawlUserLimitScheduled   int
+	CrawlUserLimitBackfill    int
+	CrawlSlotsInteractive     int
+	CrawlSlotsScheduled       int
+	CrawlSlotsBackfill        int
+	ReconcileInterval         time.Duration
+	ReconcileGrace            time.Duration
+	CrawlExecutor             string
+	BackendURL                string
+	DBMaxOpenConns            int
+	DBMaxIdleConns            int
+	DBConnMaxLifetime         time.Duration
+	DBConnMaxIdleTime         time.Duration
+	WorkerWorkflowTasks       int
+	WorkerWorkflowPollers     int
+	WorkerActivityPollers     int
+	WorkerStopTimeout         time.Duration
 }
 
 // DefaultTimeout is the default timeout for db operations
 const DefaultTimeout = 5 * time.Second
 
+// DefaultCrawlMaxBodyBytes is the default maximum size of a crawled page body
+const DefaultCrawlMaxBodyBytes = 10 << 20
+
 // Load reads configuration from environment variables and returns a Config struct
 func Load() (*Config, error) {
 	// Load .env file if it exists
@@ -36,6 +77,46 @@ func Load() (*Config, error) {
 		Namespace:   getEnv("TEMPORAL_NAMESPACE", "default"),
 		LogLevel:    getEnv("LOG_LEVEL", "info"),
 		LogFormat:   getEnv("LOG_FORMAT", "json"),
+		CrawlMaxBodyBytes: getEnvInt64("CRAWL_MAX_BODY_BYTES", DefaultCrawlMaxBodyBytes),
+		BlobStoreBackend: getEnv("BLOB_STORE_BACKEND", "local"),
+		BlobStoreDir:     getEnv("BLOB_STORE_DIR", "./data/blobs"),
+		SecretsKey:       getEnv("SECRETS_KEY", "your-secrets-key"),
+		CrawlProxyURL:    getEnv("CRAWL_PROXY_URL", ""),
+		CrawlAllowedNetworks: getEnvList("CRAWL_ALLOWED_NETWORKS"),
+		CrawlHostInterval: getEnvDuration("CRAWL_HOST_INTERVAL", 500*time.Millisecond),
+		CrawlHostBurst:    int(getEnvInt64("CRAWL_HOST_BURST", 4)),
+		CrawlHostMaxWait:  getEnvDuration("CRAWL_HOST_MAX_WAIT", 2*time.Minute),
+		LinkCacheTTL:         getEnvDuration("LINK_CACHE_TTL", 6*time.Hour),
+		LinkCacheNegativeTTL: getEnvDuration("LINK_CACHE_NEGATIVE_TTL", 15*time.Minute),
+		LinkRecheckDelay:         getEnvDuration("LINK_RECHECK_DELAY", 5*time.Minute),
+		LinkRecheckMaxDelay:      getEnvDuration("LINK_RECHECK_MAX_DELAY", time.Hour),
+		LinkRecheckConfirmations: int(getEnvInt64("LINK_RECHECK_CONFIRMATIONS", 3)),
+		BulkCrawlParallelism:     int(getEnvInt64("BULK_CRAWL_PARALLELISM", 5)),
+		LinkCheckBatchSize:       int(getEnvInt64("LINK_CHECK_BATCH_SIZE", 50)),
+		CrawlMaxPause:            getEnvDuration("CRAWL_MAX_PAUSE", 24*time.Hour),
+		CrawlUserLimitInteractive: int(getEnvLimit("CRAWL_USER_LIMIT_INTERACTIVE", 5)),
+		CrawlUserLimitScheduled:   int(getEnvLimit("CRAWL_USER_LIMIT_SCHEDULED", 10)),
+		CrawlUserLimitBackfill:    int(getEnvLimit("CRAWL_USER_LIMIT_BACKFILL", 20)),
+		CrawlSlotsInteractive:     int(getEnvInt64("CRAWL_SLOTS_INTERACTIVE", 2)),
+		CrawlSlotsScheduled:       int(getEnvInt64("CRAWL_SLOTS_SCHEDULED", 1)),
+		CrawlSlotsBackfill:        int(getEnvInt64("CRAWL_SLOTS_BACKFILL", 1)),
+		ReconcileInterval:         getEnvOptionalDuration("RECONCILE_INTERVAL", 5*time.Minute),
+		ReconcileGrace:            getEnvDuration("RECONCILE_GRACE", 10*time.Minute),
+		CrawlExecutor:             getEnv("CRAWL_EXECUTOR", "temporal"),
+		BackendURL:                getEnv("BACKEND_URL", "http://localhost:7070"),
+		DBMaxOpenConns:            int(getEnvInt64("DB_MAX_OPEN_CONNS", 20)),
+		DBMaxIdleConns:            int(getEnvInt64("DB_MAX_IDLE_CONNS", 10)),
+		DBConnMaxLifetime:         getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		DBConnMaxIdleTime:         getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+		WorkerWorkflowTasks:       int(getEnvInt64("WORKER_WORKFLOW_TASKS", 50)),
+		WorkerWorkflowPollers:     int(getEnvInt64("WORKER_WORKFLOW_POLLERS", 2)),
+		WorkerActivityPollers:     int(getEnvInt64("WORKER_ACTIVITY_POLLERS", 2)),
+		WorkerStopTimeout:         getEnvDuration("WORKER_STOP_TIMEOUT", 30*time.Second),
+	}
+
+	// The API and the workers must share the key, the default only suits a development setup
+	if os.Getenv("SECRETS_KEY") == "" && cfg.Environment != "development" {
+		return nil, errors.New("SECRETS_KEY must be set outside development")
 	}
 
 	return cfg, nil
@@ -47,4 +128,60 @@ func getEnv(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+// getEnvInt64 retrieves the environment variable named by the key as an int64,
+// falling back to the default when it is missing or not a valid positive number
+func getEnvInt64(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getEnvLimit retrieves the environment variable named by the key as a limit like getEnvInt64, except that 0 is
+// kept and means no limit
+func getEnvLimit(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil && parsed >= 0 {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getEnvDuration retrieves the environment variable named by the key as a duration such as "500ms",
+// falling back to the default when it is missing or not a valid positive duration
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getEnvOptionalDuration retrieves the environment variable named by the key as a duration like getEnvDuration,
+// except that 0 is kept so the feature it paces can be turned off
+func getEnvOptionalDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed >= 0 {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList retrieves the environment variable named by the key as a comma-separated list,
+// empty entries are dropped
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
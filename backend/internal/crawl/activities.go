@@ -0,0 +1,109 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sykell-backend/internal/blobstore"
+	"sykell-backend/internal/config"
+	"sykell-backend/internal/quota"
+	"sykell-backend/internal/ratelimit"
+	"sykell-backend/internal/utils"
+	"sync"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// Activities holds the dependencies of the crawl and link recheck activities, the worker registers it so every
+// method ending in Activity is an activity of that name
+type Activities struct {
+	Config     *config.Config
+	Repo       Repo
+	RateLimits ratelimit.Repo
+	Snapshots  blobstore.Store
+	Notifier   Notifier
+	// Quotas checks the crawls of bulk starts against the user's plan, they are not limited when nil
+	Quotas *quota.Service
+	// Transport carries the requests of every crawl when set, otherwise the crawls share a transport per proxy
+	// that refuses to connect to internal addresses
+	Transport http.RoundTripper
+
+	mu         sync.Mutex
+	transports map[string]*http.Transport
+}
+
+// activities names the activity methods in the workflows, the workflows never call them so it stays nil
+var activities *Activities
+
+// NewActivities creates the activities of the crawl workflows, backed by the database and the snapshot store
+func NewActivities(cfg *config.Config, sqlDB *sql.DB, snapshots blobstore.Store) *Activities {
+	return &Activities{
+		Config:     cfg,
+		Repo:       NewRepo(sqlDB),
+		RateLimits: ratelimit.NewRepo(sqlDB),
+		Snapshots:  snapshots,
+		Notifier:   NewHTTPNotifier(cfg.BackendURL),
+		Quotas:     quota.NewService(quota.NewRepo(sqlDB)),
+	}
+}
+
+// crawlTransport returns the transport the requests of a crawl with the profile are sent through, paced per host.
+// Invalid proxy or allowlist settings fail with a non-retryable error
+func (a *Activities) crawlTransport(ctx context.Context, profile *utils.RequestProfile) (http.RoundTripper, error) {
+	base := a.Transport
+	if base == nil {
+		proxyURL, err := resolveProxy(a.Config, profile)
+		if err != nil {
+			return nil, temporal.NewNonRetryableApplicationError(fmt.Sprintf("Invalid proxy configuration: %v", err), "InvalidProxy", err)
+		}
+		if proxyURL != nil {
+			activityLogger(ctx).Info("Using outbound proxy", "proxy", proxyURL.Redacted())
+		}
+		base, err = a.sharedTransport(proxyURL)
+		if err != nil {
+			return nil, temporal.NewNonRetryableApplicationError(fmt.Sprintf("Invalid crawl network allowlist: %v", err), "InvalidConfiguration", err)
+		}
+	}
+
+	// Pace the requests to each host, the budget is shared with every other worker through the database
+	limiter := ratelimit.NewLimiter(a.RateLimits, ratelimit.OptionsFromConfig(a.Config), &http.Client{Transport: base})
+	return ratelimit.NewTransport(base, limiter), nil
+}
+
+// sharedTransport returns the transport of the proxy, created on first use so the connections to the crawled
+// hosts are kept alive across activities, a nil proxy connects directly
+func (a *Activities) sharedTransport(proxyURL *url.URL) (*http.Transport, error) {
+	key := ""
+	if proxyURL != nil {
+		key = proxyURL.String()
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if transport, ok := a.transports[key]; ok {
+		return transport, nil
+	}
+	// Refuse connections to private, loopback, link-local and metadata addresses, checked after DNS resolution
+	guard, err := utils.NewAddressGuard(a.Config.CrawlAllowedNetworks)
+	if err != nil {
+		return nil, err
+	}
+	if a.transports == nil {
+		a.transports = make(map[string]*http.Transport)
+	}
+	transport := utils.NewCrawlTransport(proxyURL, guard)
+	a.transports[key] = transport
+	return transport, nil
+}
+
+// closeIdleConnections releases the idle connections of the shared transports, it is not exported so the worker
+// does not register it as an activity
+func (a *Activities) closeIdleConnections() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, transport := range a.transports {
+		transport.CloseIdleConnections()
+	}
+}
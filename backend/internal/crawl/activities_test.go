@@ -0,0 +1,32 @@
+package crawl
+
+import (
+	"net/url"
+	"sykell-backend/internal/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedTransport(t *testing.T) {
+	a := &Activities{Config: &config.Config{}}
+	proxyURL, err := url.Parse("http://proxy.example.com:3128")
+	require.NoError(t, err)
+
+	// Crawls through the same proxy reuse its transport and its connections
+	direct, err := a.sharedTransport(nil)
+	require.NoError(t, err)
+	again, err := a.sharedTransport(nil)
+	require.NoError(t, err)
+	assert.Same(t, direct, again)
+
+	proxied, err := a.sharedTransport(proxyURL)
+	require.NoError(t, err)
+	assert.NotSame(t, direct, proxied)
+
+	// An invalid allowlist never creates a transport
+	invalid := &Activities{Config: &config.Config{CrawlAllowedNetworks: []string{"not-a-network"}}}
+	_, err = invalid.sharedTransport(nil)
+	assert.Error(t, err)
+}
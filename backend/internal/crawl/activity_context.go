@@ -0,0 +1,45 @@
+package crawl
+
+import (
+	"context"
+	"sykell-backend/internal/logger"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/log"
+	"go.uber.org/zap"
+)
+
+// activityLogger returns the logger of the activity, or a zap logger when the activity is run by the local
+// executor outside a Temporal worker
+func activityLogger(ctx context.Context) log.Logger {
+	if activity.IsActivity(ctx) {
+		return activity.GetLogger(ctx)
+	}
+	return zapActivityLogger{sugar: logger.GetLogger().Sugar()}
+}
+
+// recordHeartbeat records a heartbeat of the activity, it does nothing outside a Temporal worker
+func recordHeartbeat(ctx context.Context, details ...interface{}) {
+	if activity.IsActivity(ctx) {
+		activity.RecordHeartbeat(ctx, details...)
+	}
+}
+
+// heartbeatDetails loads the details of the last heartbeat of a previous attempt of the activity into valuePtr,
+// it returns false when there are none or outside a Temporal worker
+func heartbeatDetails(ctx context.Context, valuePtr interface{}) bool {
+	if !activity.IsActivity(ctx) || !activity.HasHeartbeatDetails(ctx) {
+		return false
+	}
+	return activity.GetHeartbeatDetails(ctx, valuePtr) == nil
+}
+
+// zapActivityLogger logs the key/value pairs of the activities through zap
+type zapActivityLogger struct {
+	sugar *zap.SugaredLogger
+}
+
+func (l zapActivityLogger) Debug(msg string, keyvals ...interface{}) { l.sugar.Debugw(msg, keyvals...) }
+func (l zapActivityLogger) Info(msg string, keyvals ...interface{})  { l.sugar.Infow(msg, keyvals...) }
+func (l zapActivityLogger) Warn(msg string, keyvals ...interface{})  { l.sugar.Warnw(msg, keyvals...) }
+func (l zapActivityLogger) Error(msg string, keyvals ...interface{}) { l.sugar.Errorw(msg, keyvals...) }
@@ -0,0 +1,56 @@
+package crawl
+
+import (
+	"sykell-backend/internal/utils"
+
+	"golang.org/x/net/html"
+)
+
+// AnalysisVersion identifies the extractors used to analyze a page, bump it whenever an
+// extractor in internal/utils changes so that old crawls can be re-analyzed from their snapshots
+const AnalysisVersion = 2
+
+// pageAnalysis holds the metrics extracted from a parsed page that do not require any network access
+type pageAnalysis struct {
+	HtmlVersion  string
+	PageTitle    string
+	H1Count      int32
+	H2Count      int32
+	H3Count      int32
+	H4Count      int32
+	H5Count      int32
+	H6Count      int32
+	Forms        []utils.FormInfo
+	HasLoginForm bool
+	ContentHash  string
+	SimHash      uint64
+}
+
+// analyzeDocument runs the offline extractors against a parsed page
+func analyzeDocument(doc *html.Node, pageURL string) pageAnalysis {
+	headingCounts := utils.CountHeadings(doc)
+	analysis := pageAnalysis{
+		HtmlVersion: utils.ExtractHtmlVersion(doc),
+		PageTitle:   utils.SanitizeText(utils.ExtractTitle(doc), 500),
+		H1Count:     int32(headingCounts["h1"]),
+		H2Count:     int32(headingCounts["h2"]),
+		H3Count:     int32(headingCounts["h3"]),
+		H4Count:     int32(headingCounts["h4"]),
+		H5Count:     int32(headingCounts["h5"]),
+		H6Count:     int32(headingCounts["h6"]),
+		Forms:       utils.ExtractForms(doc, pageURL),
+	}
+
+	for _, form := range analysis.Forms {
+		if form.Type == utils.FormTypeLogin {
+			analysis.HasLoginForm = true
+		}
+	}
+
+	// Fingerprint the visible text for duplicate content detection
+	if visibleText := utils.ExtractVisibleText(doc); visibleText != "" {
+		analysis.ContentHash = utils.ContentHash(visibleText)
+		analysis.SimHash = utils.SimHash(visibleText)
+	}
+	return analysis
+}
@@ -0,0 +1,368 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sykell-backend/internal/quota"
+	urlpkg "sykell-backend/internal/url"
+	"time"
+
+	"github.com/google/uuid"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Bulk action limits
+const (
+	MaxBulkURLs        = 1000
+	MaxBulkParallelism = 20
+)
+
+// bulkQueueTimeout is how long the crawl of a URL of a bulk start waits for one of the user's active crawls
+// to finish before it fails
+const bulkQueueTimeout = time.Hour
+
+// Errors returned when a bulk action cannot be started
+var (
+	ErrBulkTargetsRequired = errors.New("either url_ids or filter is required")
+	ErrTooManyBulkURLs     = fmt.Errorf("at most %d URLs can be handled at once", MaxBulkURLs)
+	ErrBatchNotFound       = errors.New("batch not found")
+)
+
+// BulkCrawlWorkflow starts or stops the crawls of many URLs, starting at most Parallelism crawls at a time
+// and waiting for each of them, its progress is available through the progress query
+func BulkCrawlWorkflow(ctx workflow.Context, input BulkCrawlInput) (BulkCrawlProgress, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting bulk crawl workflow", "batch_id", input.BatchID, "action", input.Action, "urls", len(input.URLIDs), "parallelism", input.Parallelism)
+
+	progress := BulkCrawlProgress{
+		BatchID: input.BatchID,
+		UserID:  input.UserID,
+		Action:  input.Action,
+		Total:   len(input.URLIDs),
+	}
+	if err := workflow.SetQueryHandler(ctx, BulkProgressQueryName, func() (BulkCrawlProgress, error) {
+		return progress, nil
+	}); err != nil {
+		return progress, err
+	}
+
+	activityOptions := workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, activityOptions)
+
+	parallelism := input.Parallelism
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+	slots := workflow.NewBufferedChannel(ctx, parallelism)
+	wg := workflow.NewWaitGroup(ctx)
+	for _, urlID := range input.URLIDs {
+		// Blocks while all slots are taken
+		slots.Send(ctx, struct{}{})
+		wg.Add(1)
+		item := BulkCrawlItem{UserID: input.UserID, URLID: urlID, Priority: input.Priority}
+		workflow.Go(ctx, func(ctx workflow.Context) {
+			defer wg.Done()
+			defer slots.Receive(ctx, nil)
+			if input.Action == BulkActionStop {
+				stopBulkItem(ctx, item, &progress)
+			} else {
+				item.WorkflowID = "crawl_" + urlID + "_" + input.BatchID
+				startBulkItem(ctx, item, &progress)
+			}
+		})
+	}
+	wg.Wait(ctx)
+
+	progress.Done = true
+	logger.Info("Bulk crawl workflow completed", "batch_id", input.BatchID, "completed", progress.Completed, "stopped", progress.Stopped, "skipped", progress.Skipped, "failed", progress.Failed)
+	return progress, nil
+}
+
+// startBulkItem queues the crawl of a URL and runs it as a child workflow until it finishes
+func startBulkItem(ctx workflow.Context, item BulkCrawlItem, progress *BulkCrawlProgress) {
+	logger := workflow.GetLogger(ctx)
+
+	// The activity is retried while the user has too many active crawls of the priority
+	queueCtx := workflow.WithScheduleToCloseTimeout(ctx, bulkQueueTimeout)
+	queueCtx = workflow.WithRetryPolicy(queueCtx, temporal.RetryPolicy{
+		InitialInterval:    10 * time.Second,
+		BackoffCoefficient: 2.0,
+		MaximumInterval:    time.Minute,
+	})
+	var crawlInput *WorlFlowInput
+	if err := workflow.ExecuteActivity(queueCtx, activities.QueueBulkCrawlActivity, item).Get(ctx, &crawlInput); err != nil {
+		logger.Error("Failed to queue bulk crawl", "error", err, "url_id", item.URLID)
+		progress.Failed++
+		return
+	}
+	if crawlInput == nil {
+		progress.Skipped++
+		return
+	}
+
+	childCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+		WorkflowID:          crawlInput.WorkflowID,
+		TaskQueue:           TaskQueueForPriority(item.Priority),
+		WorkflowTaskTimeout: time.Minute,
+		ParentClosePolicy:   enums.PARENT_CLOSE_POLICY_REQUEST_CANCEL,
+	})
+	progress.Running++
+	err := workflow.ExecuteChildWorkflow(childCtx, WorkflowName, *crawlInput).Get(ctx, nil)
+	progress.Running--
+	switch {
+	case err == nil:
+		progress.Completed++
+	case temporal.IsCanceledError(err):
+		progress.Stopped++
+	default:
+		logger.Error("Bulk crawl failed", "error", err, "url_id", item.URLID, "workflow_id", crawlInput.WorkflowID)
+		progress.Failed++
+	}
+}
+
+// stopBulkItem stops the active crawls of a URL and cancels their workflows
+func stopBulkItem(ctx workflow.Context, item BulkCrawlItem, progress *BulkCrawlProgress) {
+	logger := workflow.GetLogger(ctx)
+
+	var workflowIDs []string
+	if err := workflow.ExecuteActivity(ctx, activities.StopBulkCrawlActivity, item).Get(ctx, &workflowIDs); err != nil {
+		logger.Error("Failed to stop bulk crawl", "error", err, "url_id", item.URLID)
+		progress.Failed++
+		return
+	}
+	if len(workflowIDs) == 0 {
+		progress.Skipped++
+		return
+	}
+	for _, workflowID := range workflowIDs {
+		// The crawl is already marked stopped, a workflow that has finished in the meantime cannot be canceled
+		if err := workflow.RequestCancelExternalWorkflow(ctx, workflowID, "").Get(ctx, nil); err != nil {
+			logger.Warn("Failed to cancel crawl workflow", "error", err, "workflow_id", workflowID)
+		}
+	}
+	progress.Stopped++
+}
+
+// QueueBulkCrawlActivity queues the crawl of a URL of a bulk start, it returns nil when the URL does not belong
+// to the user, is already being crawled or cannot be crawled under the user's plan, and the already queued crawl
+// when the activity is retried. It fails while the user has too many active crawls of the priority
+func (a *Activities) QueueBulkCrawlActivity(ctx context.Context, item BulkCrawlItem) (*WorlFlowInput, error) {
+	repo := a.Repo
+
+	url, err := repo.GetUrlByIdAndUserId(ctx, item.URLID, item.UserID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load URL: %w", err)
+	}
+	crawlInput := &WorlFlowInput{
+		URLID:      url.ID,
+		UserID:     item.UserID,
+		WorkflowID: item.WorkflowID,
+		URL:        url.NormalizedUrl,
+	}
+
+	crawlID, err := repo.GetCrawlIDByWorkflowID(ctx, item.WorkflowID)
+	if err == nil {
+		crawlInput.CrawlID = crawlID
+		return crawlInput, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to look up queued crawl: %w", err)
+	}
+
+	// Batches started before crawl priorities existed have no priority, they were backfills
+	priority := item.Priority
+	if priority == "" {
+		priority = PriorityBackfill
+	}
+	// URLs that are already being crawled, or that the user's plan does not allow to crawl now, are skipped. The
+	// limits are checked again for every URL since the user may have started other crawls since the batch
+	crawlID = uuid.New().String()
+	queued, err := repo.QueueCrawl(ctx, QueuedCrawl{
+		CrawlID:     crawlID,
+		URLID:       url.ID,
+		UserID:      item.UserID,
+		WorkflowID:  item.WorkflowID,
+		Priority:    priority,
+		ActiveLimit: userCrawlLimit(a.Config, priority),
+		Quotas:      a.Quotas,
+	})
+	var limitErr *quota.LimitError
+	if errors.As(err, &limitErr) {
+		activityLogger(ctx).Info("Skipping URL not allowed by the plan", "url_id", url.ID, "resource", limitErr.Resource)
+		return nil, nil
+	}
+	if errors.Is(err, ErrCrawlLimitReached) {
+		// Retried by the workflow until one of the user's crawls finishes
+		return nil, temporal.NewApplicationError(err.Error(), "CrawlLimitReached", err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to queue crawl: %w", err)
+	}
+	if !queued {
+		return nil, nil
+	}
+	crawlInput.CrawlID = crawlID
+	a.Notifier.CrawlUpdated(item.UserID, url.ID, "")
+	return crawlInput, nil
+}
+
+// StopBulkCrawlActivity marks the active crawls of a URL of a bulk stop as stopped and returns their
+// workflow IDs, the workflow cancels them
+func (a *Activities) StopBulkCrawlActivity(ctx context.Context, item BulkCrawlItem) ([]string, error) {
+	repo := a.Repo
+
+	url, err := repo.GetUrlByIdAndUserId(ctx, item.URLID, item.UserID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load URL: %w", err)
+	}
+	activeCrawls, err := repo.GetActiveCrawlsForUrlId(ctx, url.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active crawls: %w", err)
+	}
+
+	var workflowIDs []string
+	for _, crawl := range activeCrawls {
+		if err := repo.SetCrawlStopped(ctx, crawl.ID); err != nil {
+			return nil, fmt.Errorf("failed to update crawl status: %w", err)
+		}
+		workflowIDs = append(workflowIDs, crawl.WorkflowID)
+	}
+	if len(workflowIDs) > 0 {
+		a.Notifier.CrawlUpdated(item.UserID, url.ID, "")
+	}
+	return workflowIDs, nil
+}
+
+// StartBulkCrawl starts or stops the crawls of the selected URLs, or of every URL matching the filter,
+// in a bulk crawl workflow and returns the ID of the batch
+func (s *CrawlService) StartBulkCrawl(ctx context.Context, userID string, action string, request BulkCrawlRequest) (*BulkCrawlResponse, error) {
+	urlIDs, err := s.resolveBulkTargets(ctx, userID, request)
+	if err != nil {
+		return nil, err
+	}
+
+	priority := request.Priority
+	if priority == "" {
+		priority = PriorityBackfill
+	}
+	// Bulk crawls never take the queue of the crawls started from the dashboard
+	if priority != PriorityScheduled && priority != PriorityBackfill {
+		return nil, ErrInvalidPriority
+	}
+
+	parallelism := s.config.BulkCrawlParallelism
+	if request.Parallelism > 0 {
+		parallelism = request.Parallelism
+	}
+	parallelism = min(max(parallelism, 1), MaxBulkParallelism)
+
+	// The crawls a user already runs count towards the limit, the batch runs at most the remaining ones at a time
+	if action == BulkActionStart {
+		if s.quotas != nil {
+			if err := s.quotas.CheckCrawls(ctx, userID, len(urlIDs)); err != nil {
+				return nil, err
+			}
+		}
+		remaining, err := s.remainingCrawls(ctx, userID, priority)
+		if err != nil {
+			return nil, err
+		}
+		if remaining == 0 {
+			return nil, ErrCrawlLimitReached
+		}
+		if remaining > 0 {
+			parallelism = min(parallelism, remaining)
+		}
+	}
+
+	batchID := "bulk_" + uuid.New().String()
+	err = s.executor.StartBulk(ctx, BulkCrawlInput{
+		BatchID:     batchID,
+		UserID:      userID,
+		Action:      action,
+		URLIDs:      urlIDs,
+		Parallelism: parallelism,
+		Priority:    priority,
+	}, priority)
+	if err != nil {
+		return nil, err
+	}
+	return &BulkCrawlResponse{
+		BatchID: batchID,
+		Action:  action,
+		Total:   len(urlIDs),
+	}, nil
+}
+
+// GetBulkCrawlProgress returns the progress of a batch of the user
+func (s *CrawlService) GetBulkCrawlProgress(ctx context.Context, userID string, batchID string) (*BulkCrawlProgress, error) {
+	progress, err := s.executor.BulkProgress(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if progress.UserID != userID {
+		return nil, ErrBatchNotFound
+	}
+	return &progress, nil
+}
+
+// resolveBulkTargets returns the distinct URL IDs of a bulk request, in the order given or listed
+func (s *CrawlService) resolveBulkTargets(ctx context.Context, userID string, request BulkCrawlRequest) ([]string, error) {
+	var urlIDs []string
+	switch {
+	case len(request.URLIDs) > 0:
+		urlIDs = uniqueURLIDs(request.URLIDs)
+	case request.Filter != nil:
+		sortDir := request.Filter.SortOrder
+		if sortDir == "" {
+			sortDir = "desc"
+		}
+		// One more than allowed is listed to detect filters matching too many URLs
+		listed, err := s.repo.ListUrlIDsByFilter(ctx, userID, request.Filter.Query, urlpkg.MapSortColumn(request.Filter.SortBy), sortDir, MaxBulkURLs+1)
+		if err != nil {
+			return nil, err
+		}
+		urlIDs = listed
+	default:
+		return nil, ErrBulkTargetsRequired
+	}
+	if len(urlIDs) == 0 {
+		return nil, ErrBulkTargetsRequired
+	}
+	if len(urlIDs) > MaxBulkURLs {
+		return nil, ErrTooManyBulkURLs
+	}
+	return urlIDs, nil
+}
+
+// uniqueURLIDs removes empty and repeated URL IDs, keeping the first occurrence of each
+func uniqueURLIDs(urlIDs []string) []string {
+	seen := make(map[string]bool, len(urlIDs))
+	unique := make([]string, 0, len(urlIDs))
+	for _, urlID := range urlIDs {
+		if urlID == "" || seen[urlID] {
+			continue
+		}
+		seen[urlID] = true
+		unique = append(unique, urlID)
+	}
+	return unique
+}
@@ -0,0 +1,100 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sykell-backend/internal/config"
+	"sykell-backend/internal/quota"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+)
+
+func TestResolveBulkTargets(t *testing.T) {
+	service := &CrawlService{}
+
+	urlIDs, err := service.resolveBulkTargets(context.Background(), "user-1", BulkCrawlRequest{URLIDs: []string{"a", "b", "", "a", "c", "b"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, urlIDs)
+
+	_, err = service.resolveBulkTargets(context.Background(), "user-1", BulkCrawlRequest{})
+	assert.ErrorIs(t, err, ErrBulkTargetsRequired)
+
+	tooMany := make([]string, MaxBulkURLs+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("url-%d", i)
+	}
+	_, err = service.resolveBulkTargets(context.Background(), "user-1", BulkCrawlRequest{URLIDs: tooMany})
+	assert.ErrorIs(t, err, ErrTooManyBulkURLs)
+}
+
+func TestResolveBulkTargetsFilter(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	// The filter lists one URL more than allowed to detect oversized selections
+	mock.ExpectQuery("SELECT").
+		WillReturnRows(sqlmock.NewRows(nil))
+
+	service := &CrawlService{repo: NewRepo(mockDB)}
+	_, err = service.resolveBulkTargets(context.Background(), "user-1", BulkCrawlRequest{Filter: &BulkCrawlFilter{Query: "example"}})
+	assert.ErrorIs(t, err, ErrBulkTargetsRequired)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// bulkQueueRepo queues the crawls of a bulk start in memory, failing for the URLs given an error
+type bulkQueueRepo struct {
+	Repo
+	errs   map[string]error
+	queued []QueuedCrawl
+}
+
+func (r *bulkQueueRepo) GetUrlByIdAndUserId(ctx context.Context, urlID string, userID string) (*URLResponse, error) {
+	return &URLResponse{ID: urlID, NormalizedUrl: "https://" + urlID + ".example.com/"}, nil
+}
+
+func (r *bulkQueueRepo) GetCrawlIDByWorkflowID(ctx context.Context, workflowID string) (string, error) {
+	return "", sql.ErrNoRows
+}
+
+func (r *bulkQueueRepo) QueueCrawl(ctx context.Context, crawl QueuedCrawl) (bool, error) {
+	if err := r.errs[crawl.URLID]; err != nil {
+		return false, err
+	}
+	r.queued = append(r.queued, crawl)
+	return true, nil
+}
+
+func TestQueueBulkCrawlActivityRechecksLimits(t *testing.T) {
+	repo := &bulkQueueRepo{errs: map[string]error{
+		"recent": &quota.LimitError{Resource: quota.ResourceScheduleInterval},
+		"busy":   ErrCrawlLimitReached,
+	}}
+	a := &Activities{Config: &config.Config{CrawlUserLimitBackfill: 20}, Repo: repo, Notifier: &memoryNotifier{}}
+	queue := func(urlID string) (*WorlFlowInput, error) {
+		return a.QueueBulkCrawlActivity(context.Background(), BulkCrawlItem{UserID: "user-1", URLID: urlID, WorkflowID: "crawl_" + urlID + "_bulk", Priority: PriorityBackfill})
+	}
+
+	// A URL the plan does not allow to crawl now is skipped
+	crawlInput, err := queue("recent")
+	require.NoError(t, err)
+	assert.Nil(t, crawlInput)
+
+	// A user without free slots fails the attempt so the workflow retries it later
+	_, err = queue("busy")
+	var appErr *temporal.ApplicationError
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, "CrawlLimitReached", appErr.Type())
+	assert.False(t, appErr.NonRetryable())
+
+	crawlInput, err = queue("stale")
+	require.NoError(t, err)
+	require.NotNil(t, crawlInput)
+	require.Len(t, repo.queued, 1)
+	assert.Equal(t, 20, repo.queued[0].ActiveLimit)
+}
@@ -1,51 +1,37 @@
 package crawl
 
 import (
+	"bytes"
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/http/cookiejar"
+	"sykell-backend/internal/blobstore"
 	"sykell-backend/internal/config"
 	"sykell-backend/internal/db"
+	"sykell-backend/internal/ratelimit"
 	"sykell-backend/internal/utils"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql" // MySQL driver
-	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
 	"golang.org/x/net/html"
+	"golang.org/x/net/publicsuffix"
 )
 
-// CrawlURLActivity performs the actual URL crawling and metadata extraction, it runs in the Temporal worker process
-func CrawlURLActivity(ctx context.Context, input WorlFlowInput) error {
+// FetchPageActivity fetches, archives and analyzes the page of a crawl, it runs in the Temporal worker process.
+// It returns the page whose links the workflow checks in batches, or nil when the crawl is already done
+func (a *Activities) FetchPageActivity(ctx context.Context, input WorlFlowInput) (*PageFetchResult, error) {
 	// Get the activity logger for proper Temporal logging
-	logger := activity.GetLogger(ctx)
-	logger.Info("Starting crawl activity", "url", input.URL, "crawl_id", input.CrawlID)
+	logger := activityLogger(ctx)
+	logger.Info("Starting fetch activity", "url", input.URL, "crawl_id", input.CrawlID)
 
-	cfg, err := config.Load()
-	if err != nil {
-		logger.Error("Failed to load configuration", "error", err)
-		return fmt.Errorf("failed to load configuration: %w", err)
-	}
-
-	// Connect to database
-	dbSQL, err := sql.Open("mysql", cfg.DatabaseURL)
-	if err != nil {
-		logger.Error("Failed to connect to database", "error", err)
-		return fmt.Errorf("failed to connect to database: %w", err)
-	}
-	defer dbSQL.Close()
-
-	// Test database connection
-	if err := dbSQL.Ping(); err != nil {
-		logger.Error("Failed to ping database", "error", err)
-		return fmt.Errorf("failed to ping database: %w", err)
-	}
-	logger.Info("Database connected successfully")
-
-	repo := NewRepo(dbSQL)
+	cfg := a.Config
+	repo := a.Repo
+	snapshots := a.Snapshots
 	
 	// Start keep-alive goroutine to send heartbeats every 30 seconds
-	cancelKeepAlive := keepAlive(ctx, 30*time.Second)
+	cancelKeepAlive := keepAlive(ctx, 30*time.Second, func() interface{} { return "Crawl still in progress" })
 	defer cancelKeepAlive()
 	
 	// Defer function to handle error cases and set crawl status to error
@@ -55,147 +41,270 @@ func CrawlURLActivity(ctx context.Context, input WorlFlowInput) error {
 			bctx, cancel := context.WithTimeout(context.Background(), config.DefaultTimeout)
 			defer cancel()
 			repo.SetCrawlError(bctx, input.CrawlID, fmt.Sprintf("Activity panicked: %v", r))
-			NotifyCrawlUpdateHTTP(input.UserID, input.URLID)
+			a.Notifier.CrawlUpdated(input.UserID, input.URLID, "")
 		}
 	}()
 	
-	// Track if we successfully complete the crawl
-	var crawlCompleted bool
+	// Track if we successfully fetched the page, or completed the crawl of a non-HTML resource
+	var fetched bool
+	failureMessage := "Crawl failed to complete (timeout, error, or cancellation)"
 	defer func() {
-		if !crawlCompleted {
-			logger.Error("Crawl did not complete successfully", "crawl_id", input.CrawlID)			
+		if !fetched {
+			logger.Error("Page was not fetched successfully", "crawl_id", input.CrawlID)			
 			bctx, cancel := context.WithTimeout(context.Background(), config.DefaultTimeout)
 			defer cancel()
-			repo.SetCrawlError(bctx, input.CrawlID, "Crawl failed to complete (timeout, error, or cancellation)")
-			NotifyCrawlUpdateHTTP(input.UserID, input.URLID)
+			repo.SetCrawlError(bctx, input.CrawlID, failureMessage)
+			a.Notifier.CrawlUpdated(input.UserID, input.URLID, "")
 		}
 	}()
 	
 
-	if err = repo.SetCrawlRunning(ctx, input.CrawlID); err != nil {	
+	if err := repo.SetCrawlRunning(ctx, input.CrawlID); err != nil {	
 		logger.Error("Failed to set crawl running", "error", err, "crawl_id", input.CrawlID)
-		return err
+		return nil, err
 	}
 
 	logger.Info("Crawl status set to running", "crawl_id", input.CrawlID)
 	// Notify SSE that crawl started
-	NotifyCrawlUpdateHTTP(input.UserID, input.URLID)
+	a.Notifier.CrawlUpdated(input.UserID, input.URLID, "")
 
 	// Create HTTP client with longer timeout and proper context
 	client := &http.Client{
 		Timeout: 20 * time.Second,
 	}
 
+	// Load the custom request settings of the URL, if any
+	profile, err := loadRequestProfile(ctx, repo, cfg.SecretsKey, input.URLID)
+	if err != nil {
+		logger.Error("Failed to load request profile", "error", err, "url_id", input.URLID)
+		return nil, fmt.Errorf("failed to load request profile: %w", err)
+	}
+
+	// Route every request of the crawl through the outbound proxy, if one is configured
+	transport, err := a.crawlTransport(ctx, profile)
+	if err != nil {
+		logger.Error("Invalid crawl transport configuration", "error", err, "url_id", input.URLID)
+		var appErr *temporal.ApplicationError
+		if errors.As(err, &appErr) {
+			failureMessage = appErr.Message()
+		}
+		return nil, err
+	}
+	client.Transport = transport
+	// The credentials of the profile are only sent to the host of the URL, not to the hosts it redirects to
+	if profile != nil {
+		client.CheckRedirect = profile.CheckRedirect
+	}
+
+	// Targets that resolve to internal addresses are recorded as blocked and never retried
+	failBlocked := func(err error) error {
+		logger.Error("Blocked request to an internal address", "error", err, "url", input.URL)
+		if err := repo.UpdateCrawlOutcome(ctx, input.CrawlID, OutcomeBlocked, "", "", 0); err != nil {
+			logger.Error("Failed to update crawl outcome", "error", err, "crawl_id", input.CrawlID)
+		}
+		failureMessage = fmt.Sprintf("Blocked: %v", err)
+		return temporal.NewNonRetryableApplicationError(failureMessage, "BlockedAddress", err)
+	}
+
+	// Sign in through the login form first so the page is fetched with the session cookies
+	if profile != nil && profile.Login != nil {
+		jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cookie jar: %w", err)
+		}
+		client.Jar = jar
+
+		logger.Info("Logging in before fetching", "login_url", profile.Login.LoginURL)
+		recordHeartbeat(ctx, "Logging in")
+		err = utils.PerformFormLogin(ctx, client, profile.Login, profile.Apply)
+		if errors.Is(err, utils.ErrBlockedAddress) {
+			return nil, failBlocked(err)
+		}
+		if errors.Is(err, utils.ErrLoginFailed) {
+			logger.Error("Login rejected", "error", err, "login_url", profile.Login.LoginURL)
+			failureMessage = fmt.Sprintf("Login failed: %v", err)
+			return nil, temporal.NewNonRetryableApplicationError(failureMessage, "LoginFailed", err)
+		}
+		if err != nil {
+			logger.Error("Failed to log in", "error", err, "login_url", profile.Login.LoginURL)
+			return nil, fmt.Errorf("failed to log in: %w", err)
+		}
+		logger.Info("Logged in", "login_url", profile.Login.LoginURL)
+	}
+
 	logger.Info("Fetching URL", "url", input.URL)
 	
 	// Create request with activity context for cancellation support
 	req, err := http.NewRequestWithContext(ctx, "GET", input.URL, nil)
 	if err != nil {
 		logger.Error("Failed to create HTTP request", "error", err, "url", input.URL)
-		return fmt.Errorf("failed to create HTTP request: %w", err)
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
 	
 	// Set a reasonable User-Agent to avoid blocking
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; SykellBot/1.0)")
+	req.Header.Set("User-Agent", utils.DefaultUserAgent)
+
+	if profile != nil {
+		profile.Apply(req)
+		logger.Info("Applied request profile", "url_id", input.URLID, "apply_to_internal_links", profile.ApplyToInternalLinks, "login", profile.Login != nil)
+	}
 	
-	// Fetch the URL
-	resp, err := client.Do(req)
+	// Fetch the URL, sniffing its content type and decoding its charset
+	result, err := fetchPage(client, req, cfg.CrawlMaxBodyBytes)
+	if errors.Is(err, errBodyTooLarge) {
+		logger.Error("Response body too large", "url", input.URL, "max_bytes", cfg.CrawlMaxBodyBytes)
+		if err := repo.UpdateCrawlOutcome(ctx, input.CrawlID, OutcomeTooLarge, result.MimeType, "", result.ContentLength); err != nil {
+			logger.Error("Failed to update crawl outcome", "error", err, "crawl_id", input.CrawlID)
+		}
+		failureMessage = fmt.Sprintf("Page exceeds the maximum body size of %d bytes", cfg.CrawlMaxBodyBytes)
+		return nil, temporal.NewNonRetryableApplicationError(failureMessage, "BodyTooLarge", err)
+	}
+	if errors.Is(err, utils.ErrBlockedAddress) {
+		return nil, failBlocked(err)
+	}
+	// Retry once the host's rate limit frees a slot instead of on the regular backoff
+	var rateLimitErr *ratelimit.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		logger.Warn("Host rate limit exceeded", "host", rateLimitErr.Host, "wait", rateLimitErr.Wait, "url", input.URL)
+		return nil, temporal.NewApplicationErrorWithOptions(rateLimitErr.Error(), "RateLimited", temporal.ApplicationErrorOptions{
+			NextRetryDelay: rateLimitErr.Wait,
+			Cause:          err,
+		})
+	}
 	if err != nil {
 		logger.Error("Failed to fetch URL", "error", err, "url", input.URL)
-		return fmt.Errorf("failed to fetch URL: %w", err)
+		return nil, fmt.Errorf("failed to fetch URL: %w", err)
 	}
-	defer resp.Body.Close()
 
-	logger.Info("HTTP response received", "status_code", resp.StatusCode, "url", input.URL)
-	activity.RecordHeartbeat(ctx, "HTTP response received")
+	logger.Info("HTTP response received", "status_code", result.StatusCode, "mime_type", result.MimeType, "charset", result.Charset, "content_length", result.ContentLength, "url", input.URL)
+	recordHeartbeat(ctx, "HTTP response received")
 	
-	if resp.StatusCode != http.StatusOK {
-		logger.Error("HTTP error response", "status_code", resp.StatusCode, "url", input.URL)
-		return fmt.Errorf("HTTP error: %d", resp.StatusCode)
+	if result.StatusCode != http.StatusOK {
+		logger.Error("HTTP error response", "status_code", result.StatusCode, "url", input.URL)
+		return nil, fmt.Errorf("HTTP error: %d", result.StatusCode)
+	}
+
+	// Non-HTML resources are recorded with their type and size but not analyzed
+	if !result.IsHTML {
+		logger.Info("Resource is not HTML, skipping analysis", "mime_type", result.MimeType, "content_length", result.ContentLength)
+		if err = repo.UpdateCrawlOutcome(ctx, input.CrawlID, OutcomeNonHTML, result.MimeType, "", result.ContentLength); err != nil {
+			logger.Error("Failed to update crawl outcome", "error", err, "crawl_id", input.CrawlID)
+			return nil, fmt.Errorf("failed to update crawl outcome: %w", err)
+		}
+		if err = repo.SetCrawlDone(ctx, input.CrawlID); err != nil {
+			logger.Error("Failed to set crawl done", "error", err, "crawl_id", input.CrawlID)
+			return nil, fmt.Errorf("failed to set crawl done: %w", err)
+		}
+		fetched = true
+		logger.Info("Crawl completed successfully", "crawl_id", input.CrawlID, "url", input.URL, "outcome", OutcomeNonHTML)
+		a.Notifier.CrawlUpdated(input.UserID, input.URLID, "")
+		return nil, nil
 	}
 
+	// Archive the raw HTML exactly as fetched, deduplicated by its content hash
+	recordHeartbeat(ctx, "Archiving HTML snapshot")
+	snapshotHash, err := blobstore.PutCompressed(ctx, snapshots, result.Raw)
+	if err != nil {
+		logger.Error("Failed to archive HTML snapshot", "error", err, "crawl_id", input.CrawlID)
+		return nil, fmt.Errorf("failed to archive HTML snapshot: %w", err)
+	}
+	if err = repo.UpdateCrawlSnapshot(ctx, input.CrawlID, snapshotHash, int64(len(result.Raw)), result.Header); err != nil {
+		logger.Error("Failed to update crawl snapshot", "error", err, "crawl_id", input.CrawlID)
+		return nil, fmt.Errorf("failed to update crawl snapshot: %w", err)
+	}
+	logger.Info("HTML snapshot archived", "snapshot_hash", snapshotHash, "size", len(result.Raw))
+
 	logger.Info("Parsing HTML content")
-	activity.RecordHeartbeat(ctx, "Parsing HTML")
+	recordHeartbeat(ctx, "Parsing HTML")
 	// Parse HTML
-	doc, err := html.Parse(resp.Body)
+	doc, err := html.Parse(bytes.NewReader(result.Body))
 	if err != nil {
 		logger.Error("Failed to parse HTML", "error", err, "url", input.URL)
-		return fmt.Errorf("failed to parse HTML: %w", err)
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
 	}
-	activity.RecordHeartbeat(ctx, "HTML parsing completed")
+	recordHeartbeat(ctx, "HTML parsing completed")
 
 	
 	
 	logger.Info("Extracting page metadata")
-	activity.RecordHeartbeat(ctx, "Starting metadata extraction")
-	
-	// Extract HTML version
-	activity.RecordHeartbeat(ctx, "About to extract HTML version")
-	htmlVersion := utils.ExtractHtmlVersion(doc)
-	activity.RecordHeartbeat(ctx, "HTML version extraction completed")
-	logger.Info("HTML version extracted", "version", htmlVersion)
-	
+	recordHeartbeat(ctx, "Starting metadata extraction")
+	analysis := analyzeDocument(doc, input.URL)
+	recordHeartbeat(ctx, "Metadata extraction completed")
+	logger.Info("Page metadata extracted", "version", analysis.HtmlVersion, "title", analysis.PageTitle, "h1", analysis.H1Count, "h2", analysis.H2Count, "h3", analysis.H3Count, "h4", analysis.H4Count, "h5", analysis.H5Count, "h6", analysis.H6Count)
+	logger.Info("Form analysis completed", "forms", len(analysis.Forms), "form_types", utils.FormTypes(analysis.Forms), "has_login_form", analysis.HasLoginForm)
+	logger.Info("Content fingerprint computed", "content_hash", analysis.ContentHash, "simhash", analysis.SimHash)
 
-	// Extract page title
-	activity.RecordHeartbeat(ctx, "About to extract page title")
-	pageTitle := utils.SanitizeText(utils.ExtractTitle(doc), 500)
-	activity.RecordHeartbeat(ctx, "Page title extraction completed")
-	logger.Info("Page title extracted", "title", pageTitle)
-	
+	// The links are only extracted here, the workflow checks them in batches it can pause between
+	links := utils.ExtractLinks(doc, input.URL)
+	logger.Info("Links extracted", "total_links", len(links))
 
-	// Count headings
-	activity.RecordHeartbeat(ctx, "About to count headings")
-	headingCounts := utils.CountHeadings(doc)
-	activity.RecordHeartbeat(ctx, "Heading counting completed")
-	h1Count := int32(headingCounts["h1"])
-	h2Count := int32(headingCounts["h2"])
-	h3Count := int32(headingCounts["h3"])
-	h4Count := int32(headingCounts["h4"])
-	h5Count := int32(headingCounts["h5"])
-	h6Count := int32(headingCounts["h6"])
-	logger.Info("Heading counts extracted", "h1", h1Count, "h2", h2Count, "h3", h3Count, "h4", h4Count, "h5", h5Count, "h6", h6Count)
-		
-
-	// Count links
-	logger.Info("Analyzing links")
-	activity.RecordHeartbeat(ctx, "About to start link analysis")
-	linkAnalysis := utils.CountLinks(doc, input.URL)
-	activity.RecordHeartbeat(ctx, "Link analysis function completed")
-	linkCounts := linkAnalysis.Counts
-	internalLinksCount := int32(linkCounts["internal"])
-	externalLinksCount := int32(linkCounts["external"])
-	inaccessibleLinksCount := int32(linkCounts["inaccessible"])
-	activity.RecordHeartbeat(ctx, "Link counts processed")
-	logger.Info("Link analysis completed", "internal", internalLinksCount, "external", externalLinksCount, "inaccessible", inaccessibleLinksCount, "total_links", len(linkAnalysis.Links))
-	
-	// Skip individual link saving for now to avoid performance issues
-	// TODO: Implement efficient link checking in a separate background process
-	logger.Info("Skipping individual link saving to improve performance", "total_links", len(linkAnalysis.Links))
+	if err = repo.UpdateCrawlOutcome(ctx, input.CrawlID, OutcomeHTML, result.MimeType, result.Charset, result.ContentLength); err != nil {
+		logger.Error("Failed to update crawl outcome", "error", err, "crawl_id", input.CrawlID)
+		return nil, fmt.Errorf("failed to update crawl outcome: %w", err)
+	}
 
-	// Check for login form
-	activity.RecordHeartbeat(ctx, "Checking for login form")
-	hasLoginForm := utils.HasLoginForm(doc)
-	logger.Info("Login form analysis completed", "has_login_form", hasLoginForm)
-		
+	if err = repo.SaveCrawlForms(ctx, input.CrawlID, analysis.Forms); err != nil {
+		logger.Error("Failed to save crawl forms", "error", err, "crawl_id", input.CrawlID)
+		return nil, fmt.Errorf("failed to save crawl forms: %w", err)
+	}
 
-	logger.Info("Updating crawl results in database")
-	err = repo.UpdateCrawlResult(ctx, input.CrawlID, htmlVersion, pageTitle, h1Count, h2Count, h3Count, h4Count, h5Count, h6Count, internalLinksCount, externalLinksCount, inaccessibleLinksCount, hasLoginForm, string(db.CrawlsStatusDone))
+	if err = repo.UpdateCrawlFingerprint(ctx, input.CrawlID, analysis.ContentHash, analysis.SimHash); err != nil {
+		logger.Error("Failed to update crawl fingerprint", "error", err, "crawl_id", input.CrawlID)
+		return nil, fmt.Errorf("failed to update crawl fingerprint: %w", err)
+	}
 
-	if err != nil {
-		logger.Error("Failed to update crawl result", "error", err, "crawl_id", input.CrawlID)
-		return fmt.Errorf("failed to update crawl result: %w", err)
+	if err = repo.UpdateCrawlAnalysis(ctx, input.CrawlID, analysis, AnalysisVersion); err != nil {
+		logger.Error("Failed to update crawl analysis", "error", err, "crawl_id", input.CrawlID)
+		return nil, fmt.Errorf("failed to update crawl analysis: %w", err)
 	}
 
-	// Mark crawl as completed successfully
-	crawlCompleted = true
-	logger.Info("Crawl completed successfully", "crawl_id", input.CrawlID, "url", input.URL)
-	// Notify SSE that crawl completed successfully
-	NotifyCrawlUpdateHTTP(input.UserID, input.URLID)
+	fetched = true
+	logger.Info("Page fetched", "crawl_id", input.CrawlID, "url", input.URL, "links", len(links))
+	a.Notifier.CrawlProgress(input.UserID, input.URLID, CrawlProgress{CrawlID: input.CrawlID, Phase: CrawlPhaseCheckingLinks, LinksTotal: len(links)}.WithPercent())
+	a.Notifier.CrawlUpdated(input.UserID, input.URLID, "")
+
+	return &PageFetchResult{
+		SnapshotHash: snapshotHash,
+		ContentType:  result.Header.Get("Content-Type"),
+		LinkCount:    len(links),
+		BatchSize:    max(cfg.LinkCheckBatchSize, 1),
+		MaxPause:     cfg.CrawlMaxPause,
+	}, nil
+}
+
+// CrawlURLActivity fetches the page and checks all of its links in a single activity, it is only run by crawl
+// workflows started before the links were checked in batches and can be removed once they have finished
+func (a *Activities) CrawlURLActivity(ctx context.Context, input WorlFlowInput) error {
+	page, err := a.FetchPageActivity(ctx, input)
+	if err != nil || page == nil {
+		return err
+	}
 
-	return nil
+	err = a.checkAllLinks(ctx, input, page)
+	if err != nil {
+		// The attempt leaves the crawl in error like a failed fetch, a retry moves it to running again
+		if statusErr := a.SetCrawlStatusActivity(ctx, input, string(db.CrawlsStatusError), fmt.Sprintf("Crawl failed to complete: %v", err)); statusErr != nil {
+			activityLogger(ctx).Error("Failed to record crawl error", "error", statusErr, "crawl_id", input.CrawlID)
+		}
+	}
+	return err
+}
+
+// checkAllLinks checks every batch of links of the fetched page and finalizes the crawl
+func (a *Activities) checkAllLinks(ctx context.Context, input WorlFlowInput, page *PageFetchResult) error {
+	var results LinkCheckResults
+	for offset := 0; offset < page.LinkCount; offset += page.BatchSize {
+		batch, err := a.CheckLinkBatchActivity(ctx, LinkBatchInput{Crawl: input, Page: *page, Offset: offset})
+		if err != nil {
+			return err
+		}
+		results.Add(batch)
+	}
+	return a.FinalizeCrawlActivity(ctx, input, results)
 }
 
-func keepAlive(ctx context.Context, interval time.Duration) (stop func()) {
+// keepAlive records a heartbeat with the current details every interval until it is stopped
+func keepAlive(ctx context.Context, interval time.Duration, details func() interface{}) (stop func()) {
 	done := make(chan struct{})
 	go func() {
 		ticker := time.NewTicker(interval)
@@ -203,7 +312,7 @@ func keepAlive(ctx context.Context, interval time.Duration) (stop func()) {
 		for {
 			select {
 			case <-ticker.C:
-				activity.RecordHeartbeat(ctx, "Crawl still in progress")
+				recordHeartbeat(ctx, details())
 			case <-ctx.Done():
 				return
 			case <-done:
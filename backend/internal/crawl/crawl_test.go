@@ -0,0 +1,196 @@
+package crawl
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sykell-backend/internal/db"
+	"sykell-backend/internal/ratelimit"
+	"sykell-backend/internal/utils"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"golang.org/x/net/html"
+)
+
+func newCrawlActivityEnvironment(a *Activities) (*testsuite.TestActivityEnvironment, func() []string) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(a)
+
+	var mu sync.Mutex
+	var heartbeats []string
+	env.SetOnActivityHeartbeatListener(func(info *activity.Info, details converter.EncodedValues) {
+		var detail string
+		if details.HasValues() && details.Get(&detail) == nil {
+			mu.Lock()
+			heartbeats = append(heartbeats, detail)
+			mu.Unlock()
+		}
+	})
+	return env, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), heartbeats...)
+	}
+}
+
+func TestFetchPageActivityHeartbeats(t *testing.T) {
+	site := newFixtureSite(t)
+	a, repo, notifier := newTestActivities(t)
+	env, heartbeats := newCrawlActivityEnvironment(a)
+
+	value, err := env.ExecuteActivity(a.FetchPageActivity, crawlInput(site, "/"))
+	require.NoError(t, err)
+	var page *PageFetchResult
+	require.NoError(t, value.Get(&page))
+	require.NotNil(t, page)
+	assert.Equal(t, 3, page.LinkCount)
+	assert.Equal(t, 2, page.BatchSize)
+
+	// The fetch heartbeats once the response arrived, the SDK throttles the heartbeats of the later steps
+	assert.Contains(t, heartbeats(), "HTTP response received")
+	assert.Equal(t, string(db.CrawlsStatusRunning), repo.Status())
+	assert.Equal(t, []string{"", ""}, notifier.updates)
+}
+
+func TestFetchPageActivityRecordsErrorStatus(t *testing.T) {
+	site := newFixtureSite(t)
+	a, repo, notifier := newTestActivities(t)
+	env, _ := newCrawlActivityEnvironment(a)
+
+	_, err := env.ExecuteActivity(a.FetchPageActivity, crawlInput(site, "/missing"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "HTTP error: 404")
+
+	// The attempt leaves the crawl in error, a retry that succeeds moves it to running again
+	assert.Equal(t, []string{"running", "error"}, repo.Statuses())
+	assert.Equal(t, "Crawl failed to complete (timeout, error, or cancellation)", repo.errorMessage)
+	assert.Len(t, notifier.updates, 2)
+}
+
+func TestSetCrawlStatusActivity(t *testing.T) {
+	a, repo, notifier := newTestActivities(t)
+	env, _ := newCrawlActivityEnvironment(a)
+
+	_, err := env.ExecuteActivity(a.SetCrawlStatusActivity, WorlFlowInput{CrawlID: "crawl-1"}, string(db.CrawlsStatusError), "Crawl failed to complete")
+	require.NoError(t, err)
+	assert.Equal(t, string(db.CrawlsStatusError), repo.Status())
+	assert.Equal(t, "Crawl failed to complete", repo.errorMessage)
+	assert.Equal(t, []string{string(db.CrawlsStatusError)}, notifier.updates)
+
+	// Statuses the workflow never sets are rejected without retries
+	_, err = env.ExecuteActivity(a.SetCrawlStatusActivity, WorlFlowInput{CrawlID: "crawl-1"}, string(db.CrawlsStatusDone), "")
+	var appErr *temporal.ApplicationError
+	require.ErrorAs(t, err, &appErr)
+	assert.True(t, appErr.NonRetryable())
+}
+
+func TestCheckLinkBatchActivityResumesFromHeartbeat(t *testing.T) {
+	site := newFixtureSite(t)
+	a, _, _ := newTestActivities(t)
+	env, _ := newCrawlActivityEnvironment(a)
+
+	value, err := env.ExecuteActivity(a.FetchPageActivity, crawlInput(site, "/"))
+	require.NoError(t, err)
+	var page *PageFetchResult
+	require.NoError(t, value.Get(&page))
+	require.NotNil(t, page)
+
+	// The previous attempt checked the first link of the batch before the worker shut down
+	doc, err := html.Parse(strings.NewReader(fixtureHome))
+	require.NoError(t, err)
+	links := utils.ExtractLinks(doc, site.URL+"/")
+	require.GreaterOrEqual(t, len(links), 2)
+	checked := links[0]
+	require.Equal(t, "/about", checked.Href)
+	status := http.StatusOK
+	checked.StatusCode = &status
+	checked.Reason = utils.LinkReasonOK
+	checkedURL, err := url.Parse(checked.AbsoluteURL)
+	require.NoError(t, err)
+	hits := site.Hits(checkedURL.Path)
+
+	env.SetHeartbeatDetails(linkBatchCheckpoint{Offset: 0, Checked: []utils.LinkInfo{checked}})
+	value, err = env.ExecuteActivity(a.CheckLinkBatchActivity, LinkBatchInput{Crawl: crawlInput(site, "/"), Page: *page, Offset: 0})
+	require.NoError(t, err)
+	var results LinkCheckResults
+	require.NoError(t, value.Get(&results))
+
+	// Only the links after the checkpoint are requested, the checked link is counted from the checkpoint
+	assert.Equal(t, hits, site.Hits(checkedURL.Path))
+	assert.Positive(t, site.Hits("/missing"))
+	assert.Equal(t, 1, results.Counts.Inaccessible)
+	require.Len(t, results.InaccessibleLinks, 1)
+	assert.Equal(t, "/missing", results.InaccessibleLinks[0].Href)
+}
+
+func TestLinkBatchCheckpointResume(t *testing.T) {
+	batch := []utils.LinkInfo{
+		{Href: "/a", AbsoluteURL: "https://example.com/a"},
+		{Href: "/b", AbsoluteURL: "https://example.com/b"},
+	}
+	status := http.StatusOK
+	checked := utils.LinkInfo{Href: "/a", AbsoluteURL: "https://example.com/a", StatusCode: &status}
+
+	checkpoint := linkBatchCheckpoint{Offset: 10, Checked: []utils.LinkInfo{checked}}
+	assert.Equal(t, 1, checkpoint.resume(10, batch))
+	assert.Equal(t, &status, batch[0].StatusCode)
+
+	// Checkpoints of another batch or of other links are ignored
+	assert.Equal(t, 0, checkpoint.resume(20, batch))
+	other := linkBatchCheckpoint{Offset: 10, Checked: []utils.LinkInfo{{Href: "/c", AbsoluteURL: "https://example.com/c"}}}
+	assert.Equal(t, 0, other.resume(10, batch))
+	tooLong := linkBatchCheckpoint{Offset: 10, Checked: append(batch, checked)}
+	assert.Equal(t, 0, tooLong.resume(10, batch))
+}
+
+// busyRateLimits has no free slot for the first requests it is asked for
+type busyRateLimits struct {
+	noRateLimits
+	mu   sync.Mutex
+	busy int
+}
+
+func (r *busyRateLimits) Reserve(ctx context.Context, host string, nowMs int64, interval time.Duration, burst int, maxWait time.Duration) (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.busy > 0 {
+		r.busy--
+		return 0, &ratelimit.RateLimitError{Host: host, Wait: 10 * time.Millisecond}
+	}
+	return 0, nil
+}
+
+func TestCheckLinkBatchActivityWaitsForRateLimit(t *testing.T) {
+	site := newFixtureSite(t)
+	a, _, _ := newTestActivities(t)
+	env, _ := newCrawlActivityEnvironment(a)
+
+	value, err := env.ExecuteActivity(a.FetchPageActivity, crawlInput(site, "/"))
+	require.NoError(t, err)
+	var page *PageFetchResult
+	require.NoError(t, value.Get(&page))
+	require.NotNil(t, page)
+
+	// The first link finds its host without a free slot, it is checked again instead of counted as inaccessible
+	limits := &busyRateLimits{busy: 1}
+	a.RateLimits = limits
+	value, err = env.ExecuteActivity(a.CheckLinkBatchActivity, LinkBatchInput{Crawl: crawlInput(site, "/"), Page: *page, Offset: 0})
+	require.NoError(t, err)
+	var results LinkCheckResults
+	require.NoError(t, value.Get(&results))
+
+	assert.Zero(t, limits.busy)
+	assert.Equal(t, 1, results.Counts.Inaccessible)
+	require.Len(t, results.InaccessibleLinks, 1)
+	assert.Equal(t, "/missing", results.InaccessibleLinks[0].Href)
+}
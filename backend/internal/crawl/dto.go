@@ -1,11 +1,42 @@
 package crawl
 
-import "time"
+import (
+	"sykell-backend/internal/quota"
+	"sykell-backend/internal/utils"
+	"time"
+)
 
 // Constants for crawl workflow configuration
 const (
 	TaskQueueName = "crawl-task-queue"
+	ScheduledTaskQueueName = "crawl-scheduled-task-queue"
+	BackfillTaskQueueName = "crawl-backfill-task-queue"
 	WorkflowName  = "CrawlWorkflow"
+	ReanalyzeWorkflowName = "ReanalyzeWorkflow"
+	LinkRecheckWorkflowName = "LinkRecheckWorkflow"
+	BulkCrawlWorkflowName = "BulkCrawlWorkflow"
+	BulkProgressQueryName = "progress"
+	ReconcileWorkflowName = "ReconcileWorkflow"
+	ReconcileWorkflowID = "crawl-reconciler"
+	ReconcileReportQueryName = "report"
+	CrawlProgressQueryName = "crawl_progress"
+	PauseSignalName = "pause"
+	ResumeSignalName = "resume"
+)
+
+// Crawl outcomes describing what was found at the crawled URL
+const (
+	OutcomeHTML     = "html"      // An HTML page that was parsed and analyzed
+	OutcomeNonHTML  = "non_html"  // A resource of another type that was only measured
+	OutcomeTooLarge = "too_large" // A page larger than the configured maximum body size
+	OutcomeBlocked  = "blocked"   // A page that resolved to a private or internal address
+)
+
+// Crawl priorities, each priority has its own task queue so crawls of a lower priority never delay the others
+const (
+	PriorityInteractive = "interactive" // Crawls started by the user from the dashboard
+	PriorityScheduled   = "scheduled"   // Crawls started by a scheduler and link rechecks
+	PriorityBackfill    = "backfill"    // Bulk crawls and re-analysis
 )
 
 // WorlFlowInput represents the input parameters for the crawl workflow
@@ -20,16 +51,20 @@ type WorlFlowInput struct {
 
 // SSENotification represents a simple notification to invalidate queries
 type SSENotification struct {
-	Type      string    `json:"type"`      // "crawl_update"
-	URLID     string    `json:"url_id"`    // URL ID that needs to be refetched
-	UserID    string    `json:"user_id"`   // User ID (for verification)
-	Timestamp time.Time `json:"timestamp"`
+	Type      string         `json:"type"`               // "crawl_update" or "crawl_progress"
+	URLID     string         `json:"url_id"`             // URL ID that needs to be refetched
+	UserID    string         `json:"user_id"`            // User ID (for verification)
+	Status    string         `json:"status,omitempty"`   // New status of the crawl, when the update is a status change
+	Progress  *CrawlProgress `json:"progress,omitempty"` // Progress of the crawl, for "crawl_progress" events
+	Timestamp time.Time      `json:"timestamp"`
 }
 
 // NotificationRequest represents the payload for internal notification requests
 type NotificationRequest struct {
-	UserID string `json:"user_id"`
-	URLID  string `json:"url_id"`
+	UserID   string         `json:"user_id"`
+	URLID    string         `json:"url_id"`
+	Status   string         `json:"status,omitempty"`
+	Progress *CrawlProgress `json:"progress,omitempty"`
 }
 
 // URLResponse represents the response structure for URL data
@@ -43,4 +78,335 @@ type URLResponse struct {
 type CrawlResponse struct {
 	ID string `json:"id"`
 	WorkflowID string `json:"workflow_id"`	
-}
\ No newline at end of file
+	Status string `json:"status"`
+}
+
+// FormResponse represents a form found on a crawled page
+type FormResponse struct {
+	CrawlID    string            `json:"crawl_id"`
+	Type       string            `json:"type"`
+	Method     string            `json:"method"`
+	Action     string            `json:"action"`
+	Fields     []utils.FormField `json:"fields"`
+	IsHTTPS    bool              `json:"is_https"`
+	IsOffSite  bool              `json:"is_off_site"`
+	IsInsecure bool              `json:"is_insecure"`
+	IsFlagged  bool              `json:"is_flagged"`
+}
+
+// SnapshotResponse describes the archived raw HTML of a crawl
+type SnapshotResponse struct {
+	CrawlID   string              `json:"crawl_id"`
+	UrlID     string              `json:"url_id"`
+	Hash      string              `json:"hash"`
+	Size      int64               `json:"size"`
+	MimeType  string              `json:"mime_type"`
+	Charset   string              `json:"charset"`
+	Headers   map[string][]string `json:"headers"`
+	FetchedAt *time.Time          `json:"fetched_at"`
+}
+
+// ReanalysisCandidate represents an archived crawl whose snapshot can be re-analyzed
+type ReanalysisCandidate struct {
+	CrawlID      string `json:"crawl_id"`
+	URL          string `json:"url"`
+	SnapshotHash string `json:"snapshot_hash"`
+	ContentType  string `json:"content_type"`
+}
+
+// ReanalyzeInput represents the input parameters for the re-analysis workflow, it also carries
+// the progress of the backfill across continue-as-new runs
+type ReanalyzeInput struct {
+	UserID        string        `json:"user_id"`             // Owner of the crawls, empty for every user
+	CrawlIDs      []string      `json:"crawl_ids,omitempty"` // Selected crawls, empty for every archived crawl
+	TargetVersion int           `json:"target_version"`
+	BatchSize     int           `json:"batch_size"`
+	BatchDelay    time.Duration `json:"batch_delay"`
+	Cursor        string        `json:"cursor,omitempty"` // Last crawl ID handled when every crawl is re-analyzed
+	Offset        int           `json:"offset,omitempty"` // Number of selected crawls handled
+	Processed     int           `json:"processed"`
+	Skipped       int           `json:"skipped"`
+	Failed        int           `json:"failed"`
+}
+
+// ReanalyzeBatchInput represents a single batch of crawls to re-analyze
+type ReanalyzeBatchInput struct {
+	UserID        string   `json:"user_id"`
+	CrawlIDs      []string `json:"crawl_ids,omitempty"`
+	TargetVersion int      `json:"target_version"`
+	Cursor        string   `json:"cursor,omitempty"`
+	Limit         int      `json:"limit"`
+}
+
+// ReanalyzeBatchResult represents the outcome of a re-analysis batch
+type ReanalyzeBatchResult struct {
+	Processed  int    `json:"processed"`
+	Skipped    int    `json:"skipped"`
+	Failed     int    `json:"failed"`
+	NextCursor string `json:"next_cursor"` // Empty when no archived crawls are left after this batch
+}
+
+// ReanalyzeResult represents the totals of a completed re-analysis workflow
+type ReanalyzeResult struct {
+	Processed int `json:"processed"`
+	Skipped   int `json:"skipped"`
+	Failed    int `json:"failed"`
+}
+
+// ReanalyzeRequest represents the request body for starting a re-analysis
+type ReanalyzeRequest struct {
+	CrawlIDs          []string `json:"crawl_ids"`
+	BatchSize         int      `json:"batch_size"`
+	BatchDelaySeconds int      `json:"batch_delay_seconds"`
+}
+
+// ReanalyzeResponse represents the response structure for a started re-analysis
+type ReanalyzeResponse struct {
+	WorkflowID    string `json:"workflow_id"`
+	RunID         string `json:"run_id"`
+	TargetVersion int    `json:"target_version"`
+}
+
+// LinkCacheStatsResponse represents how often link statuses were served from the cache instead of being checked
+type LinkCacheStatsResponse struct {
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// StoredRequestProfile represents a URL's request profile as stored, with its secrets still encrypted
+type StoredRequestProfile struct {
+	UrlID                string
+	UserAgent            string
+	BasicAuthUsername    string
+	EncryptedSecrets     string
+	ApplyToInternalLinks bool
+	LoginURL             string
+	LoginUsernameField   string
+	LoginPasswordField   string
+	LoginUsername        string
+}
+
+// PendingLink represents an inaccessible link of a crawl that is not yet confirmed broken
+type PendingLink struct {
+	ID                  string `json:"id"`
+	AbsoluteURL         string `json:"absolute_url"`
+	IsInternal          bool   `json:"is_internal"`
+	Occurrences         int    `json:"occurrences"`          // How many times the page links to the URL
+	ConsecutiveFailures int    `json:"consecutive_failures"` // Failed checks so far, including the crawl itself
+	RecheckRound        int    `json:"recheck_round"`        // Recheck round its last failure was recorded in
+}
+
+// LinkRecheckInput represents the input parameters for the link recheck workflow
+type LinkRecheckInput struct {
+	CrawlID       string        `json:"crawl_id"`
+	URLID         string        `json:"url_id"`
+	UserID        string        `json:"user_id"`
+	Delay         time.Duration `json:"delay"`         // Wait before the first recheck, doubled after every round
+	MaxDelay      time.Duration `json:"max_delay"`
+	Confirmations int           `json:"confirmations"` // Consecutive failures after which a link is confirmed broken
+	Round         int           `json:"round"`         // Recheck round run by the activity, starting at 1
+	Final         bool          `json:"final"`         // Whether it is the last round, links still pending are confirmed
+}
+
+// LinkRecheckResult represents the outcome of a recheck round, or the totals of the recheck workflow
+type LinkRecheckResult struct {
+	Recovered int `json:"recovered"` // Links that turned out to be accessible
+	Confirmed int `json:"confirmed"` // Links confirmed broken
+	Pending   int `json:"pending"`   // Links that still need another recheck
+}
+
+// Bulk crawl actions
+const (
+	BulkActionStart = "start"
+	BulkActionStop  = "stop"
+)
+
+// BulkCrawlFilter selects the URLs of a bulk action with the same query and sort as the URL list
+type BulkCrawlFilter struct {
+	Query     string `json:"query"`
+	SortBy    string `json:"sort_by"`
+	SortOrder string `json:"sort_order"`
+}
+
+// BulkCrawlRequest represents the request body for starting or stopping the crawls of many URLs,
+// either URLIDs or Filter must be given
+type BulkCrawlRequest struct {
+	URLIDs      []string         `json:"url_ids"`
+	Filter      *BulkCrawlFilter `json:"filter"`
+	Parallelism int              `json:"parallelism"`
+	Priority    string           `json:"priority"` // "scheduled" or "backfill", backfill by default
+}
+
+// BulkCrawlResponse represents the response structure for a started bulk action
+type BulkCrawlResponse struct {
+	BatchID string `json:"batch_id"`
+	Action  string `json:"action"`
+	Total   int    `json:"total"`
+}
+
+// BulkCrawlInput represents the input parameters for the bulk crawl workflow
+type BulkCrawlInput struct {
+	BatchID     string   `json:"batch_id"`
+	UserID      string   `json:"user_id"`
+	Action      string   `json:"action"`
+	URLIDs      []string `json:"url_ids"`
+	Parallelism int      `json:"parallelism"`
+	Priority    string   `json:"priority"`
+}
+
+// BulkCrawlItem represents a single URL of a bulk action, handled by a bulk activity
+type BulkCrawlItem struct {
+	UserID     string `json:"user_id"`
+	URLID      string `json:"url_id"`
+	WorkflowID string `json:"workflow_id,omitempty"` // Workflow ID of the crawl started for the URL
+	Priority   string `json:"priority,omitempty"`
+}
+
+// BulkCrawlProgress represents the progress of a bulk action, returned by the workflow's progress query
+type BulkCrawlProgress struct {
+	BatchID   string `json:"batch_id"`
+	UserID    string `json:"user_id"`
+	Action    string `json:"action"`
+	Total     int    `json:"total"`
+	Running   int    `json:"running"`   // Crawls started and not finished yet
+	Completed int    `json:"completed"` // Crawls finished successfully
+	Stopped   int    `json:"stopped"`   // Crawls stopped by the batch or by the user
+	Skipped   int    `json:"skipped"`   // URLs not found, already being crawled or without an active crawl to stop
+	Failed    int    `json:"failed"`
+	Done      bool   `json:"done"`
+}
+
+// PageFetchResult describes the fetched page of a crawl whose links are checked in batches,
+// the links are extracted again from the archived snapshot by every batch
+type PageFetchResult struct {
+	SnapshotHash string        `json:"snapshot_hash"`
+	ContentType  string        `json:"content_type"` // Content-Type header the snapshot was served with
+	LinkCount    int           `json:"link_count"`
+	BatchSize    int           `json:"batch_size"`
+	MaxPause     time.Duration `json:"max_pause"` // How long the crawl may stay paused before it is stopped
+}
+
+// LinkBatchInput represents a batch of the links of a crawled page to check
+type LinkBatchInput struct {
+	Crawl  WorlFlowInput   `json:"crawl"`
+	Page   PageFetchResult `json:"page"`
+	Offset int             `json:"offset"`
+}
+
+// LinkCounts holds the number of links of a crawl in each category
+type LinkCounts struct {
+	Internal     int `json:"internal"`
+	External     int `json:"external"`
+	Inaccessible int `json:"inaccessible"`
+	BrokenAnchor int `json:"broken_anchor"`
+}
+
+// LinkCheckResults holds the results of checked links, accumulated by the crawl workflow across batches
+type LinkCheckResults struct {
+	Counts            LinkCounts       `json:"counts"`
+	InaccessibleLinks []utils.LinkInfo `json:"inaccessible_links"` // Inaccessible links that are not broken anchors
+	CacheHits         int              `json:"cache_hits"`
+	CacheMisses       int              `json:"cache_misses"`
+}
+
+// Add accumulates the results of another batch
+func (r *LinkCheckResults) Add(batch LinkCheckResults) {
+	r.Counts.Internal += batch.Counts.Internal
+	r.Counts.External += batch.Counts.External
+	r.Counts.Inaccessible += batch.Counts.Inaccessible
+	r.Counts.BrokenAnchor += batch.Counts.BrokenAnchor
+	r.InaccessibleLinks = append(r.InaccessibleLinks, batch.InaccessibleLinks...)
+	r.CacheHits += batch.CacheHits
+	r.CacheMisses += batch.CacheMisses
+}
+
+// Phases of a crawl reported by its progress
+const (
+	CrawlPhaseQueued        = "queued"
+	CrawlPhaseFetching      = "fetching"
+	CrawlPhaseCheckingLinks = "checking_links"
+	CrawlPhasePaused        = "paused"
+	CrawlPhaseFinalizing    = "finalizing"
+	CrawlPhaseDone          = "done"
+)
+
+// CrawlProgress represents the progress of a crawl, returned by the crawl workflow's progress query
+// and streamed over SSE while its links are checked
+type CrawlProgress struct {
+	CrawlID      string `json:"crawl_id"`
+	Phase        string `json:"phase"`
+	LinksChecked int    `json:"links_checked"`
+	LinksTotal   int    `json:"links_total"`
+	Percent      int    `json:"percent"`
+}
+
+// WithPercent returns the progress with its percentage computed from the phase and the checked links
+func (p CrawlProgress) WithPercent() CrawlProgress {
+	switch {
+	case p.Phase == CrawlPhaseDone:
+		p.Percent = 100
+	case p.LinksTotal > 0:
+		p.Percent = min(p.LinksChecked*100/p.LinksTotal, 99)
+	case p.Phase == CrawlPhaseFinalizing:
+		p.Percent = 99
+	default:
+		p.Percent = 0
+	}
+	return p
+}
+
+// ReconcileInput represents the input parameters for the reconcile workflow
+type ReconcileInput struct {
+	Interval time.Duration `json:"interval"` // Time between two passes
+}
+
+// ReconcileReport represents the outcome of a reconcile pass, returned by the workflow's report query
+type ReconcileReport struct {
+	Checked    int       `json:"checked"`
+	Repaired   int       `json:"repaired"` // Crawls moved to the status of their workflow
+	Canceled   int       `json:"canceled"` // Workflows of stopped crawls that were still running
+	Failed     int       `json:"failed"`   // Crawls that could not be checked or repaired, retried in the next pass
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// QueuedCrawl describes a crawl to queue and the limits it is checked against while the crawls of the user are locked
+type QueuedCrawl struct {
+	CrawlID     string
+	URLID       string
+	UserID      string
+	WorkflowID  string
+	Priority    string
+	ActiveLimit int            // Active crawls of the priority the user can have, 0 means no limit
+	Quotas      *quota.Service // Checks the crawl against the user's plan, the plan is not enforced when nil
+}
+
+// ReconcileCandidate represents an active or recently stopped crawl checked against the state of its workflow
+type ReconcileCandidate struct {
+	CrawlID    string
+	URLID      string
+	UserID     string
+	Status     string
+	WorkflowID string
+}
+
+// CrawlRepair describes how the reconciler repairs a crawl whose row does not match its workflow
+type CrawlRepair struct {
+	NewStatus      string
+	WorkflowStatus string // Empty when the workflow does not exist
+	Reason         string // Saved as the error message of the crawl
+	CancelWorkflow bool   // The workflow of a stopped crawl is canceled instead of changing the crawl
+}
+
+// CrawlRepairResponse represents the response structure for a repair made by the reconciler
+type CrawlRepairResponse struct {
+	ID             string    `json:"id"`
+	CrawlID        string    `json:"crawl_id"`
+	URLID          string    `json:"url_id"`
+	URL            string    `json:"url"`
+	PreviousStatus string    `json:"previous_status"`
+	NewStatus      string    `json:"new_status"`
+	WorkflowStatus string    `json:"workflow_status,omitempty"`
+	Reason         string    `json:"reason"`
+	RepairedAt     time.Time `json:"repaired_at"`
+}
@@ -0,0 +1,187 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"sykell-backend/internal/config"
+	"sykell-backend/internal/temporal"
+	"time"
+
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+)
+
+// Crawl executors selected by CRAWL_EXECUTOR
+const (
+	ExecutorTemporal = "temporal"
+	ExecutorLocal    = "local"
+)
+
+// crawlStartDelay is how long a crawl waits before it starts, so the SSE connection of the dashboard is ready
+const crawlStartDelay = 3 * time.Second
+
+// startUnconfirmed reports whether a crawl whose start failed with the error may have started anyway, e.g. the
+// request timed out after Temporal received it. Such crawls are left queued for the reconciler
+func startUnconfirmed(err error) bool {
+	var deadlineExceeded *serviceerror.DeadlineExceeded
+	var canceled *serviceerror.Canceled
+	var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) ||
+		errors.As(err, &deadlineExceeded) || errors.As(err, &canceled) || errors.As(err, &alreadyStarted)
+}
+
+// Executor runs the crawls queued by CrawlService, crawls are identified by their workflow ID
+type Executor interface {
+	// Ready returns an error when no crawl can be started, nothing is queued then
+	Ready() error
+	// CrawlWorkflowID returns the workflow ID of a new crawl of the URL, the suffix makes it unique
+	CrawlWorkflowID(urlID string, suffix string) string
+	// Start runs the queued crawl with the given priority
+	Start(ctx context.Context, input WorlFlowInput, priority string) error
+	// Stop cancels the crawl
+	Stop(ctx context.Context, workflowID string) error
+	// Signal sends the pause or resume signal to the crawl
+	Signal(ctx context.Context, workflowID string, signalName string) error
+	// Progress returns the progress of the running crawl
+	Progress(ctx context.Context, workflowID string) (CrawlProgress, error)
+	// StartBulk runs the bulk action of the batch, identified by its batch ID
+	StartBulk(ctx context.Context, input BulkCrawlInput, priority string) error
+	// BulkProgress returns the progress of the batch, ErrBatchNotFound when there is no such batch
+	BulkProgress(ctx context.Context, batchID string) (BulkCrawlProgress, error)
+	// StartReanalysis runs the re-analysis under the workflow ID and returns the ID of its run, it fails with
+	// ErrReanalysisRunning while another re-analysis with the same ID is still running
+	StartReanalysis(ctx context.Context, workflowID string, input ReanalyzeInput) (string, error)
+}
+
+// newExecutor returns the executor selected by the configuration, Temporal unless the local executor is chosen,
+// which runs the crawls with the activities until ctx is done
+func newExecutor(ctx context.Context, cfg *config.Config, temporalService *temporal.Service, activities *Activities) Executor {
+	if cfg != nil && cfg.CrawlExecutor == ExecutorLocal {
+		return newLocalExecutor(ctx, cfg, activities)
+	}
+	return &temporalExecutor{temporalService: temporalService}
+}
+
+// temporalExecutor runs every crawl as a workflow on the Temporal workers
+type temporalExecutor struct {
+	temporalService *temporal.Service
+}
+
+// Ready returns temporal.ErrUnavailable when the Temporal server cannot be reached
+func (e *temporalExecutor) Ready() error {
+	_, err := e.temporalService.Client()
+	return err
+}
+
+// CrawlWorkflowID returns the ID of the crawl workflow
+func (e *temporalExecutor) CrawlWorkflowID(urlID string, suffix string) string {
+	return "crawl_" + urlID + "_" + suffix
+}
+
+// Start starts the crawl workflow on the task queue of its priority
+func (e *temporalExecutor) Start(ctx context.Context, input WorlFlowInput, priority string) error {
+	temporalClient, err := e.temporalService.Client()
+	if err != nil {
+		return err
+	}
+
+	// No execution timeout, a paused crawl waits for at most CRAWL_MAX_PAUSE and every activity has its own timeouts
+	workflowOptions := client.StartWorkflowOptions{
+		ID:                  input.WorkflowID,
+		TaskQueue:           TaskQueueForPriority(priority),
+		WorkflowTaskTimeout: time.Minute,
+		StartDelay:          crawlStartDelay,
+	}
+	_, err = temporalClient.ExecuteWorkflow(ctx, workflowOptions, WorkflowName, input)
+	return err
+}
+
+// Stop cancels the crawl workflow
+func (e *temporalExecutor) Stop(ctx context.Context, workflowID string) error {
+	temporalClient, err := e.temporalService.Client()
+	if err != nil {
+		return err
+	}
+	return temporalClient.CancelWorkflow(ctx, workflowID, "")
+}
+
+// Signal sends the signal to the crawl workflow
+func (e *temporalExecutor) Signal(ctx context.Context, workflowID string, signalName string) error {
+	temporalClient, err := e.temporalService.Client()
+	if err != nil {
+		return err
+	}
+	return temporalClient.SignalWorkflow(ctx, workflowID, "", signalName, nil)
+}
+
+// Progress queries the progress of the crawl workflow
+func (e *temporalExecutor) Progress(ctx context.Context, workflowID string) (CrawlProgress, error) {
+	var progress CrawlProgress
+	temporalClient, err := e.temporalService.Client()
+	if err != nil {
+		return progress, err
+	}
+	value, err := temporalClient.QueryWorkflow(ctx, workflowID, "", CrawlProgressQueryName)
+	if err != nil {
+		return progress, err
+	}
+	err = value.Get(&progress)
+	return progress, err
+}
+
+// StartBulk starts the bulk crawl workflow of the batch on the task queue of its priority
+func (e *temporalExecutor) StartBulk(ctx context.Context, input BulkCrawlInput, priority string) error {
+	temporalClient, err := e.temporalService.Client()
+	if err != nil {
+		return err
+	}
+	workflowOptions := client.StartWorkflowOptions{
+		ID:        input.BatchID,
+		TaskQueue: TaskQueueForPriority(priority),
+	}
+	_, err = temporalClient.ExecuteWorkflow(ctx, workflowOptions, BulkCrawlWorkflowName, input)
+	return err
+}
+
+// BulkProgress queries the progress of the bulk crawl workflow, any other workflow is not a batch
+func (e *temporalExecutor) BulkProgress(ctx context.Context, batchID string) (BulkCrawlProgress, error) {
+	var progress BulkCrawlProgress
+	temporalClient, err := e.temporalService.Client()
+	if err != nil {
+		return progress, err
+	}
+	var notFound *serviceerror.NotFound
+	description, err := temporalClient.DescribeWorkflowExecution(ctx, batchID, "")
+	if errors.As(err, &notFound) {
+		return progress, ErrBatchNotFound
+	}
+	if err != nil {
+		return progress, err
+	}
+	if description.GetWorkflowExecutionInfo().GetType().GetName() != BulkCrawlWorkflowName {
+		return progress, ErrBatchNotFound
+	}
+
+	value, err := temporalClient.QueryWorkflow(ctx, batchID, "", BulkProgressQueryName)
+	if err != nil {
+		if errors.As(err, &notFound) {
+			return progress, ErrBatchNotFound
+		}
+		return progress, err
+	}
+	err = value.Get(&progress)
+	return progress, err
+}
+
+// StartReanalysis starts the re-analysis workflow
+func (e *temporalExecutor) StartReanalysis(ctx context.Context, workflowID string, input ReanalyzeInput) (string, error) {
+	temporalClient, err := e.temporalService.Client()
+	if err != nil {
+		return "", err
+	}
+	run, err := StartReanalysisWorkflow(ctx, temporalClient, workflowID, input)
+	if err != nil {
+		return "", err
+	}
+	return run.GetRunID(), nil
+}
@@ -0,0 +1,675 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sykell-backend/internal/config"
+	"sykell-backend/internal/db"
+	"sykell-backend/internal/logger"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.temporal.io/sdk/temporal"
+	"go.uber.org/zap"
+)
+
+// ErrCrawlNotRunning is returned by the local executor for a crawl it is not running, e.g. after a restart
+var ErrCrawlNotRunning = errors.New("crawl is not running")
+
+// localRetryPolicy is how an activity is retried by the local executor
+type localRetryPolicy struct {
+	InitialInterval    time.Duration
+	BackoffCoefficient float64
+	MaximumInterval    time.Duration
+	MaximumAttempts    int
+	AttemptTimeout     time.Duration
+}
+
+// localActivityPolicy matches the activity options of the crawl and link recheck workflows
+var localActivityPolicy = localRetryPolicy{
+	InitialInterval:    time.Second,
+	BackoffCoefficient: 2.0,
+	MaximumInterval:    time.Minute,
+	MaximumAttempts:    3,
+	AttemptTimeout:     10 * time.Minute,
+}
+
+// localBulkQueuePolicy matches the retries of QueueBulkCrawlActivity in BulkCrawlWorkflow, it is retried while
+// the user has too many active crawls
+var localBulkQueuePolicy = localRetryPolicy{
+	InitialInterval:    10 * time.Second,
+	BackoffCoefficient: 2.0,
+	MaximumInterval:    time.Minute,
+	AttemptTimeout:     time.Minute,
+}
+
+// localReanalyzePolicy matches the activity options of ReanalyzeWorkflow
+var localReanalyzePolicy = localRetryPolicy{
+	InitialInterval:    time.Second,
+	BackoffCoefficient: 2.0,
+	MaximumInterval:    time.Minute,
+	MaximumAttempts:    5,
+	AttemptTimeout:     10 * time.Minute,
+}
+
+// localBatchRetention is how long the progress of a finished batch can still be queried
+const localBatchRetention = 24 * time.Hour
+
+// lostCrawlMessage is recorded on the crawls that were running when the process stopped
+const lostCrawlMessage = "The crawl was lost when the server stopped"
+
+// localExecutor runs the crawls, bulk actions and re-analyses in goroutines of the API process, the activities of
+// each task queue share as many slots as the Temporal worker of that queue would have. Everything stops when the
+// context of the executor is done, crawls are lost with the process
+type localExecutor struct {
+	ctx        context.Context
+	owner      string // Prefix of the workflow IDs of the crawls this process runs
+	activities *Activities
+	slots      map[string]chan struct{}
+
+	mu         sync.Mutex
+	runs       map[string]*localRun
+	batches    map[string]*localBatch
+	reanalyses map[string]bool
+}
+
+// localBatch is a bulk action run by the local executor
+type localBatch struct {
+	mu       sync.Mutex
+	progress BulkCrawlProgress
+}
+
+// localRun is a crawl run by the local executor
+type localRun struct {
+	cancel context.CancelFunc
+	wake   chan struct{}
+
+	mu       sync.Mutex
+	paused   bool
+	progress CrawlProgress
+}
+
+// newLocalExecutor creates a local executor running the activities with the activity slots of the configuration
+// until ctx is done
+func newLocalExecutor(ctx context.Context, cfg *config.Config, activities *Activities) *localExecutor {
+	// Crawls are owned by the host running them, a restart only fails the crawls this host lost
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	return &localExecutor{
+		ctx:        ctx,
+		owner:      "local_" + host + "_",
+		activities: activities,
+		slots: map[string]chan struct{}{
+			TaskQueueName:          make(chan struct{}, max(cfg.CrawlSlotsInteractive, 1)),
+			ScheduledTaskQueueName: make(chan struct{}, max(cfg.CrawlSlotsScheduled, 1)),
+			BackfillTaskQueueName:  make(chan struct{}, max(cfg.CrawlSlotsBackfill, 1)),
+		},
+		runs:       make(map[string]*localRun),
+		batches:    make(map[string]*localBatch),
+		reanalyses: make(map[string]bool),
+	}
+}
+
+// Ready always succeeds, the local executor has nothing to connect to
+func (e *localExecutor) Ready() error {
+	return nil
+}
+
+// CrawlWorkflowID returns the ID of a crawl owned by this process
+func (e *localExecutor) CrawlWorkflowID(urlID string, suffix string) string {
+	return e.owner + "crawl_" + urlID + "_" + suffix
+}
+
+// Start runs the crawl in a new goroutine
+func (e *localExecutor) Start(ctx context.Context, input WorlFlowInput, priority string) error {
+	run, runCtx, err := e.register(input)
+	if err != nil {
+		return err
+	}
+	go func() {
+		defer e.forget(input.WorkflowID)
+		defer run.cancel()
+		e.runCrawl(runCtx, run, input, TaskQueueForPriority(priority))
+	}()
+	return nil
+}
+
+// register records the crawl as running, so it can be stopped, paused and queried, and returns its context
+func (e *localExecutor) register(input WorlFlowInput) (*localRun, context.Context, error) {
+	runCtx, cancel := context.WithCancel(e.ctx)
+	run := &localRun{
+		cancel:   cancel,
+		wake:     make(chan struct{}, 1),
+		progress: CrawlProgress{CrawlID: input.CrawlID, Phase: CrawlPhaseFetching},
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.runs[input.WorkflowID]; ok {
+		cancel()
+		return nil, nil, fmt.Errorf("crawl %s is already running", input.WorkflowID)
+	}
+	e.runs[input.WorkflowID] = run
+	return run, runCtx, nil
+}
+
+// Stop cancels the crawl, a crawl that is not running has nothing to cancel
+func (e *localExecutor) Stop(ctx context.Context, workflowID string) error {
+	if run := e.lookup(workflowID); run != nil {
+		run.cancel()
+	}
+	return nil
+}
+
+// Signal pauses or resumes the crawl
+func (e *localExecutor) Signal(ctx context.Context, workflowID string, signalName string) error {
+	run := e.lookup(workflowID)
+	if run == nil {
+		return ErrCrawlNotRunning
+	}
+	switch signalName {
+	case PauseSignalName:
+		run.setPaused(true)
+	case ResumeSignalName:
+		run.setPaused(false)
+	default:
+		return fmt.Errorf("unsupported signal %q", signalName)
+	}
+	return nil
+}
+
+// Progress returns the progress of the crawl
+func (e *localExecutor) Progress(ctx context.Context, workflowID string) (CrawlProgress, error) {
+	run := e.lookup(workflowID)
+	if run == nil {
+		return CrawlProgress{}, ErrCrawlNotRunning
+	}
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	return run.progress.WithPercent(), nil
+}
+
+// lookup returns the running crawl with the workflow ID, or nil
+func (e *localExecutor) lookup(workflowID string) *localRun {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.runs[workflowID]
+}
+
+// forget removes the crawl once it finished
+func (e *localExecutor) forget(workflowID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.runs, workflowID)
+}
+
+// runCrawl runs the activities of the crawl in the order of CrawlWorkflow, it returns the error the workflow would
+// fail with, the error of ctx when the crawl was stopped
+func (e *localExecutor) runCrawl(ctx context.Context, run *localRun, input WorlFlowInput, taskQueue string) error {
+	log := logger.GetLogger().With(zap.String("crawl_id", input.CrawlID), zap.String("workflow_id", input.WorkflowID))
+
+	// Small delay to ensure the sse connection is ready
+	if !sleepContext(ctx, crawlStartDelay) {
+		return ctx.Err()
+	}
+
+	var page *PageFetchResult
+	err := e.execute(ctx, taskQueue, func(ctx context.Context) error {
+		var err error
+		page, err = e.activities.FetchPageActivity(ctx, input)
+		return err
+	})
+	if err != nil {
+		log.Error("Local crawl failed", zap.Error(err))
+		return err
+	}
+
+	// Non-HTML resources are done once fetched
+	if page != nil {
+		run.update(func(progress *CrawlProgress) {
+			progress.Phase = CrawlPhaseCheckingLinks
+			progress.LinksTotal = page.LinkCount
+		})
+
+		var results LinkCheckResults
+		for offset := 0; offset < page.LinkCount; offset += page.BatchSize {
+			resumed, err := e.waitWhilePaused(ctx, run, input, taskQueue, page.MaxPause)
+			if err != nil {
+				log.Error("Failed to pause local crawl", zap.Error(err))
+				return err
+			}
+			if !resumed {
+				log.Warn("Crawl stopped after staying paused too long", zap.Duration("max_pause", page.MaxPause))
+				return nil
+			}
+
+			var batch LinkCheckResults
+			err = e.execute(ctx, taskQueue, func(ctx context.Context) error {
+				var err error
+				batch, err = e.activities.CheckLinkBatchActivity(ctx, LinkBatchInput{Crawl: input, Page: *page, Offset: offset})
+				return err
+			})
+			if err != nil {
+				log.Error("Link batch failed", zap.Error(err), zap.Int("offset", offset))
+				e.failCrawl(ctx, input, taskQueue, err)
+				return err
+			}
+			results.Add(batch)
+			run.update(func(progress *CrawlProgress) {
+				progress.LinksChecked = min(offset+page.BatchSize, page.LinkCount)
+			})
+		}
+
+		run.update(func(progress *CrawlProgress) { progress.Phase = CrawlPhaseFinalizing })
+		err = e.execute(ctx, taskQueue, func(ctx context.Context) error {
+			return e.activities.FinalizeCrawlActivity(ctx, input, results)
+		})
+		if err != nil {
+			log.Error("Failed to finalize crawl", zap.Error(err))
+			e.failCrawl(ctx, input, taskQueue, err)
+			return err
+		}
+	}
+	run.update(func(progress *CrawlProgress) { progress.Phase = CrawlPhaseDone })
+
+	// Inaccessible links are rechecked in the background, outliving the crawl
+	var recheck *LinkRecheckInput
+	err = e.execute(ctx, taskQueue, func(ctx context.Context) error {
+		var err error
+		recheck, err = e.activities.PlanLinkRecheckActivity(ctx, input)
+		return err
+	})
+	if err != nil {
+		log.Error("Failed to plan link recheck", zap.Error(err))
+	} else if recheck != nil {
+		go e.recheckLinks(*recheck)
+	}
+	log.Info("Local crawl completed")
+	return nil
+}
+
+// waitWhilePaused blocks while the crawl is paused, recording the paused status until it is resumed. It returns
+// false when the crawl stayed paused longer than maxPause and was stopped instead
+func (e *localExecutor) waitWhilePaused(ctx context.Context, run *localRun, input WorlFlowInput, taskQueue string, maxPause time.Duration) (bool, error) {
+	if !run.isPaused() {
+		return true, nil
+	}
+	run.update(func(progress *CrawlProgress) { progress.Phase = CrawlPhasePaused })
+	if err := e.setStatus(ctx, input, taskQueue, db.CrawlsStatusPaused, ""); err != nil {
+		return false, err
+	}
+
+	var timeout <-chan time.Time
+	if maxPause > 0 {
+		timer := time.NewTimer(maxPause)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	resumed := true
+	for resumed && run.isPaused() {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-timeout:
+			resumed = false
+		case <-run.wake:
+		}
+	}
+
+	status := db.CrawlsStatusRunning
+	if !resumed {
+		status = db.CrawlsStatusStopped
+	}
+	if err := e.setStatus(ctx, input, taskQueue, status, ""); err != nil {
+		return false, err
+	}
+	run.update(func(progress *CrawlProgress) { progress.Phase = CrawlPhaseCheckingLinks })
+	return resumed, nil
+}
+
+// failCrawl records the error of a crawl that failed after its page was fetched, stopped crawls are left as they are
+func (e *localExecutor) failCrawl(ctx context.Context, input WorlFlowInput, taskQueue string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	message := fmt.Sprintf("Crawl failed to complete: %v", err)
+	if statusErr := e.setStatus(ctx, input, taskQueue, db.CrawlsStatusError, message); statusErr != nil {
+		logger.Error("Failed to record crawl error", zap.Error(statusErr), zap.String("crawl_id", input.CrawlID))
+	}
+}
+
+// setStatus runs SetCrawlStatusActivity for the crawl
+func (e *localExecutor) setStatus(ctx context.Context, input WorlFlowInput, taskQueue string, status db.CrawlsStatus, message string) error {
+	return e.execute(ctx, taskQueue, func(ctx context.Context) error {
+		return e.activities.SetCrawlStatusActivity(ctx, input, string(status), message)
+	})
+}
+
+// recheckLinks rechecks the inaccessible links of a crawl in rounds, like LinkRecheckWorkflow
+func (e *localExecutor) recheckLinks(input LinkRecheckInput) {
+	maxRounds := (input.Confirmations - 1) * maxRecheckRoundsPerConfirmation
+	for round := 0; round < maxRounds; round++ {
+		if !sleepContext(e.ctx, recheckDelay(input, round)) {
+			return
+		}
+		var result LinkRecheckResult
+		err := e.execute(e.ctx, ScheduledTaskQueueName, func(ctx context.Context) error {
+			var err error
+			result, err = e.activities.RecheckLinksActivity(ctx, recheckRound(input, round, maxRounds))
+			return err
+		})
+		if err != nil {
+			logger.Error("Link recheck round failed", zap.Error(err), zap.String("crawl_id", input.CrawlID), zap.Int("round", round))
+			return
+		}
+		if result.Pending == 0 {
+			return
+		}
+	}
+}
+
+// execute runs the activity in a slot of the task queue, retrying it with the local retry policy
+func (e *localExecutor) execute(ctx context.Context, taskQueue string, activity func(ctx context.Context) error) error {
+	return e.executeWithPolicy(ctx, taskQueue, localActivityPolicy, activity)
+}
+
+// executeWithPolicy runs the activity in a slot of the task queue, retrying it with the policy
+func (e *localExecutor) executeWithPolicy(ctx context.Context, taskQueue string, policy localRetryPolicy, activity func(ctx context.Context) error) error {
+	slots := e.slots[taskQueue]
+	return retryActivity(ctx, policy, func(ctx context.Context) error {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		defer func() { <-slots }()
+		return activity(ctx)
+	})
+}
+
+// StartBulk runs the bulk action in a new goroutine, like BulkCrawlWorkflow
+func (e *localExecutor) StartBulk(ctx context.Context, input BulkCrawlInput, priority string) error {
+	batch := &localBatch{progress: BulkCrawlProgress{
+		BatchID: input.BatchID,
+		UserID:  input.UserID,
+		Action:  input.Action,
+		Total:   len(input.URLIDs),
+	}}
+	e.mu.Lock()
+	e.batches[input.BatchID] = batch
+	e.mu.Unlock()
+
+	go e.runBulk(batch, input, TaskQueueForPriority(priority))
+	return nil
+}
+
+// BulkProgress returns the progress of the batch
+func (e *localExecutor) BulkProgress(ctx context.Context, batchID string) (BulkCrawlProgress, error) {
+	e.mu.Lock()
+	batch := e.batches[batchID]
+	e.mu.Unlock()
+	if batch == nil {
+		return BulkCrawlProgress{}, ErrBatchNotFound
+	}
+	batch.mu.Lock()
+	defer batch.mu.Unlock()
+	return batch.progress, nil
+}
+
+// runBulk handles the URLs of the batch, at most Parallelism at a time, and keeps its progress for a while after
+func (e *localExecutor) runBulk(batch *localBatch, input BulkCrawlInput, taskQueue string) {
+	log := logger.GetLogger().With(zap.String("batch_id", input.BatchID), zap.String("action", input.Action))
+	log.Info("Starting local bulk crawl", zap.Int("urls", len(input.URLIDs)), zap.Int("parallelism", input.Parallelism))
+
+	slots := make(chan struct{}, max(input.Parallelism, 1))
+	var wg sync.WaitGroup
+	for _, urlID := range input.URLIDs {
+		select {
+		case slots <- struct{}{}:
+		case <-e.ctx.Done():
+		}
+		if e.ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		item := BulkCrawlItem{UserID: input.UserID, URLID: urlID, Priority: input.Priority}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if input.Action == BulkActionStop {
+				e.stopBulkItem(batch, item, taskQueue)
+			} else {
+				item.WorkflowID = e.CrawlWorkflowID(urlID, input.BatchID)
+				e.startBulkItem(batch, item, taskQueue)
+			}
+		}()
+	}
+	wg.Wait()
+
+	batch.update(func(progress *BulkCrawlProgress) { progress.Done = true })
+	log.Info("Local bulk crawl completed")
+	time.AfterFunc(localBatchRetention, func() {
+		e.mu.Lock()
+		delete(e.batches, input.BatchID)
+		e.mu.Unlock()
+	})
+}
+
+// startBulkItem queues the crawl of a URL of the batch and runs it until it finishes, like startBulkItem
+func (e *localExecutor) startBulkItem(batch *localBatch, item BulkCrawlItem, taskQueue string) {
+	queueCtx, cancel := context.WithTimeout(e.ctx, bulkQueueTimeout)
+	defer cancel()
+	var crawlInput *WorlFlowInput
+	err := e.executeWithPolicy(queueCtx, taskQueue, localBulkQueuePolicy, func(ctx context.Context) error {
+		var err error
+		crawlInput, err = e.activities.QueueBulkCrawlActivity(ctx, item)
+		return err
+	})
+	if err != nil {
+		logger.Error("Failed to queue bulk crawl", zap.Error(err), zap.String("url_id", item.URLID))
+		batch.update(func(progress *BulkCrawlProgress) { progress.Failed++ })
+		return
+	}
+	if crawlInput == nil {
+		batch.update(func(progress *BulkCrawlProgress) { progress.Skipped++ })
+		return
+	}
+
+	run, runCtx, err := e.register(*crawlInput)
+	if err != nil {
+		logger.Error("Failed to start bulk crawl", zap.Error(err), zap.String("url_id", item.URLID))
+		batch.update(func(progress *BulkCrawlProgress) { progress.Failed++ })
+		return
+	}
+	batch.update(func(progress *BulkCrawlProgress) { progress.Running++ })
+	err = e.runCrawl(runCtx, run, *crawlInput, TaskQueueForPriority(item.Priority))
+	stopped := runCtx.Err() != nil
+	run.cancel()
+	e.forget(crawlInput.WorkflowID)
+
+	batch.update(func(progress *BulkCrawlProgress) {
+		progress.Running--
+		switch {
+		case stopped:
+			progress.Stopped++
+		case err == nil:
+			progress.Completed++
+		default:
+			progress.Failed++
+		}
+	})
+}
+
+// stopBulkItem stops the active crawls of a URL of the batch, like stopBulkItem
+func (e *localExecutor) stopBulkItem(batch *localBatch, item BulkCrawlItem, taskQueue string) {
+	var workflowIDs []string
+	err := e.execute(e.ctx, taskQueue, func(ctx context.Context) error {
+		var err error
+		workflowIDs, err = e.activities.StopBulkCrawlActivity(ctx, item)
+		return err
+	})
+	if err != nil {
+		logger.Error("Failed to stop bulk crawl", zap.Error(err), zap.String("url_id", item.URLID))
+		batch.update(func(progress *BulkCrawlProgress) { progress.Failed++ })
+		return
+	}
+	if len(workflowIDs) == 0 {
+		batch.update(func(progress *BulkCrawlProgress) { progress.Skipped++ })
+		return
+	}
+	for _, workflowID := range workflowIDs {
+		e.Stop(e.ctx, workflowID)
+	}
+	batch.update(func(progress *BulkCrawlProgress) { progress.Stopped++ })
+}
+
+// StartReanalysis runs the re-analysis in a new goroutine, like ReanalyzeWorkflow
+func (e *localExecutor) StartReanalysis(ctx context.Context, workflowID string, input ReanalyzeInput) (string, error) {
+	e.mu.Lock()
+	if e.reanalyses[workflowID] {
+		e.mu.Unlock()
+		return "", ErrReanalysisRunning
+	}
+	e.reanalyses[workflowID] = true
+	e.mu.Unlock()
+
+	go func() {
+		defer func() {
+			e.mu.Lock()
+			delete(e.reanalyses, workflowID)
+			e.mu.Unlock()
+		}()
+		e.runReanalysis(workflowID, input)
+	}()
+	return uuid.New().String(), nil
+}
+
+// runReanalysis re-analyzes the crawls of the input in throttled batches
+func (e *localExecutor) runReanalysis(workflowID string, input ReanalyzeInput) {
+	log := logger.GetLogger().With(zap.String("workflow_id", workflowID))
+	for {
+		batch, ok := nextReanalyzeBatch(input)
+		if !ok {
+			break
+		}
+		var result ReanalyzeBatchResult
+		err := e.executeWithPolicy(e.ctx, BackfillTaskQueueName, localReanalyzePolicy, func(ctx context.Context) error {
+			var err error
+			result, err = e.activities.ReanalyzeBatchActivity(ctx, batch)
+			return err
+		})
+		if err != nil {
+			log.Error("Re-analysis batch failed", zap.Error(err), zap.String("cursor", input.Cursor), zap.Int("offset", input.Offset))
+			return
+		}
+		if !advanceReanalysis(&input, batch, result) {
+			break
+		}
+		if input.BatchDelay > 0 && !sleepContext(e.ctx, input.BatchDelay) {
+			return
+		}
+	}
+	log.Info("Local re-analysis completed", zap.Int("processed", input.Processed), zap.Int("skipped", input.Skipped), zap.Int("failed", input.Failed))
+}
+
+// retryActivity runs the activity until it succeeds, fails with a non-retryable application error or runs out of
+// attempts. The wait between attempts grows with the backoff, unless the error asks for a specific delay
+func retryActivity(ctx context.Context, policy localRetryPolicy, activity func(ctx context.Context) error) error {
+	delay := policy.InitialInterval
+	for attempt := 1; ; attempt++ {
+		attemptCtx := ctx
+		cancel := context.CancelFunc(func() {})
+		if policy.AttemptTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, policy.AttemptTimeout)
+		}
+		err := activity(attemptCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var appErr *temporal.ApplicationError
+		isAppErr := errors.As(err, &appErr)
+		if isAppErr && appErr.NonRetryable() {
+			return err
+		}
+		if policy.MaximumAttempts > 0 && attempt >= policy.MaximumAttempts {
+			return err
+		}
+
+		wait := delay
+		if isAppErr && appErr.NextRetryDelay() > 0 {
+			wait = appErr.NextRetryDelay()
+		}
+		if !sleepContext(ctx, wait) {
+			return ctx.Err()
+		}
+		delay = time.Duration(float64(delay) * policy.BackoffCoefficient)
+		if policy.MaximumInterval > 0 && delay > policy.MaximumInterval {
+			delay = policy.MaximumInterval
+		}
+	}
+}
+
+// sleepContext waits for the duration, it returns false when the context is done first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// setPaused records a pause or resume signal, the last signal received wins
+func (r *localRun) setPaused(paused bool) {
+	r.mu.Lock()
+	r.paused = paused
+	r.mu.Unlock()
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// isPaused reports whether the last signal received paused the crawl
+func (r *localRun) isPaused() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.paused
+}
+
+// update changes the progress of the crawl
+func (r *localRun) update(change func(progress *CrawlProgress)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	change(&r.progress)
+}
+
+// update changes the progress of the batch
+func (b *localBatch) update(change func(progress *BulkCrawlProgress)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	change(&b.progress)
+}
+
+// FailOrphanedCrawls fails the crawls a previous process on this host left queued, running or paused when crawls
+// run locally, nothing could finish them anymore. Crawls of Temporal workers and other hosts are left alone, it
+// returns how many crawls were failed
+func (s *CrawlService) FailOrphanedCrawls(ctx context.Context) (int64, error) {
+	executor, ok := s.executor.(*localExecutor)
+	if !ok {
+		return 0, nil
+	}
+	return s.repo.FailActiveCrawls(ctx, executor.owner, lostCrawlMessage)
+}
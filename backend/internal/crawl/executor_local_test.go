@@ -0,0 +1,151 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sykell-backend/internal/config"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+)
+
+var testRetryPolicy = localRetryPolicy{
+	InitialInterval:    time.Millisecond,
+	BackoffCoefficient: 2.0,
+	MaximumInterval:    5 * time.Millisecond,
+	MaximumAttempts:    3,
+}
+
+func TestRetryActivity(t *testing.T) {
+	// A failing activity is retried until it succeeds
+	attempts := 0
+	err := retryActivity(context.Background(), testRetryPolicy, func(ctx context.Context) error {
+		attempts++
+		if attempts < 2 {
+			return errors.New("temporary failure")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+
+	// It gives up after the maximum number of attempts
+	attempts = 0
+	err = retryActivity(context.Background(), testRetryPolicy, func(ctx context.Context) error {
+		attempts++
+		return errors.New("permanent failure")
+	})
+	assert.EqualError(t, err, "permanent failure")
+	assert.Equal(t, 3, attempts)
+
+	// Non-retryable application errors are not retried
+	attempts = 0
+	err = retryActivity(context.Background(), testRetryPolicy, func(ctx context.Context) error {
+		attempts++
+		return temporal.NewNonRetryableApplicationError("login failed", "LoginFailed", nil)
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetryActivityCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	err := retryActivity(ctx, localRetryPolicy{InitialInterval: time.Hour, MaximumAttempts: 3}, func(ctx context.Context) error {
+		attempts++
+		cancel()
+		return errors.New("failed while stopping")
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestLocalExecutorStop(t *testing.T) {
+	executor := newLocalExecutor(context.Background(), &config.Config{}, &Activities{})
+	input := WorlFlowInput{CrawlID: "crawl-1", WorkflowID: "crawl_url-1_1"}
+
+	// The crawl waits for its start delay, a stop cancels it before any activity runs
+	require.NoError(t, executor.Start(context.Background(), input, PriorityInteractive))
+	assert.Error(t, executor.Start(context.Background(), input, PriorityInteractive))
+
+	progress, err := executor.Progress(context.Background(), input.WorkflowID)
+	require.NoError(t, err)
+	assert.Equal(t, CrawlPhaseFetching, progress.Phase)
+	require.NoError(t, executor.Signal(context.Background(), input.WorkflowID, PauseSignalName))
+
+	require.NoError(t, executor.Stop(context.Background(), input.WorkflowID))
+	assert.Eventually(t, func() bool {
+		return executor.lookup(input.WorkflowID) == nil
+	}, time.Second, 10*time.Millisecond)
+
+	// Crawls that are not running have nothing to stop, but cannot be signaled or queried
+	assert.NoError(t, executor.Stop(context.Background(), input.WorkflowID))
+	assert.ErrorIs(t, executor.Signal(context.Background(), input.WorkflowID, ResumeSignalName), ErrCrawlNotRunning)
+	_, err = executor.Progress(context.Background(), input.WorkflowID)
+	assert.ErrorIs(t, err, ErrCrawlNotRunning)
+}
+
+// missingURLRepo has no URLs
+type missingURLRepo struct {
+	Repo
+}
+
+func (r *missingURLRepo) GetUrlByIdAndUserId(ctx context.Context, urlID string, userID string) (*URLResponse, error) {
+	return nil, sql.ErrNoRows
+}
+
+func TestLocalExecutorBulk(t *testing.T) {
+	executor := newLocalExecutor(context.Background(), &config.Config{}, &Activities{Repo: &missingURLRepo{}})
+
+	_, err := executor.BulkProgress(context.Background(), "bulk-1")
+	assert.ErrorIs(t, err, ErrBatchNotFound)
+
+	// URLs without active crawls are skipped by a bulk stop, the progress is kept after the batch is done
+	input := BulkCrawlInput{BatchID: "bulk-1", UserID: "user-1", Action: BulkActionStop, URLIDs: []string{"url-1", "url-2"}, Parallelism: 1}
+	require.NoError(t, executor.StartBulk(context.Background(), input, PriorityBackfill))
+	assert.Eventually(t, func() bool {
+		progress, err := executor.BulkProgress(context.Background(), "bulk-1")
+		return err == nil && progress.Done
+	}, time.Second, 10*time.Millisecond)
+
+	progress, err := executor.BulkProgress(context.Background(), "bulk-1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, progress.Total)
+	assert.Equal(t, 2, progress.Skipped)
+}
+
+// orphanRepo records the prefix of the crawls it is asked to fail
+type orphanRepo struct {
+	Repo
+	prefix string
+}
+
+func (r *orphanRepo) FailActiveCrawls(ctx context.Context, workflowPrefix string, errorMessage string) (int64, error) {
+	r.prefix = workflowPrefix
+	return 1, nil
+}
+
+func TestFailOrphanedCrawls(t *testing.T) {
+	repo := &orphanRepo{}
+	service := NewCrawlService(context.Background(), repo, &config.Config{CrawlExecutor: ExecutorLocal}, nil, nil, nil, &Activities{})
+
+	// Only the crawls this host queued are failed, they are recognized by the prefix of their workflow ID
+	failed, err := service.FailOrphanedCrawls(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), failed)
+	assert.NotEmpty(t, repo.prefix)
+	assert.True(t, strings.HasPrefix(service.executor.CrawlWorkflowID("url-1", "1"), repo.prefix))
+
+	// Crawls run by Temporal are never failed on startup
+	repo.prefix = ""
+	service = NewCrawlService(context.Background(), repo, &config.Config{}, nil, nil, nil, &Activities{})
+	failed, err = service.FailOrphanedCrawls(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, failed)
+	assert.Empty(t, repo.prefix)
+}
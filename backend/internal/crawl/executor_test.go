@@ -0,0 +1,35 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"sykell-backend/internal/temporal"
+
+	"github.com/stretchr/testify/assert"
+	"go.temporal.io/api/serviceerror"
+)
+
+func TestStartUnconfirmed(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"Temporal not connected", temporal.ErrUnavailable, false},
+		{"Temporal unreachable", serviceerror.NewUnavailable("connection refused"), false},
+		{"Invalid request", serviceerror.NewInvalidArgument("invalid task queue"), false},
+		{"Already running locally", errors.New("crawl is already running"), false},
+		{"Request timed out", serviceerror.NewDeadlineExceeded("deadline exceeded"), true},
+		{"Context deadline", fmt.Errorf("failed to start: %w", context.DeadlineExceeded), true},
+		{"Request canceled", context.Canceled, true},
+		{"Workflow already started", serviceerror.NewWorkflowExecutionAlreadyStarted("started", "", ""), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, startUnconfirmed(tt.err))
+		})
+	}
+}
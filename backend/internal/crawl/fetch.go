@@ -0,0 +1,126 @@
+package crawl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// sniffLength is the number of bytes inspected to detect the content type of a response
+const sniffLength = 512
+
+// errBodyTooLarge is returned when a response body exceeds the configured maximum size
+var errBodyTooLarge = errors.New("response body exceeds the maximum allowed size")
+
+// fetchResult holds a fetched response body together with its detected type and encoding
+type fetchResult struct {
+	StatusCode    int
+	Header        http.Header
+	MimeType      string // Declared MIME type, or the sniffed one when the server did not declare any
+	Charset       string // Name of the encoding the body was decoded from, empty for non-HTML resources
+	ContentLength int64  // Size of the body in bytes
+	IsHTML        bool
+	Raw           []byte // Body exactly as received, only read for HTML resources
+	Body          []byte // Body transcoded to UTF-8, only set for HTML resources
+}
+
+// fetchPage executes the request and reads at most maxBodyBytes of the response body,
+// HTML bodies are transcoded to UTF-8 while other resources are only measured
+func fetchPage(client *http.Client, req *http.Request, maxBodyBytes int64) (*fetchResult, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &fetchResult{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+	}
+
+	// Read one byte past the limit so oversized bodies can be detected
+	limited := io.LimitReader(resp.Body, maxBodyBytes+1)
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(limited, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	head = head[:n]
+
+	contentType := resp.Header.Get("Content-Type")
+	result.MimeType = detectMimeType(contentType, head)
+	result.IsHTML = isHTMLMimeType(result.MimeType)
+
+	if !result.IsHTML {
+		// Only measure non-HTML resources, prefer the declared length when the server sent one
+		discarded, err := io.Copy(io.Discard, limited)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", err)
+		}
+		result.ContentLength = int64(len(head)) + discarded
+		if resp.ContentLength > result.ContentLength {
+			result.ContentLength = resp.ContentLength
+		}
+		return result, nil
+	}
+
+	rest, err := io.ReadAll(limited)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	result.Raw = append(head, rest...)
+	result.ContentLength = int64(len(result.Raw))
+	if result.ContentLength > maxBodyBytes {
+		return result, errBodyTooLarge
+	}
+
+	body, encodingName, err := decodeHTML(result.Raw, contentType)
+	if err != nil {
+		return nil, err
+	}
+	result.Body = body
+	result.Charset = encodingName
+	return result, nil
+}
+
+// detectMimeType returns the MIME type declared by the Content-Type header,
+// falling back to content sniffing when the header is missing or generic
+func detectMimeType(contentType string, head []byte) string {
+	if contentType != "" {
+		if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType != "application/octet-stream" {
+			return strings.ToLower(mediaType)
+		}
+	}
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
+
+// isHTMLMimeType reports whether the MIME type is parsed as an HTML document
+func isHTMLMimeType(mimeType string) bool {
+	return mimeType == "text/html" || mimeType == "application/xhtml+xml"
+}
+
+// decodeHTML transcodes an HTML body to UTF-8 using the charset from the Content-Type header,
+// a byte order mark or a <meta> declaration, in that order
+func decodeHTML(raw []byte, contentType string) ([]byte, string, error) {
+	enc, name, _ := charset.DetermineEncoding(raw, contentType)
+	if name == "utf-8" {
+		return raw, name, nil
+	}
+	decoded, err := enc.NewDecoder().Bytes(raw)
+	if err != nil {
+		return nil, name, fmt.Errorf("failed to decode %s content: %w", name, err)
+	}
+	// Drop a UTF-8 byte order mark left over by the decoder
+	return bytes.TrimPrefix(decoded, []byte("\xef\xbb\xbf")), name, nil
+}
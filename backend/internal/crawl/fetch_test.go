@@ -0,0 +1,130 @@
+package crawl
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchPageDecodesCharsets(t *testing.T) {
+	tests := []struct {
+		name            string
+		contentType     string
+		body            string
+		expectedCharset string
+		expectedText    string
+	}{
+		{
+			name:            "UTF-8",
+			contentType:     "text/html; charset=utf-8",
+			body:            "<html><body>héllo</body></html>",
+			expectedCharset: "utf-8",
+			expectedText:    "héllo",
+		},
+		{
+			name:            "ISO-8859-1 from header",
+			contentType:     "text/html; charset=ISO-8859-1",
+			body:            "<html><body>caf\xe9</body></html>",
+			expectedCharset: "windows-1252",
+			expectedText:    "café",
+		},
+		{
+			name:            "Shift_JIS from header",
+			contentType:     "text/html; charset=Shift_JIS",
+			body:            "<html><body>\x82\xb1\x82\xf1\x82\xc9\x82\xbf\x82\xcd</body></html>",
+			expectedCharset: "shift_jis",
+			expectedText:    "こんにちは",
+		},
+		{
+			name:            "windows-1251 from meta tag",
+			contentType:     "text/html",
+			body:            `<html><head><meta charset="windows-1251"></head><body>` + "\xcf\xf0\xe8\xe2\xe5\xf2</body></html>",
+			expectedCharset: "windows-1251",
+			expectedText:    "Привет",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			req, _ := http.NewRequest("GET", server.URL, nil)
+			result, err := fetchPage(server.Client(), req, 1024)
+			if err != nil {
+				t.Fatalf("fetchPage() error = %v", err)
+			}
+			if !result.IsHTML {
+				t.Errorf("fetchPage() IsHTML = false, want true")
+			}
+			if result.Charset != tt.expectedCharset {
+				t.Errorf("fetchPage() charset = %q, want %q", result.Charset, tt.expectedCharset)
+			}
+			if !strings.Contains(string(result.Body), tt.expectedText) {
+				t.Errorf("fetchPage() body = %q, want it to contain %q", result.Body, tt.expectedText)
+			}
+		})
+	}
+}
+
+func TestFetchPageContentTypes(t *testing.T) {
+	tests := []struct {
+		name         string
+		contentType  string
+		body         string
+		expectedMime string
+		expectedHTML bool
+	}{
+		{"Declared PDF", "application/pdf", "%PDF-1.4 binary content", "application/pdf", false},
+		{"Sniffed PNG", "", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", "image/png", false},
+		{"Sniffed HTML", "application/octet-stream", "<!DOCTYPE html><html><body>hi</body></html>", "text/html", true},
+		{"XHTML", "application/xhtml+xml; charset=utf-8", "<html><body>hi</body></html>", "application/xhtml+xml", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header()["Content-Type"] = []string{tt.contentType}
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			req, _ := http.NewRequest("GET", server.URL, nil)
+			result, err := fetchPage(server.Client(), req, 1024)
+			if err != nil {
+				t.Fatalf("fetchPage() error = %v", err)
+			}
+			if result.MimeType != tt.expectedMime {
+				t.Errorf("fetchPage() mime type = %q, want %q", result.MimeType, tt.expectedMime)
+			}
+			if result.IsHTML != tt.expectedHTML {
+				t.Errorf("fetchPage() IsHTML = %v, want %v", result.IsHTML, tt.expectedHTML)
+			}
+			if result.ContentLength != int64(len(tt.body)) {
+				t.Errorf("fetchPage() content length = %d, want %d", result.ContentLength, len(tt.body))
+			}
+		})
+	}
+}
+
+func TestFetchPageBodyTooLarge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body>" + strings.Repeat("a", 2048) + "</body></html>"))
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	result, err := fetchPage(server.Client(), req, 1024)
+	if !errors.Is(err, errBodyTooLarge) {
+		t.Fatalf("fetchPage() error = %v, want %v", err, errBodyTooLarge)
+	}
+	if result == nil || result.ContentLength != 1025 {
+		t.Errorf("fetchPage() should report the truncated size of an oversized body, got %+v", result)
+	}
+}
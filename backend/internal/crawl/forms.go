@@ -0,0 +1,16 @@
+package crawl
+
+import (
+	"context"
+)
+
+// GetForms returns the forms found by the latest completed crawl of the specified URL of the user
+func (s *CrawlService) GetForms(ctx context.Context, userID string, urlID string) ([]FormResponse, error) {
+	// Verify that the URL belongs to the user
+	url, err := s.repo.GetUrlByIdAndUserId(ctx, urlID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetLatestCrawlForms(ctx, url.ID)
+}
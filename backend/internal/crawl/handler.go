@@ -1,8 +1,14 @@
 package crawl
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"net/http"
 	"sykell-backend/internal/logger"
+	"sykell-backend/internal/quota"
+	"sykell-backend/internal/temporal"
 
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
@@ -21,6 +27,14 @@ func NewCrawlHandler(crawlService *CrawlService) *CrawlHandler {
 	}
 }
 
+// respondUnavailable answers with 503 when the Temporal server running the crawls cannot be reached
+func respondUnavailable(c echo.Context) error {
+	c.Response().Header().Set("Retry-After", "30")
+	return c.JSON(http.StatusServiceUnavailable, map[string]string{
+		"error": "The crawl service is temporarily unavailable, please try again later",
+	})
+}
+
 // StartCrawl handles starting a new crawl
 func (h *CrawlHandler) StartCrawl(c echo.Context) error {
 	userID := c.Get("user_id")
@@ -31,10 +45,32 @@ func (h *CrawlHandler) StartCrawl(c echo.Context) error {
 		})
 	}
 
+	priority := c.QueryParam("priority")
+	if priority == "" {
+		priority = PriorityInteractive
+	}
+
 	ctx := c.Request().Context()
 
-	err := h.crawlService.StartCrawl(ctx, userID.(string), urlID)
+	err := h.crawlService.StartCrawl(ctx, userID.(string), urlID, priority)
+	var limitErr *quota.LimitError
+	if errors.As(err, &limitErr) {
+		return quota.RespondLimit(c, limitErr)
+	}
+	if errors.Is(err, ErrInvalidPriority) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	if errors.Is(err, ErrCrawlLimitReached) {
+		return c.JSON(http.StatusTooManyRequests, map[string]string{
+			"error": err.Error(),
+		})
+	}
 	if err != nil {
+		if temporal.IsUnavailable(err) {
+			return respondUnavailable(c)
+		}
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "Failed to retrieve user profile",
 		})
@@ -62,6 +98,9 @@ func (h *CrawlHandler) StopCrawl(c echo.Context) error {
 
 	err := h.crawlService.StopCrawl(ctx, userID.(string), urlID)
 	if err != nil {
+		if temporal.IsUnavailable(err) {
+			return respondUnavailable(c)
+		}
 		logger.Error("Error in StopCrawl handler", 
 			zap.Error(err),
 			zap.String("user_id", userID.(string)),
@@ -77,12 +116,370 @@ func (h *CrawlHandler) StopCrawl(c echo.Context) error {
 	})
 }
 
+// PauseCrawl handles pausing an active crawl
+func (h *CrawlHandler) PauseCrawl(c echo.Context) error {
+	return h.signalCrawl(c, "pause", h.crawlService.PauseCrawl)
+}
+
+// ResumeCrawl handles resuming a paused crawl
+func (h *CrawlHandler) ResumeCrawl(c echo.Context) error {
+	return h.signalCrawl(c, "resume", h.crawlService.ResumeCrawl)
+}
+
+// signalCrawl sends a pause or resume request to the crawl of a URL, the crawl reports its new status over SSE
+func (h *CrawlHandler) signalCrawl(c echo.Context, action string, signal func(ctx context.Context, userID string, urlID string) error) error {
+	userID := c.Get("user_id")
+	urlID := c.Param("id")
+	if urlID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Missing URL ID",
+		})
+	}
+
+	ctx := c.Request().Context()
+
+	err := signal(ctx, userID.(string), urlID)
+	if err != nil {
+		if temporal.IsUnavailable(err) {
+			return respondUnavailable(c)
+		}
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "URL not found",
+			})
+		case errors.Is(err, ErrNoRunningCrawl), errors.Is(err, ErrNoPausedCrawl), errors.Is(err, ErrCrawlNotRunning):
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		logger.Error("Error signaling crawl",
+			zap.Error(err),
+			zap.String("user_id", userID.(string)),
+			zap.String("url_id", urlID),
+			zap.String("action", action))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("Failed to %s crawl", action),
+		})
+	}
+
+	return c.JSON(http.StatusAccepted, map[string]string{
+		"message": fmt.Sprintf("Crawl %s requested", action),
+	})
+}
+
+// GetCrawlProgress handles retrieving the progress of the active crawl of a URL
+func (h *CrawlHandler) GetCrawlProgress(c echo.Context) error {
+	userID := c.Get("user_id")
+	urlID := c.Param("id")
+	if urlID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Missing URL ID",
+		})
+	}
+
+	ctx := c.Request().Context()
+
+	progress, err := h.crawlService.GetCrawlProgress(ctx, userID.(string), urlID)
+	if err != nil {
+		if temporal.IsUnavailable(err) {
+			return respondUnavailable(c)
+		}
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "URL not found",
+			})
+		case errors.Is(err, ErrNoActiveCrawl):
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": err.Error(),
+			})
+		case errors.Is(err, ErrCrawlNotRunning):
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		logger.Error("Error in GetCrawlProgress handler",
+			zap.Error(err),
+			zap.String("user_id", userID.(string)),
+			zap.String("url_id", urlID))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve crawl progress",
+		})
+	}
+
+	return c.JSON(http.StatusOK, progress)
+}
+
+// GetForms handles listing the forms found by the latest crawl of a URL
+func (h *CrawlHandler) GetForms(c echo.Context) error {
+	userID := c.Get("user_id")
+	urlID := c.Param("id")
+	if urlID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Missing URL ID",
+		})
+	}
+
+	ctx := c.Request().Context()
+
+	forms, err := h.crawlService.GetForms(ctx, userID.(string), urlID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "URL not found",
+			})
+		}
+		logger.Error("Error in GetForms handler",
+			zap.Error(err),
+			zap.String("user_id", userID.(string)),
+			zap.String("url_id", urlID))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve forms",
+		})
+	}
+
+	return c.JSON(http.StatusOK, forms)
+}
+
+// GetLinkCacheStats handles retrieving the link status cache hit ratio of the user's crawls
+func (h *CrawlHandler) GetLinkCacheStats(c echo.Context) error {
+	userID := c.Get("user_id")
+	ctx := c.Request().Context()
+
+	stats, err := h.crawlService.GetLinkCacheStats(ctx, userID.(string))
+	if err != nil {
+		logger.Error("Error in GetLinkCacheStats handler",
+			zap.Error(err),
+			zap.String("user_id", userID.(string)))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve link cache stats",
+		})
+	}
+
+	return c.JSON(http.StatusOK, stats)
+}
+
+// GetCrawlRepairs handles listing the repairs the reconciler made to the user's crawls
+func (h *CrawlHandler) GetCrawlRepairs(c echo.Context) error {
+	userID := c.Get("user_id")
+	ctx := c.Request().Context()
+
+	repairs, err := h.crawlService.ListCrawlRepairs(ctx, userID.(string))
+	if err != nil {
+		logger.Error("Error in GetCrawlRepairs handler",
+			zap.Error(err),
+			zap.String("user_id", userID.(string)))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve crawl repairs",
+		})
+	}
+
+	return c.JSON(http.StatusOK, repairs)
+}
+
+// GetSnapshot handles retrieving the snapshot details of a crawl
+func (h *CrawlHandler) GetSnapshot(c echo.Context) error {
+	userID := c.Get("user_id")
+	crawlID := c.Param("id")
+	if crawlID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Missing crawl ID",
+		})
+	}
+
+	ctx := c.Request().Context()
+
+	snapshot, err := h.crawlService.GetSnapshot(ctx, userID.(string), crawlID)
+	if err != nil {
+		if errors.Is(err, ErrSnapshotNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Snapshot not found",
+			})
+		}
+		logger.Error("Error in GetSnapshot handler",
+			zap.Error(err),
+			zap.String("user_id", userID.(string)),
+			zap.String("crawl_id", crawlID))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve snapshot",
+		})
+	}
+
+	return c.JSON(http.StatusOK, snapshot)
+}
+
+// DownloadSnapshot handles downloading the raw HTML archived by a crawl
+func (h *CrawlHandler) DownloadSnapshot(c echo.Context) error {
+	userID := c.Get("user_id")
+	crawlID := c.Param("id")
+	if crawlID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Missing crawl ID",
+		})
+	}
+
+	ctx := c.Request().Context()
+
+	snapshot, content, err := h.crawlService.GetSnapshotContent(ctx, userID.(string), crawlID)
+	if err != nil {
+		if errors.Is(err, ErrSnapshotNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": "Snapshot not found",
+			})
+		}
+		logger.Error("Error in DownloadSnapshot handler",
+			zap.Error(err),
+			zap.String("user_id", userID.(string)),
+			zap.String("crawl_id", crawlID))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to download snapshot",
+		})
+	}
+
+	// Serve the bytes exactly as fetched, declaring the encoding they were decoded from
+	contentType := "text/html"
+	if snapshot.MimeType != "" {
+		contentType = snapshot.MimeType
+	}
+	if snapshot.Charset != "" {
+		contentType += "; charset=" + snapshot.Charset
+	}
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", crawlID+".html"))
+	c.Response().Header().Set("X-Snapshot-Hash", snapshot.Hash)
+	return c.Blob(http.StatusOK, contentType, content)
+}
+
+// StartReanalysis handles starting a re-analysis of the user's archived crawls
+func (h *CrawlHandler) StartReanalysis(c echo.Context) error {
+	userID := c.Get("user_id")
+
+	var request ReanalyzeRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	ctx := c.Request().Context()
+
+	response, err := h.crawlService.StartReanalysis(ctx, userID.(string), request)
+	if err != nil {
+		if temporal.IsUnavailable(err) {
+			return respondUnavailable(c)
+		}
+		switch {
+		case errors.Is(err, ErrTooManyReanalysisCrawls):
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		case errors.Is(err, ErrReanalysisRunning):
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		logger.Error("Error in StartReanalysis handler",
+			zap.Error(err),
+			zap.String("user_id", userID.(string)))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to start re-analysis",
+		})
+	}
+
+	return c.JSON(http.StatusAccepted, response)
+}
+
+// StartBulkCrawl handles starting the crawls of many URLs at once
+func (h *CrawlHandler) StartBulkCrawl(c echo.Context) error {
+	return h.bulkCrawl(c, BulkActionStart)
+}
+
+// StopBulkCrawl handles stopping the crawls of many URLs at once
+func (h *CrawlHandler) StopBulkCrawl(c echo.Context) error {
+	return h.bulkCrawl(c, BulkActionStop)
+}
+
+// bulkCrawl starts a bulk crawl workflow for the given action
+func (h *CrawlHandler) bulkCrawl(c echo.Context, action string) error {
+	userID := c.Get("user_id")
+
+	var request BulkCrawlRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	ctx := c.Request().Context()
+
+	response, err := h.crawlService.StartBulkCrawl(ctx, userID.(string), action, request)
+	if err != nil {
+		if temporal.IsUnavailable(err) {
+			return respondUnavailable(c)
+		}
+		if errors.Is(err, ErrBulkTargetsRequired) || errors.Is(err, ErrTooManyBulkURLs) || errors.Is(err, ErrInvalidPriority) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		if errors.Is(err, ErrCrawlLimitReached) {
+			return c.JSON(http.StatusTooManyRequests, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		var limitErr *quota.LimitError
+		if errors.As(err, &limitErr) {
+			return quota.RespondLimit(c, limitErr)
+		}
+		logger.Error("Error in bulk crawl handler",
+			zap.Error(err),
+			zap.String("user_id", userID.(string)),
+			zap.String("action", action))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to start bulk crawl",
+		})
+	}
+
+	return c.JSON(http.StatusAccepted, response)
+}
+
+// GetBulkCrawlProgress handles retrieving the progress of a bulk crawl
+func (h *CrawlHandler) GetBulkCrawlProgress(c echo.Context) error {
+	userID := c.Get("user_id")
+	batchID := c.Param("id")
+	if batchID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Missing batch ID",
+		})
+	}
+
+	ctx := c.Request().Context()
+
+	progress, err := h.crawlService.GetBulkCrawlProgress(ctx, userID.(string), batchID)
+	if err != nil {
+		if temporal.IsUnavailable(err) {
+			return respondUnavailable(c)
+		}
+		if errors.Is(err, ErrBatchNotFound) {
+			return c.JSON(http.StatusNotFound, map[string]string{
+				"error": err.Error(),
+			})
+		}
+		logger.Error("Error in GetBulkCrawlProgress handler",
+			zap.Error(err),
+			zap.String("user_id", userID.(string)),
+			zap.String("batch_id", batchID))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve bulk crawl progress",
+		})
+	}
+
+	return c.JSON(http.StatusOK, progress)
+}
+
 // NotifyCrawlUpdate handles internal notifications to trigger SSE updates
 func (h *CrawlHandler) NotifyCrawlUpdate(c echo.Context) error {
-		var request struct {
-			UserID string `json:"user_id"`
-			URLID  string `json:"url_id"`
-		}
+		var request NotificationRequest
 		
 		if err := c.Bind(&request); err != nil {
 			return c.JSON(http.StatusBadRequest, map[string]string{
@@ -98,7 +495,11 @@ func (h *CrawlHandler) NotifyCrawlUpdate(c echo.Context) error {
 			zap.String("url_id", request.URLID))
 		
 		// Trigger the SSE notification
-		NotifyCrawlUpdate(request.UserID, request.URLID)
+		if request.Progress != nil {
+			NotifyCrawlProgress(request.UserID, request.URLID, *request.Progress)
+		} else {
+			NotifyCrawlUpdate(request.UserID, request.URLID, request.Status)
+		}
 		
 		return c.JSON(http.StatusOK, map[string]string{
 			"message": "Notification sent",
@@ -0,0 +1,236 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"sykell-backend/internal/blobstore"
+	"sykell-backend/internal/db"
+	"sykell-backend/internal/utils"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"golang.org/x/net/html"
+	"golang.org/x/net/publicsuffix"
+)
+
+// progressInterval is the shortest time between two progress events of a link batch
+const progressInterval = time.Second
+
+// linkBatchCheckpoint is the heartbeat of a link batch, a retried batch starts after the links already checked
+type linkBatchCheckpoint struct {
+	Offset  int              `json:"offset"`
+	Checked []utils.LinkInfo `json:"checked"`
+}
+
+// resume copies the links checked by a previous attempt into the batch and returns how many there are, a
+// checkpoint of another batch or of other links is ignored
+func (c linkBatchCheckpoint) resume(offset int, batch []utils.LinkInfo) int {
+	if c.Offset != offset || len(c.Checked) > len(batch) {
+		return 0
+	}
+	for i, link := range c.Checked {
+		if link.AbsoluteURL != batch[i].AbsoluteURL || link.Href != batch[i].Href {
+			return 0
+		}
+	}
+	return copy(batch, c.Checked)
+}
+
+// CheckLinkBatchActivity checks one batch of the links of a fetched page, the links are extracted again from
+// the archived snapshot so the workflow only has to remember how far it got
+func (a *Activities) CheckLinkBatchActivity(ctx context.Context, input LinkBatchInput) (LinkCheckResults, error) {
+	logger := activityLogger(ctx)
+	var results LinkCheckResults
+	cfg := a.Config
+	repo := a.Repo
+
+	raw, err := blobstore.GetCompressed(ctx, a.Snapshots, input.Page.SnapshotHash)
+	if err != nil {
+		return results, fmt.Errorf("failed to load snapshot: %w", err)
+	}
+	body, _, err := decodeHTML(raw, input.Page.ContentType)
+	if err != nil {
+		return results, err
+	}
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return results, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	links := utils.ExtractLinks(doc, input.Crawl.URL)
+	if input.Offset >= len(links) {
+		return results, nil
+	}
+	batch := links[input.Offset:min(input.Offset+input.Page.BatchSize, len(links))]
+
+	// A batch interrupted by a worker shutdown resumes after the links its last heartbeat reported as checked
+	start := 0
+	var checkpoint linkBatchCheckpoint
+	if heartbeatDetails(ctx, &checkpoint) {
+		start = checkpoint.resume(input.Offset, batch)
+		if start > 0 {
+			logger.Info("Resuming link batch", "crawl_id", input.Crawl.CrawlID, "offset", input.Offset, "checked", start)
+		}
+	}
+	var mu sync.Mutex
+	checked := start
+	currentCheckpoint := func() interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return linkBatchCheckpoint{Offset: input.Offset, Checked: batch[:checked]}
+	}
+
+	cancelKeepAlive := keepAlive(ctx, 30*time.Second, currentCheckpoint)
+	defer cancelKeepAlive()
+
+	// Links are checked with the same settings as the page, including its proxy, pacing and request profile
+	profile, err := loadRequestProfile(ctx, repo, cfg.SecretsKey, input.Crawl.URLID)
+	if err != nil {
+		return results, fmt.Errorf("failed to load request profile: %w", err)
+	}
+	transport, err := a.crawlTransport(ctx, profile)
+	if err != nil {
+		return results, err
+	}
+
+	checker := &utils.LinkChecker{Transport: transport}
+	if profile != nil && profile.ApplyToInternalLinks {
+		checker.PrepareInternal = profile.Apply
+		checker.CheckRedirect = profile.CheckRedirect
+		// The session of the page is not kept between batches, every batch signs in again
+		if profile.Login != nil {
+			jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+			if err != nil {
+				return results, fmt.Errorf("failed to create cookie jar: %w", err)
+			}
+			client := &http.Client{Transport: transport, Jar: jar, Timeout: 20 * time.Second, CheckRedirect: profile.CheckRedirect}
+			err = utils.PerformFormLogin(ctx, client, profile.Login, profile.Apply)
+			if errors.Is(err, utils.ErrLoginFailed) || errors.Is(err, utils.ErrBlockedAddress) {
+				return results, temporal.NewNonRetryableApplicationError(fmt.Sprintf("Login failed: %v", err), "LoginFailed", err)
+			}
+			if err != nil {
+				return results, fmt.Errorf("failed to log in: %w", err)
+			}
+			checker.Jar = jar
+		}
+	}
+	linkCache := newLinkStatusCache(ctx, repo, cfg.LinkCacheTTL, cfg.LinkCacheNegativeTTL)
+	checker.Cache = linkCache
+
+	pageAnchors := utils.PageAnchors(doc, input.Crawl.URL)
+	var lastProgress time.Time
+	for i := start; i < len(batch); i++ {
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+		checker.CheckLinks(batch[i:i+1], pageAnchors)
+		// Our own pacing is not a failure of the link, it is checked again once its host has a free slot
+		for batch[i].Reason == utils.LinkReasonRateLimited {
+			logger.Info("Waiting for the rate limit of a linked host", "crawl_id", input.Crawl.CrawlID, "url", batch[i].AbsoluteURL, "wait", batch[i].RetryAfter)
+			if !sleepContext(ctx, max(batch[i].RetryAfter, 100*time.Millisecond)) {
+				return results, ctx.Err()
+			}
+			checker.CheckLinks(batch[i:i+1], pageAnchors)
+		}
+		mu.Lock()
+		checked = i + 1
+		mu.Unlock()
+		recordHeartbeat(ctx, currentCheckpoint())
+
+		// Progress events are throttled, the last link of the batch is always reported
+		if time.Since(lastProgress) >= progressInterval || i == len(batch)-1 {
+			lastProgress = time.Now()
+			a.Notifier.CrawlProgress(input.Crawl.UserID, input.Crawl.URLID, CrawlProgress{
+				CrawlID:      input.Crawl.CrawlID,
+				Phase:        CrawlPhaseCheckingLinks,
+				LinksChecked: input.Offset + i + 1,
+				LinksTotal:   input.Page.LinkCount,
+			}.WithPercent())
+		}
+	}
+
+	counts := utils.CountLinkCategories(batch)
+	results.Counts = LinkCounts{
+		Internal:     counts["internal"],
+		External:     counts["external"],
+		Inaccessible: counts["inaccessible"],
+		BrokenAnchor: counts["broken_anchor"],
+	}
+	// Only inaccessible links are saved, with the reason of their status, they are confirmed broken by the recheck workflow
+	for _, link := range batch {
+		if !link.BrokenAnchor && !link.Status().Accessible() && link.Reason != utils.LinkReasonRateLimited {
+			results.InaccessibleLinks = append(results.InaccessibleLinks, link)
+		}
+	}
+	results.CacheHits, results.CacheMisses = linkCache.Stats()
+
+	logger.Info("Link batch checked", "crawl_id", input.Crawl.CrawlID, "offset", input.Offset, "links", len(batch), "inaccessible", results.Counts.Inaccessible, "broken_anchor", results.Counts.BrokenAnchor)
+	return results, nil
+}
+
+// FinalizeCrawlActivity saves the link results of every batch and marks the crawl done
+func (a *Activities) FinalizeCrawlActivity(ctx context.Context, input WorlFlowInput, results LinkCheckResults) error {
+	logger := activityLogger(ctx)
+	repo := a.Repo
+
+	if err := repo.UpdateCrawlLinkCacheStats(ctx, input.CrawlID, results.CacheHits, results.CacheMisses); err != nil {
+		logger.Error("Failed to update link cache stats", "error", err, "crawl_id", input.CrawlID)
+	}
+	if removed, err := repo.DeleteExpiredLinkStatuses(ctx, expiredLinkStatusBatch); err != nil {
+		logger.Error("Failed to delete expired link statuses", "error", err)
+	} else if removed > 0 {
+		logger.Info("Expired link statuses deleted", "count", removed)
+	}
+
+	if err := repo.SaveInaccessibleLinks(ctx, input.CrawlID, results.InaccessibleLinks); err != nil {
+		logger.Error("Failed to save inaccessible links", "error", err, "crawl_id", input.CrawlID)
+		return fmt.Errorf("failed to save inaccessible links: %w", err)
+	}
+	if err := repo.UpdateCrawlLinkCounts(ctx, input.CrawlID, results.Counts); err != nil {
+		logger.Error("Failed to update link counts", "error", err, "crawl_id", input.CrawlID)
+		return fmt.Errorf("failed to update link counts: %w", err)
+	}
+	if err := repo.SetCrawlDone(ctx, input.CrawlID); err != nil {
+		logger.Error("Failed to set crawl done", "error", err, "crawl_id", input.CrawlID)
+		return fmt.Errorf("failed to set crawl done: %w", err)
+	}
+
+	logger.Info("Crawl completed successfully", "crawl_id", input.CrawlID, "url", input.URL, "internal", results.Counts.Internal, "external", results.Counts.External, "inaccessible", results.Counts.Inaccessible, "broken_anchor", results.Counts.BrokenAnchor)
+	a.Notifier.CrawlProgress(input.UserID, input.URLID, CrawlProgress{CrawlID: input.CrawlID, Phase: CrawlPhaseDone}.WithPercent())
+	a.Notifier.CrawlUpdated(input.UserID, input.URLID, "")
+	return nil
+}
+
+// SetCrawlStatusActivity changes the status of a crawl from the workflow, e.g. when it is paused or resumed,
+// and notifies the user of the new status
+func (a *Activities) SetCrawlStatusActivity(ctx context.Context, input WorlFlowInput, status string, message string) error {
+	logger := activityLogger(ctx)
+	repo := a.Repo
+
+	var err error
+	switch db.CrawlsStatus(status) {
+	case db.CrawlsStatusRunning:
+		err = repo.SetCrawlRunning(ctx, input.CrawlID)
+	case db.CrawlsStatusPaused:
+		err = repo.SetCrawlPaused(ctx, input.CrawlID)
+	case db.CrawlsStatusStopped:
+		err = repo.SetCrawlStopped(ctx, input.CrawlID)
+	case db.CrawlsStatusError:
+		err = repo.SetCrawlError(ctx, input.CrawlID, message)
+	default:
+		return temporal.NewNonRetryableApplicationError(fmt.Sprintf("Unsupported crawl status %q", status), "InvalidStatus", nil)
+	}
+	if err != nil {
+		logger.Error("Failed to update crawl status", "error", err, "crawl_id", input.CrawlID, "status", status)
+		return fmt.Errorf("failed to update crawl status: %w", err)
+	}
+
+	logger.Info("Crawl status updated", "crawl_id", input.CrawlID, "status", status)
+	a.Notifier.CrawlUpdated(input.UserID, input.URLID, status)
+	return nil
+}
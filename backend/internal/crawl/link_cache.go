@@ -0,0 +1,87 @@
+package crawl
+
+import (
+	"context"
+	"sync"
+	"sykell-backend/internal/utils"
+	"time"
+)
+
+// expiredLinkStatusBatch is how many expired link statuses a crawl removes when it finishes
+const expiredLinkStatusBatch = 1000
+
+// linkStatusCache is the utils.LinkStatusCache of a crawl, links repeated on the page are answered from memory
+// and links checked by earlier crawls from the link_status_cache table
+type linkStatusCache struct {
+	ctx         context.Context
+	repo        Repo
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mu     sync.Mutex
+	local  map[string]utils.LinkStatus
+	hits   int
+	misses int
+}
+
+// newLinkStatusCache creates the link status cache of a crawl, inaccessible links are kept for the negative TTL only
+func newLinkStatusCache(ctx context.Context, repo Repo, ttl time.Duration, negativeTTL time.Duration) *linkStatusCache {
+	return &linkStatusCache{
+		ctx:         ctx,
+		repo:        repo,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		local:       map[string]utils.LinkStatus{},
+	}
+}
+
+// Get implements utils.LinkStatusCache, database errors are treated as misses so the link is checked again
+func (c *linkStatusCache) Get(absoluteURL string) (utils.LinkStatus, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if status, ok := c.local[absoluteURL]; ok {
+		c.hits++
+		return status, true
+	}
+	status, found, err := c.repo.GetCachedLinkStatus(c.ctx, absoluteURL)
+	if err != nil || !found {
+		c.misses++
+		return utils.LinkStatus{}, false
+	}
+	c.local[absoluteURL] = status
+	c.hits++
+	return status, true
+}
+
+// Put implements utils.LinkStatusCache, a status that cannot be stored is only kept for the current crawl
+func (c *linkStatusCache) Put(absoluteURL string, status utils.LinkStatus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.local[absoluteURL] = status
+	ttl := c.ttl
+	if !status.Accessible() {
+		ttl = c.negativeTTL
+	}
+	c.repo.CacheLinkStatus(c.ctx, absoluteURL, status, ttl)
+}
+
+// Stats returns the number of cache hits and misses so far
+func (c *linkStatusCache) Stats() (hits int, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// HitRatio returns the share of lookups answered from the cache
+func (c *linkStatusCache) HitRatio() float64 {
+	hits, misses := c.Stats()
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+// GetLinkCacheStats returns how often the link statuses of the user's crawls were served from the cache
+func (s *CrawlService) GetLinkCacheStats(ctx context.Context, userID string) (*LinkCacheStatsResponse, error) {
+	return s.repo.GetLinkCacheStats(ctx, userID)
+}
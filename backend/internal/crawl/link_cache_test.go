@@ -0,0 +1,54 @@
+package crawl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"sykell-backend/internal/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkStatusCache(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	cache := newLinkStatusCache(context.Background(), NewRepo(mockDB), 6*time.Hour, 15*time.Minute)
+
+	// A link checked by an earlier crawl is served from the database, then from memory
+	mock.ExpectQuery("SELECT status_code, reason, redirect_url FROM link_status_cache").
+		WithArgs("https://example.com/about").
+		WillReturnRows(sqlmock.NewRows([]string{"status_code", "reason", "redirect_url"}).AddRow(200, utils.LinkReasonOK, nil))
+	status, found := cache.Get("https://example.com/about")
+	require.True(t, found)
+	assert.Equal(t, 200, *status.StatusCode)
+	assert.True(t, status.Accessible())
+	_, found = cache.Get("https://example.com/about")
+	assert.True(t, found)
+
+	// Unreachable links and soft 404s are cached with the negative TTL
+	mock.ExpectQuery("SELECT status_code, reason, redirect_url FROM link_status_cache").
+		WithArgs("https://gone.example.com/").
+		WillReturnRows(sqlmock.NewRows([]string{"status_code", "reason", "redirect_url"}))
+	_, found = cache.Get("https://gone.example.com/")
+	assert.False(t, found)
+	mock.ExpectExec("INSERT INTO link_status_cache").
+		WithArgs("https://gone.example.com/", "https://gone.example.com/", nil, utils.LinkReasonDNSError, nil, int64(900)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	cache.Put("https://gone.example.com/", utils.LinkStatus{Reason: utils.LinkReasonDNSError})
+	ok := 200
+	mock.ExpectExec("INSERT INTO link_status_cache").
+		WithArgs("https://example.com/old", "https://example.com/old", 200, utils.LinkReasonSoftNotFound, nil, int64(900)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	cache.Put("https://example.com/old", utils.LinkStatus{StatusCode: &ok, Reason: utils.LinkReasonSoftNotFound})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+	hits, misses := cache.Stats()
+	assert.Equal(t, 2, hits)
+	assert.Equal(t, 1, misses)
+	assert.InDelta(t, 2.0/3.0, cache.HitRatio(), 0.001)
+}
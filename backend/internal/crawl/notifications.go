@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"time"
@@ -13,15 +14,66 @@ import (
 	"go.uber.org/zap"
 )
 
-// NotifyCrawlUpdateHTTP sends an HTTP request to the main server to trigger SSE notifications
-// This is used from the Temporal worker process to communicate with the main server process
-func NotifyCrawlUpdateHTTP(userID, urlID string) {			
-	// Create the notification request
-	request := NotificationRequest{
+// Notifier tells the main server about crawl updates so it can push them to the dashboard over SSE
+type Notifier interface {
+	// CrawlUpdated reports an update of the crawl of the URL, status is set when the update changed it
+	CrawlUpdated(userID, urlID, status string)
+	// CrawlProgress reports the progress of the crawl of the URL
+	CrawlProgress(userID, urlID string, progress CrawlProgress)
+}
+
+// notificationClient sends the notifications to the main server, shared so its connections are kept alive
+var notificationClient = &http.Client{
+	Timeout: 5 * time.Second,
+}
+
+// HTTPNotifier notifies the main server at URL through its internal notification endpoint
+type HTTPNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewHTTPNotifier creates a notifier for the main server at backendURL sharing the notification client
+func NewHTTPNotifier(backendURL string) *HTTPNotifier {
+	return &HTTPNotifier{URL: backendURL, Client: notificationClient}
+}
+
+// CrawlUpdated notifies the main server of an update of the crawl, the status is forwarded in the SSE event
+func (n *HTTPNotifier) CrawlUpdated(userID, urlID, status string) {
+	n.post(NotificationRequest{
 		UserID: userID,
 		URLID:  urlID,
-	}
-	
+		Status: status,
+	})
+}
+
+// CrawlProgress notifies the main server of the progress of a crawl, forwarded as a crawl_progress SSE event
+func (n *HTTPNotifier) CrawlProgress(userID, urlID string, progress CrawlProgress) {
+	n.post(NotificationRequest{
+		UserID:   userID,
+		URLID:    urlID,
+		Progress: &progress,
+	})
+}
+
+// NotifyCrawlUpdateHTTP sends an HTTP request to the main server to trigger SSE notifications
+// This is used from the Temporal worker process to communicate with the main server process
+func NotifyCrawlUpdateHTTP(userID, urlID string) {
+	NotifyCrawlStatusHTTP(userID, urlID, "")
+}
+
+// NotifyCrawlStatusHTTP notifies the main server at BACKEND_URL of an update that changed the status of a crawl
+func NotifyCrawlStatusHTTP(userID, urlID, status string) {
+	NewHTTPNotifier(os.Getenv("BACKEND_URL")).CrawlUpdated(userID, urlID, status)
+}
+
+// NotifyCrawlProgressHTTP notifies the main server at BACKEND_URL of the progress of a crawl
+func NotifyCrawlProgressHTTP(userID, urlID string, progress CrawlProgress) {
+	NewHTTPNotifier(os.Getenv("BACKEND_URL")).CrawlProgress(userID, urlID, progress)
+}
+
+// post sends a notification request to the main server
+func (n *HTTPNotifier) post(request NotificationRequest) {
 	// Marshal to JSON
 	jsonData, err := json.Marshal(request)
 	if err != nil {
@@ -30,25 +82,27 @@ func NotifyCrawlUpdateHTTP(userID, urlID string) {
 	}
 	
 	// Make HTTP request to the main server
-	url := fmt.Sprintf("%s/api/v1/internal/notify-crawl-update", os.Getenv("BACKEND_URL"))
-	
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-	}
+	url := fmt.Sprintf("%s/api/v1/internal/notify-crawl-update", n.URL)
 	
-	resp, err := client.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	resp, err := n.Client.Post(url, "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
 		logger.Error("Error sending notification to main server", zap.Error(err))
 		return
 	}
-	defer resp.Body.Close()
+	// The body is drained so the connection is reused by the next notification
+	defer func() {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
 	
 	if resp.StatusCode != http.StatusOK {
 		logger.Warn("Notification request failed", zap.Int("status_code", resp.StatusCode))
 		return
 	}
 	
-	logger.Info("Successfully sent crawl update notification", 
-		zap.String("user_id", userID), 
-		zap.String("url_id", urlID))
+	logger.Debug("Successfully sent crawl update notification", 
+		zap.String("user_id", request.UserID), 
+		zap.String("url_id", request.URLID),
+		zap.String("status", request.Status),
+		zap.Bool("progress", request.Progress != nil))
 }
\ No newline at end of file
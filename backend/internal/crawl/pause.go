@@ -0,0 +1,74 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sykell-backend/internal/db"
+	"sykell-backend/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// Errors returned when a crawl cannot be paused or resumed
+var (
+	ErrNoRunningCrawl = errors.New("no running crawl to pause")
+	ErrNoPausedCrawl  = errors.New("no paused crawl to resume")
+)
+
+// PauseCrawl asks the active crawls of the URL to pause, a crawl pauses before its next batch of link checks
+// and keeps the links it already checked
+func (s *CrawlService) PauseCrawl(ctx context.Context, userID string, urlID string) error {
+	return s.signalCrawls(ctx, userID, urlID, PauseSignalName, func(status string) bool {
+		return status != string(db.CrawlsStatusPaused)
+	}, ErrNoRunningCrawl)
+}
+
+// ResumeCrawl asks the paused crawls of the URL to continue where they stopped
+func (s *CrawlService) ResumeCrawl(ctx context.Context, userID string, urlID string) error {
+	return s.signalCrawls(ctx, userID, urlID, ResumeSignalName, func(status string) bool {
+		return status == string(db.CrawlsStatusPaused)
+	}, ErrNoPausedCrawl)
+}
+
+// signalCrawls sends the signal to the active crawls of the URL accepted by the filter,
+// it returns notFound when there is no such crawl
+func (s *CrawlService) signalCrawls(ctx context.Context, userID string, urlID string, signalName string, accept func(status string) bool, notFound error) error {
+	// Verify that the URL belongs to the user
+	url, err := s.repo.GetUrlByIdAndUserId(ctx, urlID, userID)
+	if err != nil {
+		return err
+	}
+
+	activeCrawls, err := s.repo.GetActiveCrawlsForUrlId(ctx, url.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.executor.Ready(); err != nil {
+		return err
+	}
+
+	signaled := 0
+	for _, crawl := range activeCrawls {
+		if !accept(crawl.Status) {
+			continue
+		}
+		if err := s.executor.Signal(ctx, crawl.WorkflowID, signalName); err != nil {
+			logger.Error("Error signaling crawl workflow",
+				zap.Error(err),
+				zap.String("workflow_id", crawl.WorkflowID),
+				zap.String("signal", signalName))
+			return fmt.Errorf("failed to signal workflow: %w", err)
+		}
+		logger.Info("Signaled crawl workflow",
+			zap.String("crawl_id", crawl.ID),
+			zap.String("workflow_id", crawl.WorkflowID),
+			zap.String("signal", signalName))
+		signaled++
+	}
+	if signaled == 0 {
+		return notFound
+	}
+	return nil
+}
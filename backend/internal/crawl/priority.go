@@ -0,0 +1,66 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"sykell-backend/internal/config"
+)
+
+// Errors returned when a crawl cannot be started with its priority
+var (
+	ErrInvalidPriority   = errors.New("invalid crawl priority")
+	ErrCrawlLimitReached = errors.New("too many active crawls, wait for some of them to finish")
+)
+
+// TaskQueueForPriority returns the task queue the crawls of the priority run on, interactive crawls keep
+// the original task queue
+func TaskQueueForPriority(priority string) string {
+	switch priority {
+	case PriorityScheduled:
+		return ScheduledTaskQueueName
+	case PriorityBackfill:
+		return BackfillTaskQueueName
+	default:
+		return TaskQueueName
+	}
+}
+
+// validPriority reports whether the priority is one of the known crawl priorities
+func validPriority(priority string) bool {
+	switch priority {
+	case PriorityInteractive, PriorityScheduled, PriorityBackfill:
+		return true
+	}
+	return false
+}
+
+// scheduledPriority reports whether crawls of the priority are limited by the schedule interval of the user's plan,
+// crawls the user starts from the dashboard are not
+func scheduledPriority(priority string) bool {
+	return priority == PriorityScheduled || priority == PriorityBackfill
+}
+
+// userCrawlLimit returns how many active crawls of the priority a user can have, 0 means no limit
+func userCrawlLimit(cfg *config.Config, priority string) int {
+	switch priority {
+	case PriorityScheduled:
+		return cfg.CrawlUserLimitScheduled
+	case PriorityBackfill:
+		return cfg.CrawlUserLimitBackfill
+	default:
+		return cfg.CrawlUserLimitInteractive
+	}
+}
+
+// remainingCrawls returns how many more crawls of the priority the user can start, or -1 without a limit
+func (s *CrawlService) remainingCrawls(ctx context.Context, userID string, priority string) (int, error) {
+	limit := userCrawlLimit(s.config, priority)
+	if limit <= 0 {
+		return -1, nil
+	}
+	active, err := s.repo.CountActiveCrawlsForUser(ctx, userID, priority)
+	if err != nil {
+		return 0, err
+	}
+	return max(limit-int(active), 0), nil
+}
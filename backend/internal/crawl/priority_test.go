@@ -0,0 +1,43 @@
+package crawl
+
+import (
+	"context"
+	"sykell-backend/internal/config"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskQueueForPriority(t *testing.T) {
+	assert.Equal(t, TaskQueueName, TaskQueueForPriority(PriorityInteractive))
+	assert.Equal(t, ScheduledTaskQueueName, TaskQueueForPriority(PriorityScheduled))
+	assert.Equal(t, BackfillTaskQueueName, TaskQueueForPriority(PriorityBackfill))
+}
+
+func TestStartCrawlUserLimit(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectQuery("SELECT id, user_id, normalized_url").
+		WithArgs("url-1", "user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "normalized_url", "domain", "created_at", "updated_at"}).
+			AddRow("url-1", "user-1", "https://example.com", "example.com", nil, nil))
+	mock.ExpectQuery("SELECT COUNT").
+		WithArgs("url-1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT").
+		WithArgs("user-1", "interactive").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	// The limit is checked before anything is queued or started
+	service := &CrawlService{repo: NewRepo(mockDB), config: &config.Config{CrawlUserLimitInteractive: 2}}
+	err = service.StartCrawl(context.Background(), "user-1", "url-1", PriorityInteractive)
+	assert.ErrorIs(t, err, ErrCrawlLimitReached)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	err = service.StartCrawl(context.Background(), "user-1", "url-1", "urgent")
+	assert.ErrorIs(t, err, ErrInvalidPriority)
+}
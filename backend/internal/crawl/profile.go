@@ -0,0 +1,46 @@
+package crawl
+
+import (
+	"context"
+	"net/url"
+	"sykell-backend/internal/config"
+	"sykell-backend/internal/utils"
+)
+
+// loadRequestProfile returns the decrypted request profile of a URL, or nil when it has none
+func loadRequestProfile(ctx context.Context, repo Repo, secretsKey string, urlID string) (*utils.RequestProfile, error) {
+	stored, err := repo.GetRequestProfile(ctx, urlID)
+	if err != nil || stored == nil {
+		return nil, err
+	}
+	profile := &utils.RequestProfile{
+		UserAgent:            stored.UserAgent,
+		BasicAuthUsername:    stored.BasicAuthUsername,
+		ApplyToInternalLinks: stored.ApplyToInternalLinks,
+	}
+	if stored.LoginURL != "" {
+		profile.Login = &utils.LoginRecipe{
+			LoginURL:      stored.LoginURL,
+			UsernameField: stored.LoginUsernameField,
+			PasswordField: stored.LoginPasswordField,
+			Username:      stored.LoginUsername,
+		}
+	}
+	if err := profile.OpenSecrets(utils.DeriveKey(secretsKey), stored.EncryptedSecrets); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// resolveProxy returns the outbound proxy of a crawl, the URL's own proxy takes precedence over the
+// configured one, nil means requests go out directly
+func resolveProxy(cfg *config.Config, profile *utils.RequestProfile) (*url.URL, error) {
+	raw := cfg.CrawlProxyURL
+	if profile != nil && profile.ProxyURL != "" {
+		raw = profile.ProxyURL
+	}
+	if raw == "" {
+		return nil, nil
+	}
+	return utils.ParseProxyURL(raw)
+}
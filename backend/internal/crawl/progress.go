@@ -0,0 +1,40 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"sykell-backend/internal/db"
+)
+
+// ErrNoActiveCrawl is returned when progress is requested for a URL that is not being crawled
+var ErrNoActiveCrawl = errors.New("no active crawl")
+
+// GetCrawlProgress returns the progress of the active crawl of the URL reported by the executor,
+// a crawl whose workflow has not started yet is reported as queued
+func (s *CrawlService) GetCrawlProgress(ctx context.Context, userID string, urlID string) (*CrawlProgress, error) {
+	// Verify that the URL belongs to the user
+	url, err := s.repo.GetUrlByIdAndUserId(ctx, urlID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	activeCrawls, err := s.repo.GetActiveCrawlsForUrlId(ctx, url.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(activeCrawls) == 0 {
+		return nil, ErrNoActiveCrawl
+	}
+	// The most recent active crawl is listed first
+	crawl := activeCrawls[0]
+	if crawl.Status == string(db.CrawlsStatusQueued) {
+		progress := CrawlProgress{CrawlID: crawl.ID, Phase: CrawlPhaseQueued}.WithPercent()
+		return &progress, nil
+	}
+
+	progress, err := s.executor.Progress(ctx, crawl.WorkflowID)
+	if err != nil {
+		return nil, err
+	}
+	return &progress, nil
+}
@@ -0,0 +1,16 @@
+package crawl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrawlProgressWithPercent(t *testing.T) {
+	assert.Equal(t, 0, CrawlProgress{Phase: CrawlPhaseFetching}.WithPercent().Percent)
+	assert.Equal(t, 25, CrawlProgress{Phase: CrawlPhaseCheckingLinks, LinksChecked: 10, LinksTotal: 40}.WithPercent().Percent)
+	// Checking every link is not the end of the crawl, the results still have to be saved
+	assert.Equal(t, 99, CrawlProgress{Phase: CrawlPhaseCheckingLinks, LinksChecked: 40, LinksTotal: 40}.WithPercent().Percent)
+	assert.Equal(t, 99, CrawlProgress{Phase: CrawlPhaseFinalizing}.WithPercent().Percent)
+	assert.Equal(t, 100, CrawlProgress{Phase: CrawlPhaseDone, LinksChecked: 10, LinksTotal: 40}.WithPercent().Percent)
+}
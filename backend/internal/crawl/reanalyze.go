@@ -0,0 +1,265 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sykell-backend/internal/blobstore"
+	"time"
+
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+	"golang.org/x/net/html"
+)
+
+// Re-analysis throttling defaults and limits
+const (
+	DefaultReanalyzeBatchSize  = 20
+	MaxReanalyzeBatchSize      = 200
+	DefaultReanalyzeBatchDelay = 2 * time.Second
+	MaxReanalyzeCrawlIDs       = 1000
+
+	// reanalyzeBatchesPerRun bounds the workflow history, the workflow continues as new after that many batches
+	reanalyzeBatchesPerRun = 100
+)
+
+// Errors returned when a re-analysis cannot be started
+var (
+	ErrReanalysisRunning       = errors.New("a re-analysis is already running")
+	ErrTooManyReanalysisCrawls = fmt.Errorf("at most %d crawls can be re-analyzed at once", MaxReanalyzeCrawlIDs)
+)
+
+// ReanalyzeWorkflow replays the current extractors against the archived snapshots of the selected crawls,
+// or of every archived crawl analyzed by an older version, in throttled batches
+func ReanalyzeWorkflow(ctx workflow.Context, input ReanalyzeInput) (ReanalyzeResult, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting re-analysis workflow", "user_id", input.UserID, "selected_crawls", len(input.CrawlIDs), "target_version", input.TargetVersion, "cursor", input.Cursor, "offset", input.Offset)
+
+	if input.TargetVersion <= 0 {
+		input.TargetVersion = AnalysisVersion
+	}
+	if input.BatchSize <= 0 {
+		input.BatchSize = DefaultReanalyzeBatchSize
+	}
+
+	activityOptions := workflow.ActivityOptions{
+		StartToCloseTimeout: 10 * time.Minute,
+		HeartbeatTimeout:    time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    5,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, activityOptions)
+
+	for batches := 0; ; batches++ {
+		// Keep the history small by carrying the progress over to a fresh run
+		if batches >= reanalyzeBatchesPerRun {
+			logger.Info("Continuing re-analysis as new", "processed", input.Processed, "cursor", input.Cursor, "offset", input.Offset)
+			return ReanalyzeResult{}, workflow.NewContinueAsNewError(ctx, ReanalyzeWorkflow, input)
+		}
+
+		batch, ok := nextReanalyzeBatch(input)
+		if !ok {
+			break
+		}
+
+		var result ReanalyzeBatchResult
+		if err := workflow.ExecuteActivity(ctx, activities.ReanalyzeBatchActivity, batch).Get(ctx, &result); err != nil {
+			logger.Error("Re-analysis batch failed", "error", err, "cursor", input.Cursor, "offset", input.Offset)
+			return ReanalyzeResult{}, err
+		}
+		if !advanceReanalysis(&input, batch, result) {
+			break
+		}
+
+		if input.BatchDelay > 0 {
+			if err := workflow.Sleep(ctx, input.BatchDelay); err != nil {
+				return ReanalyzeResult{}, err
+			}
+		}
+	}
+
+	logger.Info("Re-analysis workflow completed", "processed", input.Processed, "skipped", input.Skipped, "failed", input.Failed)
+	return ReanalyzeResult{
+		Processed: input.Processed,
+		Skipped:   input.Skipped,
+		Failed:    input.Failed,
+	}, nil
+}
+
+// nextReanalyzeBatch returns the next batch of the re-analysis, it returns false once every selected crawl was handled
+func nextReanalyzeBatch(input ReanalyzeInput) (ReanalyzeBatchInput, bool) {
+	batch := ReanalyzeBatchInput{
+		UserID:        input.UserID,
+		TargetVersion: input.TargetVersion,
+		Cursor:        input.Cursor,
+		Limit:         input.BatchSize,
+	}
+	if len(input.CrawlIDs) > 0 {
+		if input.Offset >= len(input.CrawlIDs) {
+			return batch, false
+		}
+		end := input.Offset + input.BatchSize
+		if end > len(input.CrawlIDs) {
+			end = len(input.CrawlIDs)
+		}
+		batch.CrawlIDs = input.CrawlIDs[input.Offset:end]
+	}
+	return batch, true
+}
+
+// advanceReanalysis counts the result of the batch and moves the re-analysis past it, it returns false once every
+// archived crawl was listed
+func advanceReanalysis(input *ReanalyzeInput, batch ReanalyzeBatchInput, result ReanalyzeBatchResult) bool {
+	input.Processed += result.Processed
+	input.Skipped += result.Skipped
+	input.Failed += result.Failed
+
+	if len(input.CrawlIDs) > 0 {
+		input.Offset += len(batch.CrawlIDs)
+		return true
+	}
+	if result.NextCursor == "" {
+		return false
+	}
+	input.Cursor = result.NextCursor
+	return true
+}
+
+// ReanalyzeBatchActivity re-analyzes a batch of archived crawls, a crawl that fails is counted and left at its
+// old analysis version so a later run picks it up again
+func (a *Activities) ReanalyzeBatchActivity(ctx context.Context, input ReanalyzeBatchInput) (ReanalyzeBatchResult, error) {
+	logger := activityLogger(ctx)
+	var result ReanalyzeBatchResult
+	var err error
+
+	repo := a.Repo
+	snapshots := a.Snapshots
+
+	// Load the candidates of the batch, selected crawls that do not qualify are skipped
+	var candidates []ReanalysisCandidate
+	if len(input.CrawlIDs) > 0 {
+		for _, crawlID := range input.CrawlIDs {
+			candidate, err := repo.GetCrawlForReanalysis(ctx, crawlID, input.UserID, input.TargetVersion)
+			if err == sql.ErrNoRows {
+				result.Skipped++
+				continue
+			}
+			if err != nil {
+				return result, fmt.Errorf("failed to load crawl %s: %w", crawlID, err)
+			}
+			candidates = append(candidates, *candidate)
+		}
+	} else {
+		candidates, err = repo.ListCrawlsForReanalysis(ctx, input.UserID, input.Cursor, input.TargetVersion, input.Limit)
+		if err != nil {
+			return result, fmt.Errorf("failed to list crawls for re-analysis: %w", err)
+		}
+		if len(candidates) == input.Limit {
+			result.NextCursor = candidates[len(candidates)-1].CrawlID
+		}
+	}
+
+	for i, candidate := range candidates {
+		recordHeartbeat(ctx, i)
+		if err := reanalyzeCrawl(ctx, repo, snapshots, candidate, input.TargetVersion); err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			logger.Warn("Failed to re-analyze crawl", "error", err, "crawl_id", candidate.CrawlID)
+			result.Failed++
+			continue
+		}
+		result.Processed++
+	}
+
+	logger.Info("Re-analysis batch completed", "processed", result.Processed, "skipped", result.Skipped, "failed", result.Failed, "next_cursor", result.NextCursor)
+	return result, nil
+}
+
+// reanalyzeCrawl parses the archived snapshot of a crawl and stores the new analysis
+func reanalyzeCrawl(ctx context.Context, repo Repo, snapshots blobstore.Store, candidate ReanalysisCandidate, version int) error {
+	raw, err := blobstore.GetCompressed(ctx, snapshots, candidate.SnapshotHash)
+	if err != nil {
+		return fmt.Errorf("failed to load snapshot: %w", err)
+	}
+	body, _, err := decodeHTML(raw, candidate.ContentType)
+	if err != nil {
+		return err
+	}
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	analysis := analyzeDocument(doc, candidate.URL)
+	if err := repo.SaveCrawlForms(ctx, candidate.CrawlID, analysis.Forms); err != nil {
+		return fmt.Errorf("failed to save crawl forms: %w", err)
+	}
+	if err := repo.UpdateCrawlFingerprint(ctx, candidate.CrawlID, analysis.ContentHash, analysis.SimHash); err != nil {
+		return fmt.Errorf("failed to update crawl fingerprint: %w", err)
+	}
+	// The analysis version is written last so an interrupted crawl is picked up again
+	if err := repo.UpdateCrawlAnalysis(ctx, candidate.CrawlID, analysis, version); err != nil {
+		return fmt.Errorf("failed to update crawl analysis: %w", err)
+	}
+	return nil
+}
+
+// StartReanalysisWorkflow starts the re-analysis workflow under the given ID, it fails with ErrReanalysisRunning
+// while another re-analysis with the same ID is still running
+func StartReanalysisWorkflow(ctx context.Context, temporalClient client.Client, workflowID string, input ReanalyzeInput) (client.WorkflowRun, error) {
+	workflowOptions := client.StartWorkflowOptions{
+		ID:                                       workflowID,
+		TaskQueue:                                BackfillTaskQueueName,
+		WorkflowExecutionErrorWhenAlreadyStarted: true,
+	}
+	run, err := temporalClient.ExecuteWorkflow(ctx, workflowOptions, ReanalyzeWorkflowName, input)
+	if err != nil {
+		var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+		if errors.As(err, &alreadyStarted) {
+			return nil, ErrReanalysisRunning
+		}
+		return nil, err
+	}
+	return run, nil
+}
+
+// StartReanalysis starts re-analyzing the selected crawls of the user, or all of the user's archived crawls
+func (s *CrawlService) StartReanalysis(ctx context.Context, userID string, request ReanalyzeRequest) (*ReanalyzeResponse, error) {
+	if len(request.CrawlIDs) > MaxReanalyzeCrawlIDs {
+		return nil, ErrTooManyReanalysisCrawls
+	}
+
+	input := ReanalyzeInput{
+		UserID:        userID,
+		CrawlIDs:      request.CrawlIDs,
+		TargetVersion: AnalysisVersion,
+		BatchSize:     request.BatchSize,
+		BatchDelay:    DefaultReanalyzeBatchDelay,
+	}
+	if input.BatchSize <= 0 || input.BatchSize > MaxReanalyzeBatchSize {
+		input.BatchSize = DefaultReanalyzeBatchSize
+	}
+	if request.BatchDelaySeconds > 0 {
+		input.BatchDelay = time.Duration(request.BatchDelaySeconds) * time.Second
+	}
+
+	workflowID := "reanalyze_" + userID
+	runID, err := s.executor.StartReanalysis(ctx, workflowID, input)
+	if err != nil {
+		return nil, err
+	}
+	return &ReanalyzeResponse{
+		WorkflowID:    workflowID,
+		RunID:         runID,
+		TargetVersion: input.TargetVersion,
+	}, nil
+}
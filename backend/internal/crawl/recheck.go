@@ -0,0 +1,198 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"sykell-backend/internal/utils"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// maxRecheckRoundsPerConfirmation bounds the rounds of a recheck workflow, links that were skipped
+// because their host was rate limited need more rounds than confirmations
+const maxRecheckRoundsPerConfirmation = 3
+
+// LinkRecheckWorkflow rechecks the inaccessible links of a crawl after a growing delay until every link
+// has either recovered or failed enough times in a row to be confirmed broken
+func LinkRecheckWorkflow(ctx workflow.Context, input LinkRecheckInput) (LinkRecheckResult, error) {
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Starting link recheck workflow", "crawl_id", input.CrawlID, "confirmations", input.Confirmations, "delay", input.Delay)
+
+	activityOptions := workflow.ActivityOptions{
+		StartToCloseTimeout: 10 * time.Minute,
+		HeartbeatTimeout:    time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, activityOptions)
+
+	var total LinkRecheckResult
+	maxRounds := (input.Confirmations - 1) * maxRecheckRoundsPerConfirmation
+	for round := 0; round < maxRounds; round++ {
+		if err := workflow.Sleep(ctx, recheckDelay(input, round)); err != nil {
+			return total, err
+		}
+
+		var result LinkRecheckResult
+		if err := workflow.ExecuteActivity(ctx, activities.RecheckLinksActivity, recheckRound(input, round, maxRounds)).Get(ctx, &result); err != nil {
+			logger.Error("Link recheck round failed", "error", err, "crawl_id", input.CrawlID, "round", round)
+			return total, err
+		}
+		total.Recovered += result.Recovered
+		total.Confirmed += result.Confirmed
+		total.Pending = result.Pending
+		if result.Pending == 0 {
+			break
+		}
+	}
+
+	if total.Pending > 0 {
+		logger.Warn("Link recheck stopped with unconfirmed links", "crawl_id", input.CrawlID, "pending", total.Pending)
+	}
+	logger.Info("Link recheck workflow completed", "crawl_id", input.CrawlID, "recovered", total.Recovered, "confirmed", total.Confirmed)
+	return total, nil
+}
+
+// recheckDelay returns the wait before the given recheck round, doubling from the initial delay up to the maximum
+func recheckDelay(input LinkRecheckInput, round int) time.Duration {
+	delay := input.Delay
+	for i := 0; i < round && (input.MaxDelay <= 0 || delay < input.MaxDelay); i++ {
+		delay *= 2
+	}
+	if input.MaxDelay > 0 && delay > input.MaxDelay {
+		delay = input.MaxDelay
+	}
+	return delay
+}
+
+// recheckRound returns the input of the activity running the given round, rounds are numbered from 1 so a
+// retried attempt recognizes the failures it already recorded
+func recheckRound(input LinkRecheckInput, round int, maxRounds int) LinkRecheckInput {
+	input.Round = round + 1
+	input.Final = round == maxRounds-1
+	return input
+}
+
+// PlanLinkRecheckActivity decides whether the inaccessible links of a finished crawl are rechecked, it returns
+// nil when there is nothing to recheck, and confirms the links right away when rechecks are disabled
+func (a *Activities) PlanLinkRecheckActivity(ctx context.Context, input WorlFlowInput) (*LinkRecheckInput, error) {
+	logger := activityLogger(ctx)
+	cfg := a.Config
+	repo := a.Repo
+
+	if cfg.LinkRecheckConfirmations <= 1 {
+		if _, err := repo.ConfirmPendingLinks(ctx, input.CrawlID); err != nil {
+			return nil, fmt.Errorf("failed to confirm inaccessible links: %w", err)
+		}
+		return nil, nil
+	}
+
+	links, err := repo.ListPendingLinks(ctx, input.CrawlID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list inaccessible links: %w", err)
+	}
+	if len(links) == 0 {
+		return nil, nil
+	}
+
+	logger.Info("Inaccessible links will be rechecked", "crawl_id", input.CrawlID, "links", len(links))
+	return &LinkRecheckInput{
+		CrawlID:       input.CrawlID,
+		URLID:         input.URLID,
+		UserID:        input.UserID,
+		Delay:         cfg.LinkRecheckDelay,
+		MaxDelay:      cfg.LinkRecheckMaxDelay,
+		Confirmations: cfg.LinkRecheckConfirmations,
+	}, nil
+}
+
+// RecheckLinksActivity checks every pending link of a crawl once more, recording recovered and confirmed links
+// and notifying the user when the crawl's counts changed. Links still pending after the final round, e.g. because
+// their host stayed rate limited, are confirmed broken
+func (a *Activities) RecheckLinksActivity(ctx context.Context, input LinkRecheckInput) (LinkRecheckResult, error) {
+	logger := activityLogger(ctx)
+	var result LinkRecheckResult
+	repo := a.Repo
+
+	links, err := repo.ListPendingLinks(ctx, input.CrawlID)
+	if err != nil {
+		return result, fmt.Errorf("failed to list inaccessible links: %w", err)
+	}
+	if len(links) == 0 {
+		return result, nil
+	}
+
+	// Rate limit waits can outlast the heartbeat timeout, the heartbeat is kept alive while a link is checked
+	cancelKeepAlive := keepAlive(ctx, 30*time.Second, func() interface{} { return "Rechecking links" })
+	defer cancelKeepAlive()
+
+	// Links are checked the way the crawl checked them, except that a login session is not replayed
+	profile, err := loadRequestProfile(ctx, repo, a.Config.SecretsKey, input.URLID)
+	if err != nil {
+		return result, fmt.Errorf("failed to load request profile: %w", err)
+	}
+	transport, err := a.crawlTransport(ctx, profile)
+	if err != nil {
+		return result, err
+	}
+
+	checker := &utils.LinkChecker{Transport: transport}
+	if profile != nil && profile.ApplyToInternalLinks {
+		checker.PrepareInternal = profile.Apply
+		checker.CheckRedirect = profile.CheckRedirect
+	}
+
+	for _, link := range links {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		// A retried attempt skips the links whose failure it already recorded in this round
+		if link.RecheckRound >= input.Round {
+			result.Pending++
+			continue
+		}
+
+		status := checker.CheckLink(link.AbsoluteURL, link.IsInternal)
+		switch {
+		case status.Accessible():
+			if err := repo.RecordLinkRecovered(ctx, input.CrawlID, link, status); err != nil {
+				return result, fmt.Errorf("failed to record recovered link: %w", err)
+			}
+			result.Recovered++
+		case status.Reason == utils.LinkReasonRateLimited:
+			// Our own pacing is not a failure of the link, it is checked again in the next round
+			result.Pending++
+		default:
+			if err := repo.RecordLinkRecheckFailure(ctx, link.ID, status, input.Confirmations, input.Round); err != nil {
+				return result, fmt.Errorf("failed to record link failure: %w", err)
+			}
+			if link.ConsecutiveFailures+1 >= input.Confirmations {
+				result.Confirmed++
+			} else {
+				result.Pending++
+			}
+		}
+	}
+
+	if input.Final && result.Pending > 0 {
+		confirmed, err := repo.ConfirmPendingLinks(ctx, input.CrawlID)
+		if err != nil {
+			return result, fmt.Errorf("failed to confirm unresolved links: %w", err)
+		}
+		logger.Warn("Unresolved links confirmed after the last recheck round", "crawl_id", input.CrawlID, "links", confirmed)
+		result.Confirmed += int(confirmed)
+		result.Pending = 0
+	}
+
+	logger.Info("Link recheck round completed", "crawl_id", input.CrawlID, "recovered", result.Recovered, "confirmed", result.Confirmed, "pending", result.Pending)
+	if result.Recovered > 0 || result.Confirmed > 0 {
+		a.Notifier.CrawlUpdated(input.UserID, input.URLID, "")
+	}
+	return result, nil
+}
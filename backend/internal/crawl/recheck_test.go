@@ -0,0 +1,138 @@
+package crawl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"sykell-backend/internal/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecheckDelay(t *testing.T) {
+	input := LinkRecheckInput{Delay: 5 * time.Minute, MaxDelay: 30 * time.Minute}
+	want := []time.Duration{5 * time.Minute, 10 * time.Minute, 20 * time.Minute, 30 * time.Minute, 30 * time.Minute}
+	for round, expected := range want {
+		assert.Equal(t, expected, recheckDelay(input, round), "round %d", round)
+	}
+}
+
+func TestRepo_RecordLinkRecovered(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	code := 200
+	link := PendingLink{ID: "link-1", AbsoluteURL: "https://example.com/docs", IsInternal: true, Occurrences: 3, ConsecutiveFailures: 1}
+
+	// The link and the crawl's counts are updated together
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE crawl_links").
+		WithArgs(200, utils.LinkReasonOK, nil, "link-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE crawls").
+		WithArgs(3, true, 3, true, 3, "crawl-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = NewRepo(mockDB).RecordLinkRecovered(context.Background(), "crawl-1", link, utils.LinkStatus{StatusCode: &code, Reason: utils.LinkReasonOK})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepo_RecordLinkRecheckFailureOncePerRound(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	// The round is stored and compared so a retried round does not count the failure again
+	code := 404
+	mock.ExpectExec("UPDATE crawl_links").
+		WithArgs(404, utils.LinkReasonHTTPError, nil, 3, 2, "link-1", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = NewRepo(mockDB).RecordLinkRecheckFailure(context.Background(), "link-1", utils.LinkStatus{StatusCode: &code, Reason: utils.LinkReasonHTTPError}, 3, 2)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// recheckRepo keeps the pending links of a crawl in memory
+type recheckRepo struct {
+	*memoryRepo
+	links     []PendingLink
+	failures  map[string]int
+	recovered []string
+}
+
+func (r *recheckRepo) ListPendingLinks(ctx context.Context, crawlID string) ([]PendingLink, error) {
+	return append([]PendingLink(nil), r.links...), nil
+}
+
+func (r *recheckRepo) RecordLinkRecheckFailure(ctx context.Context, linkID string, status utils.LinkStatus, confirmations int, round int) error {
+	for i := range r.links {
+		if r.links[i].ID == linkID && r.links[i].RecheckRound < round {
+			r.links[i].RecheckRound = round
+			r.failures[linkID]++
+		}
+	}
+	return nil
+}
+
+func (r *recheckRepo) RecordLinkRecovered(ctx context.Context, crawlID string, link PendingLink, status utils.LinkStatus) error {
+	r.recovered = append(r.recovered, link.ID)
+	for i := range r.links {
+		if r.links[i].ID == link.ID {
+			r.links = append(r.links[:i], r.links[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (r *recheckRepo) ConfirmPendingLinks(ctx context.Context, crawlID string) (int64, error) {
+	confirmed := int64(len(r.links))
+	r.links = nil
+	return confirmed, nil
+}
+
+func TestRecheckLinksActivityRetriedRound(t *testing.T) {
+	site := newFixtureSite(t)
+	a, memory, _ := newTestActivities(t)
+	repo := &recheckRepo{
+		memoryRepo: memory,
+		failures:   make(map[string]int),
+		links: []PendingLink{
+			// The failed attempt of the round already recorded the first link
+			{ID: "link-1", AbsoluteURL: site.URL + "/missing", IsInternal: true, ConsecutiveFailures: 2, RecheckRound: 1},
+			{ID: "link-2", AbsoluteURL: site.URL + "/broken", IsInternal: true, ConsecutiveFailures: 1},
+			{ID: "link-3", AbsoluteURL: site.URL + "/about", IsInternal: true, ConsecutiveFailures: 1},
+		},
+	}
+	a.Repo = repo
+	input := LinkRecheckInput{CrawlID: "crawl-1", URLID: "url-1", UserID: "user-1", Confirmations: 5, Round: 1}
+
+	result, err := a.RecheckLinksActivity(context.Background(), input)
+	require.NoError(t, err)
+	assert.Equal(t, LinkRecheckResult{Recovered: 1, Pending: 2}, result)
+	assert.Zero(t, site.Hits("/missing"))
+	assert.Equal(t, map[string]int{"link-2": 1}, repo.failures)
+	assert.Equal(t, []string{"link-3"}, repo.recovered)
+
+	// Links still pending after the final round are confirmed
+	input.Round, input.Final = 2, true
+	result, err = a.RecheckLinksActivity(context.Background(), input)
+	require.NoError(t, err)
+	assert.Equal(t, LinkRecheckResult{Confirmed: 2}, result)
+	assert.Equal(t, map[string]int{"link-1": 1, "link-2": 2}, repo.failures)
+}
+
+func TestRecheckRound(t *testing.T) {
+	input := LinkRecheckInput{CrawlID: "crawl-1", Confirmations: 3}
+	assert.Equal(t, 1, recheckRound(input, 0, 6).Round)
+	assert.False(t, recheckRound(input, 0, 6).Final)
+	assert.Equal(t, 6, recheckRound(input, 5, 6).Round)
+	assert.True(t, recheckRound(input, 5, 6).Final)
+}
@@ -0,0 +1,230 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sykell-backend/internal/db"
+	"time"
+
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+const (
+	// reconcileBatchSize is how many crawls are listed at a time by a reconcile pass
+	reconcileBatchSize = 100
+	// reconcileStoppedLookback is how long after a stop the workflow of a stopped crawl is still checked
+	reconcileStoppedLookback = 24 * time.Hour
+	// reconcilePassesPerRun bounds the workflow history, the workflow continues as new after that many passes
+	reconcilePassesPerRun = 100
+	// maxCrawlRepairs is how many repairs are listed for a user
+	maxCrawlRepairs = 100
+)
+
+// ReconcileWorkflow periodically checks the active crawls against the state of their workflows and repairs
+// the crawls left behind by a dead worker, a failed workflow start or a failed cancel
+func ReconcileWorkflow(ctx workflow.Context, input ReconcileInput) error {
+	logger := workflow.GetLogger(ctx)
+
+	var report ReconcileReport
+	if err := workflow.SetQueryHandler(ctx, ReconcileReportQueryName, func() (ReconcileReport, error) {
+		return report, nil
+	}); err != nil {
+		return err
+	}
+
+	activityOptions := workflow.ActivityOptions{
+		StartToCloseTimeout: 30 * time.Minute,
+		HeartbeatTimeout:    time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute,
+			MaximumAttempts:    3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, activityOptions)
+
+	for passes := 0; passes < reconcilePassesPerRun; passes++ {
+		// A failed pass is logged and the crawls are checked again in the next one
+		var pass ReconcileReport
+		if err := workflow.ExecuteActivity(ctx, activities.ReconcileCrawlsActivity).Get(ctx, &pass); err != nil {
+			logger.Error("Reconcile pass failed", "error", err)
+		} else {
+			report = pass
+		}
+		if err := workflow.Sleep(ctx, input.Interval); err != nil {
+			return err
+		}
+	}
+	return workflow.NewContinueAsNewError(ctx, ReconcileWorkflow, input)
+}
+
+// ReconcileCrawlsActivity checks every active crawl not updated for the configured grace period, and every
+// recently stopped crawl, against the state of its workflow and repairs the crawls that do not match it
+func (a *Activities) ReconcileCrawlsActivity(ctx context.Context) (ReconcileReport, error) {
+	logger := activityLogger(ctx)
+	var report ReconcileReport
+
+	repo := a.Repo
+	temporalClient := activity.GetClient(ctx)
+
+	// A retried pass resumes after the last crawl it checked
+	var cursor string
+	if !heartbeatDetails(ctx, &cursor) {
+		cursor = ""
+	}
+
+	now := time.Now()
+	updatedBefore := now.Add(-a.Config.ReconcileGrace)
+	stoppedAfter := now.Add(-reconcileStoppedLookback)
+	for {
+		candidates, err := repo.ListCrawlsForReconciliation(ctx, cursor, updatedBefore, stoppedAfter, reconcileBatchSize)
+		if err != nil {
+			return report, fmt.Errorf("failed to list crawls: %w", err)
+		}
+		for _, candidate := range candidates {
+			recordHeartbeat(ctx, cursor)
+			report.Checked++
+			if err := reconcileCrawl(ctx, repo, temporalClient, a.Notifier, candidate, &report); err != nil {
+				logger.Error("Failed to reconcile crawl", "error", err, "crawl_id", candidate.CrawlID, "workflow_id", candidate.WorkflowID)
+				report.Failed++
+			}
+			cursor = candidate.CrawlID
+		}
+		if len(candidates) < reconcileBatchSize {
+			break
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	logger.Info("Reconcile pass completed", "checked", report.Checked, "repaired", report.Repaired, "canceled", report.Canceled, "failed", report.Failed)
+	return report, nil
+}
+
+// reconcileCrawl describes the workflow of the crawl and repairs the crawl when it does not match it
+func reconcileCrawl(ctx context.Context, repo Repo, temporalClient client.Client, notifier Notifier, candidate ReconcileCandidate, report *ReconcileReport) error {
+	logger := activityLogger(ctx)
+
+	found := true
+	cancelRequested := false
+	var workflowStatus enums.WorkflowExecutionStatus
+	description, err := temporalClient.DescribeWorkflowExecution(ctx, candidate.WorkflowID, "")
+	var notFound *serviceerror.NotFound
+	switch {
+	case errors.As(err, &notFound):
+		found = false
+	case err != nil:
+		return fmt.Errorf("failed to describe workflow: %w", err)
+	default:
+		workflowStatus = description.GetWorkflowExecutionInfo().GetStatus()
+		cancelRequested = description.GetWorkflowExtendedInfo().GetCancelRequested()
+	}
+
+	repair := planRepair(candidate.Status, workflowStatus, found, cancelRequested)
+	if repair == nil {
+		return nil
+	}
+	if repair.CancelWorkflow {
+		err := temporalClient.CancelWorkflow(ctx, candidate.WorkflowID, "")
+		if err != nil && !errors.As(err, &notFound) {
+			return fmt.Errorf("failed to cancel workflow: %w", err)
+		}
+	}
+
+	repaired, err := repo.RepairCrawl(ctx, candidate, *repair)
+	if err != nil {
+		return fmt.Errorf("failed to repair crawl: %w", err)
+	}
+	if !repaired {
+		return nil
+	}
+
+	logger.Warn("Crawl repaired", "crawl_id", candidate.CrawlID, "workflow_id", candidate.WorkflowID, "previous_status", candidate.Status, "status", repair.NewStatus, "workflow_status", repair.WorkflowStatus, "reason", repair.Reason)
+	if repair.CancelWorkflow {
+		report.Canceled++
+		return nil
+	}
+	report.Repaired++
+	notifier.CrawlUpdated(candidate.UserID, candidate.URLID, repair.NewStatus)
+	return nil
+}
+
+// planRepair decides how a crawl with the given status is repaired from the status of its workflow, found is
+// false when the workflow does not exist and cancelRequested is true when the workflow was already asked to cancel.
+// It returns nil when the crawl matches its workflow or a cancel of its workflow is still pending
+func planRepair(status string, workflowStatus enums.WorkflowExecutionStatus, found bool, cancelRequested bool) *CrawlRepair {
+	stopped := status == string(db.CrawlsStatusStopped)
+	if !found {
+		if stopped {
+			return nil
+		}
+		reason := "The workflow of the crawl no longer exists"
+		if status == string(db.CrawlsStatusQueued) {
+			reason = "The crawl was queued but its workflow was never started"
+		}
+		return &CrawlRepair{NewStatus: string(db.CrawlsStatusError), Reason: reason}
+	}
+
+	name := workflowStatus.String()
+	if workflowStatus == enums.WORKFLOW_EXECUTION_STATUS_RUNNING {
+		if !stopped || cancelRequested {
+			return nil
+		}
+		return &CrawlRepair{
+			NewStatus:      status,
+			WorkflowStatus: name,
+			Reason:         "The crawl was stopped but its workflow was still running, the workflow was canceled",
+			CancelWorkflow: true,
+		}
+	}
+	if stopped {
+		return nil
+	}
+
+	switch workflowStatus {
+	case enums.WORKFLOW_EXECUTION_STATUS_CANCELED:
+		return &CrawlRepair{NewStatus: string(db.CrawlsStatusStopped), WorkflowStatus: name, Reason: "The workflow of the crawl was canceled"}
+	case enums.WORKFLOW_EXECUTION_STATUS_COMPLETED:
+		return &CrawlRepair{NewStatus: string(db.CrawlsStatusError), WorkflowStatus: name, Reason: "The workflow of the crawl completed without recording its result"}
+	case enums.WORKFLOW_EXECUTION_STATUS_TERMINATED:
+		return &CrawlRepair{NewStatus: string(db.CrawlsStatusError), WorkflowStatus: name, Reason: "The workflow of the crawl was terminated"}
+	case enums.WORKFLOW_EXECUTION_STATUS_TIMED_OUT:
+		return &CrawlRepair{NewStatus: string(db.CrawlsStatusError), WorkflowStatus: name, Reason: "The workflow of the crawl timed out"}
+	case enums.WORKFLOW_EXECUTION_STATUS_FAILED:
+		return &CrawlRepair{NewStatus: string(db.CrawlsStatusError), WorkflowStatus: name, Reason: "The workflow of the crawl failed"}
+	default:
+		return &CrawlRepair{NewStatus: string(db.CrawlsStatusError), WorkflowStatus: name, Reason: fmt.Sprintf("The workflow of the crawl closed with status %s", name)}
+	}
+}
+
+// StartReconciler starts the reconcile workflow, or leaves it as it is when it is already running
+func StartReconciler(ctx context.Context, temporalClient client.Client, interval time.Duration) error {
+	workflowOptions := client.StartWorkflowOptions{
+		ID:                       ReconcileWorkflowID,
+		TaskQueue:                ScheduledTaskQueueName,
+		WorkflowIDConflictPolicy: enums.WORKFLOW_ID_CONFLICT_POLICY_USE_EXISTING,
+	}
+	_, err := temporalClient.ExecuteWorkflow(ctx, workflowOptions, ReconcileWorkflowName, ReconcileInput{Interval: interval})
+	return err
+}
+
+// StopReconciler cancels the reconcile workflow when it is running
+func StopReconciler(ctx context.Context, temporalClient client.Client) error {
+	err := temporalClient.CancelWorkflow(ctx, ReconcileWorkflowID, "")
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		return nil
+	}
+	return err
+}
+
+// ListCrawlRepairs returns the latest repairs the reconciler made to the crawls of the user
+func (s *CrawlService) ListCrawlRepairs(ctx context.Context, userID string) ([]CrawlRepairResponse, error) {
+	return s.repo.ListCrawlRepairs(ctx, userID, maxCrawlRepairs)
+}
@@ -0,0 +1,46 @@
+package crawl
+
+import (
+	"sykell-backend/internal/db"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/api/enums/v1"
+)
+
+func TestPlanRepair(t *testing.T) {
+	running := string(db.CrawlsStatusRunning)
+	stopped := string(db.CrawlsStatusStopped)
+
+	// Crawls matching their workflow are left alone
+	assert.Nil(t, planRepair(running, enums.WORKFLOW_EXECUTION_STATUS_RUNNING, true, false))
+	assert.Nil(t, planRepair(string(db.CrawlsStatusPaused), enums.WORKFLOW_EXECUTION_STATUS_RUNNING, true, false))
+	assert.Nil(t, planRepair(stopped, enums.WORKFLOW_EXECUTION_STATUS_CANCELED, true, false))
+	assert.Nil(t, planRepair(stopped, enums.WORKFLOW_EXECUTION_STATUS_UNSPECIFIED, false, false))
+
+	// A queued crawl whose workflow was never started
+	repair := planRepair(string(db.CrawlsStatusQueued), enums.WORKFLOW_EXECUTION_STATUS_UNSPECIFIED, false, false)
+	require.NotNil(t, repair)
+	assert.Equal(t, string(db.CrawlsStatusError), repair.NewStatus)
+	assert.Empty(t, repair.WorkflowStatus)
+	assert.Contains(t, repair.Reason, "never started")
+
+	// A running crawl whose worker died before the workflow was canceled or terminated
+	repair = planRepair(running, enums.WORKFLOW_EXECUTION_STATUS_CANCELED, true, false)
+	require.NotNil(t, repair)
+	assert.Equal(t, stopped, repair.NewStatus)
+	repair = planRepair(running, enums.WORKFLOW_EXECUTION_STATUS_TERMINATED, true, false)
+	require.NotNil(t, repair)
+	assert.Equal(t, string(db.CrawlsStatusError), repair.NewStatus)
+	assert.Equal(t, "Terminated", repair.WorkflowStatus)
+
+	// A stopped crawl whose workflow could not be canceled
+	repair = planRepair(stopped, enums.WORKFLOW_EXECUTION_STATUS_RUNNING, true, false)
+	require.NotNil(t, repair)
+	assert.True(t, repair.CancelWorkflow)
+	assert.Equal(t, stopped, repair.NewStatus)
+
+	// A stopped crawl whose workflow was already asked to cancel is left alone until the workflow closes
+	assert.Nil(t, planRepair(stopped, enums.WORKFLOW_EXECUTION_STATUS_RUNNING, true, true))
+}
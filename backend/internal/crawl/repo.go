@@ -3,22 +3,57 @@ package crawl
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
 	"sykell-backend/internal/config"
 	"sykell-backend/internal/db"
+	"sykell-backend/internal/quota"
+	"sykell-backend/internal/utils"
+	"time"
 )
 
 // Repo defines the interface for crawl repository operations
 type Repo interface {
 	GetCrawlIDByWorkflowID(ctx context.Context, workflowID string) (string, error)
-	QueueCrawl(ctx context.Context, urlID string, workflowID string) error
+	QueueCrawl(ctx context.Context, crawl QueuedCrawl) (bool, error)
 	CountOfActiveCrawlForUrlId(ctx context.Context, urlID string) (int64, error)
+	CountActiveCrawlsForUser(ctx context.Context, userID string, priority string) (int64, error)
 	GetUrlByIdAndUserId(ctx context.Context, urlID string, userID string) (*URLResponse, error)
-	UpdateCrawlResult(ctx context.Context, crawlID string, htmlVersion string, pageTitle string, h1Count int32, h2Count int32, h3Count int32, h4Count int32, h5Count int32, h6Count int32, internalLinksCount int32, externalLinksCount int32, inaccessableLinksCount int32, hasLoginForm bool, status string) error
-	CreateInaccessibleLink(ctx context.Context, crawlID string, href string, absoluteURL string, isInternal bool, statusCode int, anchorText string) error
+	ListUrlIDsByFilter(ctx context.Context, userID string, query string, sortBy string, sortDir string, limit int) ([]string, error)
+	UpdateCrawlResult(ctx context.Context, crawlID string, htmlVersion string, pageTitle string, h1Count int32, h2Count int32, h3Count int32, h4Count int32, h5Count int32, h6Count int32, internalLinksCount int32, externalLinksCount int32, inaccessableLinksCount int32, brokenAnchorsCount int32, hasLoginForm bool, status string) error
+	SaveInaccessibleLinks(ctx context.Context, crawlID string, links []utils.LinkInfo) error
+	ListPendingLinks(ctx context.Context, crawlID string) ([]PendingLink, error)
+	RecordLinkRecheckFailure(ctx context.Context, linkID string, status utils.LinkStatus, confirmations int, round int) error
+	RecordLinkRecovered(ctx context.Context, crawlID string, link PendingLink, status utils.LinkStatus) error
+	ConfirmPendingLinks(ctx context.Context, crawlID string) (int64, error)
 	SetCrawlError(ctx context.Context, crawlID string, errorMessage string) error
+	FailActiveCrawls(ctx context.Context, workflowPrefix string, errorMessage string) (int64, error)
 	SetCrawlRunning(ctx context.Context, crawlID string) error
 	SetCrawlStopped(ctx context.Context, crawlID string) error
+	SetCrawlPaused(ctx context.Context, crawlID string) error
 	GetActiveCrawlsForUrlId(ctx context.Context, urlID string) ([]CrawlResponse, error) 
+	UpdateCrawlLinkCounts(ctx context.Context, crawlID string, counts LinkCounts) error
+	UpdateCrawlFingerprint(ctx context.Context, crawlID string, contentHash string, simhash uint64) error
+	SaveCrawlForms(ctx context.Context, crawlID string, forms []utils.FormInfo) error
+	GetLatestCrawlForms(ctx context.Context, urlID string) ([]FormResponse, error)
+	UpdateCrawlOutcome(ctx context.Context, crawlID string, outcome string, mimeType string, charset string, contentLength int64) error
+	SetCrawlDone(ctx context.Context, crawlID string) error
+	UpdateCrawlSnapshot(ctx context.Context, crawlID string, snapshotHash string, snapshotSize int64, headers http.Header) error
+	GetCrawlSnapshot(ctx context.Context, crawlID string, userID string) (*SnapshotResponse, error)
+	SetCrawlAnalysisVersion(ctx context.Context, crawlID string, version int) error
+	UpdateCrawlAnalysis(ctx context.Context, crawlID string, analysis pageAnalysis, version int) error
+	ListCrawlsForReanalysis(ctx context.Context, userID string, cursor string, targetVersion int, limit int) ([]ReanalysisCandidate, error)
+	GetCrawlForReanalysis(ctx context.Context, crawlID string, userID string, targetVersion int) (*ReanalysisCandidate, error)
+	GetRequestProfile(ctx context.Context, urlID string) (*StoredRequestProfile, error)
+	GetCachedLinkStatus(ctx context.Context, absoluteURL string) (utils.LinkStatus, bool, error)
+	CacheLinkStatus(ctx context.Context, absoluteURL string, status utils.LinkStatus, ttl time.Duration) error
+	DeleteExpiredLinkStatuses(ctx context.Context, limit int) (int64, error)
+	UpdateCrawlLinkCacheStats(ctx context.Context, crawlID string, hits int, misses int) error
+	GetLinkCacheStats(ctx context.Context, userID string) (*LinkCacheStatsResponse, error)
+	ListCrawlsForReconciliation(ctx context.Context, cursor string, updatedBefore time.Time, stoppedAfter time.Time, limit int) ([]ReconcileCandidate, error)
+	RepairCrawl(ctx context.Context, candidate ReconcileCandidate, repair CrawlRepair) (bool, error)
+	ListCrawlRepairs(ctx context.Context, userID string, limit int) ([]CrawlRepairResponse, error)
 }
 
 // crawlRepo is the concrete implementation of the Repo interface
@@ -45,16 +80,79 @@ func (r *crawlRepo) GetCrawlIDByWorkflowID(ctx context.Context, workflowID strin
 	return crawl.ID, nil
 }
 
-// QueueCrawl adds a new crawl to the queue for the specified URL and workflow ID
-func (r *crawlRepo) QueueCrawl(ctx context.Context, urlID string, workflowID string) error {
+// QueueCrawl adds a new crawl to the queue for its URL and workflow ID, unless the URL already has an active crawl.
+// The checks and the insert hold a lock on the user and on the URL so concurrent starts queue one crawl of a URL
+// at most and never exceed the user's limits, it returns false when no crawl was queued, ErrCrawlLimitReached when
+// the user has too many active crawls of the priority and a quota.LimitError when the plan does not allow the crawl
+func (r *crawlRepo) QueueCrawl(ctx context.Context, crawl QueuedCrawl) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	queries := db.New(r.sqlDB).WithTx(tx)
+
+	if _, err := queries.LockUserQuota(ctx, crawl.UserID); err != nil {
+		return false, err
+	}
+	if _, err := queries.LockUrlForCrawl(ctx, crawl.URLID); err != nil {
+		return false, err
+	}
+	active, err := queries.CountOfActiveCrawlForUrlId(ctx, crawl.URLID)
+	if err != nil {
+		return false, err
+	}
+	if active > 0 {
+		return false, nil
+	}
+
+	if crawl.ActiveLimit > 0 {
+		userActive, err := queries.CountActiveCrawlsForUser(ctx, db.CountActiveCrawlsForUserParams{
+			UserID:   crawl.UserID,
+			Priority: db.CrawlsPriority(crawl.Priority),
+		})
+		if err != nil {
+			return false, err
+		}
+		if userActive >= int64(crawl.ActiveLimit) {
+			return false, ErrCrawlLimitReached
+		}
+	}
+	if crawl.Quotas != nil {
+		quotas := crawl.Quotas.WithRepo(quota.NewRepo(tx))
+		if err := quotas.CheckCrawls(ctx, crawl.UserID, 1); err != nil {
+			return false, err
+		}
+		if scheduledPriority(crawl.Priority) {
+			if err := quotas.CheckScheduleInterval(ctx, crawl.UserID, crawl.URLID); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	_, err = queries.QueueCrawl(ctx, db.QueueCrawlParams{
+		ID: crawl.CrawlID,
+		UrlID: crawl.URLID,
+		Priority: db.CrawlsPriority(crawl.Priority),
+		WorkflowID: crawl.WorkflowID,
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// CountActiveCrawlsForUser returns the count of active crawls of the given priority across all URLs of the user
+func (r *crawlRepo) CountActiveCrawlsForUser(ctx context.Context, userID string, priority string) (int64, error) {
 	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
 	defer cancel()
 	queries := db.New(r.sqlDB)
-	_, err := queries.QueueCrawl(ctx, db.QueueCrawlParams{
-		UrlID: urlID,
-		WorkflowID: workflowID,
+	return queries.CountActiveCrawlsForUser(ctx, db.CountActiveCrawlsForUserParams{
+		UserID:   userID,
+		Priority: db.CrawlsPriority(priority),
 	})
-	return err
 }
 
 // CountOfActiveCrawlForUrlId returns the count of active crawls for the specified URL ID
@@ -88,8 +186,31 @@ func (r *crawlRepo) GetUrlByIdAndUserId(ctx context.Context, urlID string, userI
 	}, nil
 }
 
+// ListUrlIDsByFilter retrieves the IDs of the user's URLs matching the URL list filter, in the list's order
+func (r *crawlRepo) ListUrlIDsByFilter(ctx context.Context, userID string, query string, sortBy string, sortDir string, limit int) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	rows, err := queries.GetUrlsWithLatestCrawlsFiltered(ctx, db.GetUrlsWithLatestCrawlsFilteredParams{
+		UserID:      userID,
+		QueryFilter: query,
+		SortBy:      sortBy,
+		SortDir:     sortDir,
+		Limit:       int32(limit),
+		Offset:      0,
+	})
+	if err != nil {
+		return nil, err
+	}
+	urlIDs := make([]string, len(rows))
+	for i, row := range rows {
+		urlIDs[i] = row.UrlID
+	}
+	return urlIDs, nil
+}
+
 // UpdateCrawlResult updates the results of a crawl with the provided metrics
-func (r *crawlRepo) UpdateCrawlResult(ctx context.Context, crawlID string, htmlVersion string, pageTitle string, h1Count int32, h2Count int32, h3Count int32, h4Count int32, h5Count int32, h6Count int32, internalLinksCount int32, externalLinksCount int32, inaccessableLinksCount int32, hasLoginForm bool, status string) error {
+func (r *crawlRepo) UpdateCrawlResult(ctx context.Context, crawlID string, htmlVersion string, pageTitle string, h1Count int32, h2Count int32, h3Count int32, h4Count int32, h5Count int32, h6Count int32, internalLinksCount int32, externalLinksCount int32, inaccessableLinksCount int32, brokenAnchorsCount int32, hasLoginForm bool, status string) error {
 	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
 	defer cancel()
 	queries := db.New(r.sqlDB)
@@ -106,26 +227,142 @@ func (r *crawlRepo) UpdateCrawlResult(ctx context.Context, crawlID string, htmlV
 		InternalLinksCount:    sql.NullInt32{Int32: internalLinksCount, Valid: true},
 		ExternalLinksCount:    sql.NullInt32{Int32: externalLinksCount, Valid: true},
 		InaccessibleLinksCount: sql.NullInt32{Int32: inaccessableLinksCount, Valid: true},
+		BrokenAnchorsCount:    sql.NullInt32{Int32: brokenAnchorsCount, Valid: true},
 		HasLoginForm:          hasLoginForm,
 		Status:				db.CrawlsStatus(status),
 	})
 	return err
 }
 
-// CreateInaccessibleLink creates a new record for an inaccessible link found during a crawl
-func (r *crawlRepo) CreateInaccessibleLink(ctx context.Context, crawlID string, href string, absoluteURL string, isInternal bool, statusCode int, anchorText string) error {
+// SaveInaccessibleLinks replaces the inaccessible links recorded for a crawl in a single transaction,
+// a URL linked several times is recorded once with the number of occurrences
+func (r *crawlRepo) SaveInaccessibleLinks(ctx context.Context, crawlID string, links []utils.LinkInfo) error {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	queries := db.New(r.sqlDB).WithTx(tx)
+
+	// Remove links saved by a previous attempt of the same crawl
+	if err := queries.DeleteCrawlLinksByCrawlId(ctx, crawlID); err != nil {
+		return err
+	}
+
+	var unique []utils.LinkInfo
+	occurrences := make(map[string]uint32)
+	for _, link := range links {
+		if occurrences[link.AbsoluteURL] == 0 {
+			unique = append(unique, link)
+		}
+		occurrences[link.AbsoluteURL]++
+	}
+
+	for _, link := range unique {
+		params := db.CreateInaccessibleLinkParams{
+			CrawlID:     crawlID,
+			Href:        link.Href,
+			AbsoluteUrl: link.AbsoluteURL,
+			IsInternal:  link.IsInternal,
+			Reason:      sql.NullString{String: link.Reason, Valid: link.Reason != ""},
+			RedirectUrl: sql.NullString{String: link.RedirectURL, Valid: link.RedirectURL != ""},
+			AnchorText:  sql.NullString{String: link.AnchorText, Valid: link.AnchorText != ""},
+			Occurrences: occurrences[link.AbsoluteURL],
+		}
+		if link.StatusCode != nil {
+			params.StatusCode = sql.NullInt32{Int32: int32(*link.StatusCode), Valid: true}
+		}
+		if _, err := queries.CreateInaccessibleLink(ctx, params); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ListPendingLinks returns the inaccessible links of a crawl that are neither confirmed broken nor recovered
+func (r *crawlRepo) ListPendingLinks(ctx context.Context, crawlID string) ([]PendingLink, error) {
 	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
 	defer cancel()
 	queries := db.New(r.sqlDB)
-	_, err := queries.CreateInaccessibleLink(ctx, db.CreateInaccessibleLinkParams{
-		CrawlID:     crawlID,
-		Href:        href,
-		AbsoluteUrl: absoluteURL,
-		IsInternal:  isInternal,
-		StatusCode:  sql.NullInt32{Int32: int32(statusCode), Valid: true},
-		AnchorText:  sql.NullString{String: anchorText, Valid: anchorText != ""},
+	rows, err := queries.GetPendingCrawlLinks(ctx, crawlID)
+	if err != nil {
+		return nil, err
+	}
+	links := make([]PendingLink, 0, len(rows))
+	for _, row := range rows {
+		links = append(links, PendingLink{
+			ID:                  row.ID,
+			AbsoluteURL:         row.AbsoluteUrl,
+			IsInternal:          row.IsInternal,
+			Occurrences:         int(row.Occurrences),
+			ConsecutiveFailures: int(row.ConsecutiveFailures),
+			RecheckRound:        int(row.RecheckRound),
+		})
+	}
+	return links, nil
+}
+
+// RecordLinkRecheckFailure stores the status of a link that failed again in the recheck round, it is confirmed
+// broken once it has failed the given number of times in a row. A failure is counted once per round
+func (r *crawlRepo) RecordLinkRecheckFailure(ctx context.Context, linkID string, status utils.LinkStatus, confirmations int, round int) error {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	params := db.UpdateCrawlLinkRecheckFailureParams{
+		Reason:        sql.NullString{String: status.Reason, Valid: status.Reason != ""},
+		RedirectUrl:   sql.NullString{String: status.RedirectURL, Valid: status.RedirectURL != ""},
+		Confirmations: confirmations,
+		Round:         uint32(round),
+		ID:            linkID,
+	}
+	if status.StatusCode != nil {
+		params.StatusCode = sql.NullInt32{Int32: int32(*status.StatusCode), Valid: true}
+	}
+	return queries.UpdateCrawlLinkRecheckFailure(ctx, params)
+}
+
+// RecordLinkRecovered marks a link as accessible again and moves its occurrences out of the crawl's
+// inaccessible links count in a single transaction
+func (r *crawlRepo) RecordLinkRecovered(ctx context.Context, crawlID string, link PendingLink, status utils.LinkStatus) error {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	queries := db.New(r.sqlDB).WithTx(tx)
+
+	params := db.UpdateCrawlLinkRecoveredParams{
+		Reason:      sql.NullString{String: status.Reason, Valid: status.Reason != ""},
+		RedirectUrl: sql.NullString{String: status.RedirectURL, Valid: status.RedirectURL != ""},
+		ID:          link.ID,
+	}
+	if status.StatusCode != nil {
+		params.StatusCode = sql.NullInt32{Int32: int32(*status.StatusCode), Valid: true}
+	}
+	if err := queries.UpdateCrawlLinkRecovered(ctx, params); err != nil {
+		return err
+	}
+	err = queries.MoveRecoveredLinkCounts(ctx, db.MoveRecoveredLinkCountsParams{
+		Occurrences: link.Occurrences,
+		IsInternal:  link.IsInternal,
+		ID:          crawlID,
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ConfirmPendingLinks confirms every pending link of a crawl as broken, used when rechecks are disabled
+func (r *crawlRepo) ConfirmPendingLinks(ctx context.Context, crawlID string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	return queries.ConfirmPendingCrawlLinks(ctx, crawlID)
 }
 
 // SetCrawlError sets the error message for a crawl that encountered an error
@@ -140,6 +377,18 @@ func (r *crawlRepo) SetCrawlError(ctx context.Context, crawlID string, errorMess
 	return err
 }
 
+// FailActiveCrawls sets the error message on every queued, running or paused crawl whose workflow ID starts with
+// the prefix and returns how many there were
+func (r *crawlRepo) FailActiveCrawls(ctx context.Context, workflowPrefix string, errorMessage string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	return queries.FailActiveCrawls(ctx, db.FailActiveCrawlsParams{
+		ErrorMessage: sql.NullString{String: errorMessage, Valid: true},
+		Prefix:       workflowPrefix,
+	})
+}
+
 // SetCrawlRunning updates the status of a crawl to "running"
 func (r *crawlRepo) SetCrawlRunning(ctx context.Context, crawlID string) error {
 	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
@@ -158,6 +407,52 @@ func (r *crawlRepo) SetCrawlStopped(ctx context.Context, crawlID string) error {
 	return err
 }
 
+// SetCrawlPaused updates the status of a crawl to "paused"
+func (r *crawlRepo) SetCrawlPaused(ctx context.Context, crawlID string) error {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	return queries.SetCrawlPaused(ctx, crawlID)
+}
+
+// UpdateCrawlLinkCounts stores the number of links of a crawl in each category
+func (r *crawlRepo) UpdateCrawlLinkCounts(ctx context.Context, crawlID string, counts LinkCounts) error {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	return queries.UpdateCrawlLinkCounts(ctx, db.UpdateCrawlLinkCountsParams{
+		InternalLinksCount:     sql.NullInt32{Int32: int32(counts.Internal), Valid: true},
+		ExternalLinksCount:     sql.NullInt32{Int32: int32(counts.External), Valid: true},
+		InaccessibleLinksCount: sql.NullInt32{Int32: int32(counts.Inaccessible), Valid: true},
+		BrokenAnchorsCount:     sql.NullInt32{Int32: int32(counts.BrokenAnchor), Valid: true},
+		ID:                     crawlID,
+	})
+}
+
+// SetCrawlDone updates the status of a crawl to "done" without storing any page metrics
+func (r *crawlRepo) SetCrawlDone(ctx context.Context, crawlID string) error {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	err := queries.SetCrawlDone(ctx, crawlID)
+	return err
+}
+
+// UpdateCrawlOutcome records the fetch outcome and the detected type, encoding and size of the crawled resource
+func (r *crawlRepo) UpdateCrawlOutcome(ctx context.Context, crawlID string, outcome string, mimeType string, charset string, contentLength int64) error {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	err := queries.UpdateCrawlOutcome(ctx, db.UpdateCrawlOutcomeParams{
+		ID:            crawlID,
+		Outcome:       sql.NullString{String: outcome, Valid: outcome != ""},
+		MimeType:      sql.NullString{String: mimeType, Valid: mimeType != ""},
+		Charset:       sql.NullString{String: charset, Valid: charset != ""},
+		ContentLength: sql.NullInt64{Int64: contentLength, Valid: true},
+	})
+	return err
+}
+
 // GetActiveCrawlsForUrlId retrieves all active crawls for the specified URL ID
 func (r *crawlRepo) GetActiveCrawlsForUrlId(ctx context.Context, urlID string) ([]CrawlResponse, error) {
 	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
@@ -172,7 +467,457 @@ func (r *crawlRepo) GetActiveCrawlsForUrlId(ctx context.Context, urlID string) (
 		crawls[i] = CrawlResponse{
 			ID: row.ID,
 			WorkflowID: row.WorkflowID,
+			Status: string(row.Status),
 		}
 	}
 	return crawls, err
-}
\ No newline at end of file
+}
+
+// UpdateCrawlFingerprint stores the content hash and SimHash fingerprint of the crawled page,
+// the fingerprint is stored bit-for-bit in a signed BIGINT column
+func (r *crawlRepo) UpdateCrawlFingerprint(ctx context.Context, crawlID string, contentHash string, simhash uint64) error {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	err := queries.UpdateCrawlFingerprint(ctx, db.UpdateCrawlFingerprintParams{
+		ID:          crawlID,
+		ContentHash: sql.NullString{String: contentHash, Valid: contentHash != ""},
+		Simhash:     sql.NullInt64{Int64: int64(simhash), Valid: contentHash != ""},
+	})
+	return err
+}
+
+// SaveCrawlForms replaces the form inventory of a crawl and updates its form summary in a single transaction
+func (r *crawlRepo) SaveCrawlForms(ctx context.Context, crawlID string, forms []utils.FormInfo) error {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	queries := db.New(r.sqlDB).WithTx(tx)
+
+	// Remove forms saved by a previous attempt of the same crawl
+	if err := queries.DeleteCrawlFormsByCrawlId(ctx, crawlID); err != nil {
+		return err
+	}
+
+	var flaggedCount int32
+	for i, form := range forms {
+		fields, err := json.Marshal(form.Fields)
+		if err != nil {
+			return err
+		}
+		if form.IsFlagged() {
+			flaggedCount++
+		}
+		err = queries.CreateCrawlForm(ctx, db.CreateCrawlFormParams{
+			CrawlID:    crawlID,
+			Position:   uint32(i),
+			FormType:   form.Type,
+			Method:     form.Method,
+			Action:     form.Action,
+			Fields:     fields,
+			IsHttps:    form.IsHTTPS,
+			IsOffSite:  form.IsOffSite,
+			IsInsecure: form.IsInsecure,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	formTypes := strings.Join(utils.FormTypes(forms), ",")
+	err = queries.UpdateCrawlFormSummary(ctx, db.UpdateCrawlFormSummaryParams{
+		ID:                crawlID,
+		FormTypes:         sql.NullString{String: formTypes, Valid: true},
+		FlaggedFormsCount: sql.NullInt32{Int32: flaggedCount, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetLatestCrawlForms retrieves the forms found by the latest completed crawl of the specified URL ID
+func (r *crawlRepo) GetLatestCrawlForms(ctx context.Context, urlID string) ([]FormResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	result, err := queries.GetFormsForLatestCrawlByUrlId(ctx, urlID)
+	if err != nil {
+		return nil, err
+	}
+	forms := make([]FormResponse, len(result))
+	for i, row := range result {
+		var fields []utils.FormField
+		if err := json.Unmarshal(row.Fields, &fields); err != nil {
+			return nil, err
+		}
+		forms[i] = FormResponse{
+			CrawlID:    row.CrawlID,
+			Type:       row.FormType,
+			Method:     row.Method,
+			Action:     row.Action,
+			Fields:     fields,
+			IsHTTPS:    row.IsHttps,
+			IsOffSite:  row.IsOffSite,
+			IsInsecure: row.IsInsecure,
+			IsFlagged:  row.IsInsecure || row.IsOffSite,
+		}
+	}
+	return forms, nil
+}
+// UpdateCrawlSnapshot stores the content address of the archived raw HTML and the response headers of a crawl,
+// except the headers carrying credentials such as the cookies the site set
+func (r *crawlRepo) UpdateCrawlSnapshot(ctx context.Context, crawlID string, snapshotHash string, snapshotSize int64, headers http.Header) error {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	headersJSON, err := json.Marshal(snapshotHeaders(headers))
+	if err != nil {
+		return err
+	}
+	queries := db.New(r.sqlDB)
+	err = queries.UpdateCrawlSnapshot(ctx, db.UpdateCrawlSnapshotParams{
+		ID:              crawlID,
+		SnapshotHash:    sql.NullString{String: snapshotHash, Valid: snapshotHash != ""},
+		SnapshotSize:    sql.NullInt64{Int64: snapshotSize, Valid: snapshotHash != ""},
+		ResponseHeaders: headersJSON,
+	})
+	return err
+}
+
+// GetCrawlSnapshot retrieves the snapshot details of a crawl belonging to the specified user
+func (r *crawlRepo) GetCrawlSnapshot(ctx context.Context, crawlID string, userID string) (*SnapshotResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	row, err := queries.GetCrawlSnapshotByIdAndUserId(ctx, db.GetCrawlSnapshotByIdAndUserIdParams{
+		ID:     crawlID,
+		UserID: userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &SnapshotResponse{
+		CrawlID:  row.ID,
+		UrlID:    row.UrlID,
+		Hash:     row.SnapshotHash.String,
+		Size:     row.SnapshotSize.Int64,
+		MimeType: row.MimeType.String,
+		Charset:  row.Charset.String,
+		Headers:  map[string][]string{},
+	}
+	if len(row.ResponseHeaders) > 0 {
+		if err := json.Unmarshal(row.ResponseHeaders, &snapshot.Headers); err != nil {
+			return nil, err
+		}
+		// Snapshots archived before credential headers were dropped still have them
+		snapshot.Headers = snapshotHeaders(snapshot.Headers)
+	}
+	if row.FinishedAt.Valid {
+		snapshot.FetchedAt = &row.FinishedAt.Time
+	}
+	return snapshot, nil
+}
+
+// SetCrawlAnalysisVersion records the version of the extractors that analyzed a crawl
+func (r *crawlRepo) SetCrawlAnalysisVersion(ctx context.Context, crawlID string, version int) error {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	err := queries.SetCrawlAnalysisVersion(ctx, db.SetCrawlAnalysisVersionParams{
+		ID:              crawlID,
+		AnalysisVersion: uint32(version),
+	})
+	return err
+}
+
+// UpdateCrawlAnalysis replaces the page metrics of a crawl with a re-analysis of its snapshot, link metrics are kept
+func (r *crawlRepo) UpdateCrawlAnalysis(ctx context.Context, crawlID string, analysis pageAnalysis, version int) error {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	err := queries.UpdateCrawlAnalysis(ctx, db.UpdateCrawlAnalysisParams{
+		ID:              crawlID,
+		HtmlVersion:     sql.NullString{String: analysis.HtmlVersion, Valid: true},
+		PageTitle:       sql.NullString{String: analysis.PageTitle, Valid: true},
+		H1Count:         sql.NullInt32{Int32: analysis.H1Count, Valid: true},
+		H2Count:         sql.NullInt32{Int32: analysis.H2Count, Valid: true},
+		H3Count:         sql.NullInt32{Int32: analysis.H3Count, Valid: true},
+		H4Count:         sql.NullInt32{Int32: analysis.H4Count, Valid: true},
+		H5Count:         sql.NullInt32{Int32: analysis.H5Count, Valid: true},
+		H6Count:         sql.NullInt32{Int32: analysis.H6Count, Valid: true},
+		HasLoginForm:    analysis.HasLoginForm,
+		AnalysisVersion: uint32(version),
+	})
+	return err
+}
+
+// ListCrawlsForReanalysis retrieves the next page of archived crawls analyzed by an older version than the target,
+// ordered by crawl ID after the cursor, an empty user ID selects the crawls of every user
+func (r *crawlRepo) ListCrawlsForReanalysis(ctx context.Context, userID string, cursor string, targetVersion int, limit int) ([]ReanalysisCandidate, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	result, err := queries.ListCrawlsForReanalysis(ctx, db.ListCrawlsForReanalysisParams{
+		TargetVersion: uint32(targetVersion),
+		Cursor:        cursor,
+		UserFilter:    userID,
+		Limit:         int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]ReanalysisCandidate, len(result))
+	for i, row := range result {
+		candidate, err := toReanalysisCandidate(row.ID, row.NormalizedUrl, row.SnapshotHash.String, row.ResponseHeaders)
+		if err != nil {
+			return nil, err
+		}
+		candidates[i] = candidate
+	}
+	return candidates, nil
+}
+
+// GetCrawlForReanalysis retrieves an archived crawl analyzed by an older version than the target,
+// an empty user ID matches crawls of every user
+func (r *crawlRepo) GetCrawlForReanalysis(ctx context.Context, crawlID string, userID string, targetVersion int) (*ReanalysisCandidate, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	row, err := queries.GetCrawlForReanalysis(ctx, db.GetCrawlForReanalysisParams{
+		ID:            crawlID,
+		TargetVersion: uint32(targetVersion),
+		UserFilter:    userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	candidate, err := toReanalysisCandidate(row.ID, row.NormalizedUrl, row.SnapshotHash.String, row.ResponseHeaders)
+	if err != nil {
+		return nil, err
+	}
+	return &candidate, nil
+}
+
+// toReanalysisCandidate builds a re-analysis candidate, taking the content type from the recorded response headers
+func toReanalysisCandidate(crawlID string, url string, snapshotHash string, responseHeaders json.RawMessage) (ReanalysisCandidate, error) {
+	candidate := ReanalysisCandidate{
+		CrawlID:      crawlID,
+		URL:          url,
+		SnapshotHash: snapshotHash,
+	}
+	if len(responseHeaders) > 0 {
+		var headers http.Header
+		if err := json.Unmarshal(responseHeaders, &headers); err != nil {
+			return candidate, err
+		}
+		candidate.ContentType = headers.Get("Content-Type")
+	}
+	return candidate, nil
+}
+
+// GetRequestProfile retrieves the stored request profile of a URL, or nil when it has none
+func (r *crawlRepo) GetRequestProfile(ctx context.Context, urlID string) (*StoredRequestProfile, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	row, err := queries.GetRequestProfileByUrlId(ctx, urlID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &StoredRequestProfile{
+		UrlID:                row.UrlID,
+		UserAgent:            row.UserAgent.String,
+		BasicAuthUsername:    row.BasicAuthUsername.String,
+		EncryptedSecrets:     row.EncryptedSecrets,
+		ApplyToInternalLinks: row.ApplyToInternalLinks,
+		LoginURL:             row.LoginUrl.String,
+		LoginUsernameField:   row.LoginUsernameField.String,
+		LoginPasswordField:   row.LoginPasswordField.String,
+		LoginUsername:        row.LoginUsername.String,
+	}, nil
+}
+
+// GetCachedLinkStatus retrieves the unexpired cached status of a link
+func (r *crawlRepo) GetCachedLinkStatus(ctx context.Context, absoluteURL string) (utils.LinkStatus, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	row, err := queries.GetCachedLinkStatus(ctx, absoluteURL)
+	if err == sql.ErrNoRows {
+		return utils.LinkStatus{}, false, nil
+	}
+	if err != nil {
+		return utils.LinkStatus{}, false, err
+	}
+	status := utils.LinkStatus{Reason: row.Reason, RedirectURL: row.RedirectUrl.String}
+	if row.StatusCode.Valid {
+		code := int(row.StatusCode.Int32)
+		status.StatusCode = &code
+	}
+	return status, true, nil
+}
+
+// CacheLinkStatus stores the status of a link for the given time to live
+func (r *crawlRepo) CacheLinkStatus(ctx context.Context, absoluteURL string, status utils.LinkStatus, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	params := db.UpsertCachedLinkStatusParams{
+		AbsoluteUrl: absoluteURL,
+		Reason:      status.Reason,
+		RedirectUrl: sql.NullString{String: status.RedirectURL, Valid: status.RedirectURL != ""},
+		TtlSeconds:  int64(ttl.Seconds()),
+	}
+	if status.StatusCode != nil {
+		params.StatusCode = sql.NullInt32{Int32: int32(*status.StatusCode), Valid: true}
+	}
+	return queries.UpsertCachedLinkStatus(ctx, params)
+}
+
+// DeleteExpiredLinkStatuses removes up to limit expired link statuses and returns how many were removed
+func (r *crawlRepo) DeleteExpiredLinkStatuses(ctx context.Context, limit int) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	return queries.DeleteExpiredLinkStatuses(ctx, int32(limit))
+}
+
+// UpdateCrawlLinkCacheStats records how many link statuses of a crawl were served from the cache
+func (r *crawlRepo) UpdateCrawlLinkCacheStats(ctx context.Context, crawlID string, hits int, misses int) error {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	err := queries.UpdateCrawlLinkCacheStats(ctx, db.UpdateCrawlLinkCacheStatsParams{
+		LinkCacheHits:   uint32(hits),
+		LinkCacheMisses: uint32(misses),
+		ID:              crawlID,
+	})
+	return err
+}
+
+// GetLinkCacheStats sums the link cache hits and misses of all crawls of the user
+func (r *crawlRepo) GetLinkCacheStats(ctx context.Context, userID string) (*LinkCacheStatsResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	row, err := queries.GetLinkCacheStatsByUserId(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	stats := &LinkCacheStatsResponse{
+		Hits:   row.Hits,
+		Misses: row.Misses,
+	}
+	if total := row.Hits + row.Misses; total > 0 {
+		stats.HitRatio = float64(row.Hits) / float64(total)
+	}
+	return stats, nil
+}
+
+// ListCrawlsForReconciliation retrieves the active crawls not updated since updatedBefore, and the crawls stopped
+// between stoppedAfter and updatedBefore, after the cursor ordered by ID
+func (r *crawlRepo) ListCrawlsForReconciliation(ctx context.Context, cursor string, updatedBefore time.Time, stoppedAfter time.Time, limit int) ([]ReconcileCandidate, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	rows, err := queries.ListCrawlsForReconciliation(ctx, db.ListCrawlsForReconciliationParams{
+		Cursor:        cursor,
+		UpdatedBefore: sql.NullTime{Time: updatedBefore, Valid: true},
+		StoppedAfter:  sql.NullTime{Time: stoppedAfter, Valid: true},
+		Limit:         int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	candidates := make([]ReconcileCandidate, len(rows))
+	for i, row := range rows {
+		candidates[i] = ReconcileCandidate{
+			CrawlID:    row.ID,
+			URLID:      row.UrlID,
+			UserID:     row.UserID,
+			Status:     string(row.Status),
+			WorkflowID: row.WorkflowID,
+		}
+	}
+	return candidates, nil
+}
+
+// RepairCrawl moves the crawl to the status of the repair and records the repair, it returns false without
+// changing anything when the crawl left the status it was listed with in the meantime. A repair that only
+// canceled the workflow is recorded with the updated_at of the crawl bumped, so it is not listed again right away
+func (r *crawlRepo) RepairCrawl(ctx context.Context, candidate ReconcileCandidate, repair CrawlRepair) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	queries := db.New(r.sqlDB).WithTx(tx)
+
+	var result sql.Result
+	if repair.CancelWorkflow {
+		result, err = queries.TouchStoppedCrawl(ctx, candidate.CrawlID)
+	} else {
+		result, err = queries.RepairCrawl(ctx, db.RepairCrawlParams{
+			Status:         db.CrawlsStatus(repair.NewStatus),
+			ErrorMessage:   sql.NullString{String: repair.Reason, Valid: true},
+			ID:             candidate.CrawlID,
+			PreviousStatus: db.CrawlsStatus(candidate.Status),
+		})
+	}
+	if err != nil {
+		return false, err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return false, err
+	}
+
+	err = queries.CreateCrawlRepair(ctx, db.CreateCrawlRepairParams{
+		CrawlID:        candidate.CrawlID,
+		PreviousStatus: candidate.Status,
+		NewStatus:      repair.NewStatus,
+		WorkflowStatus: sql.NullString{String: repair.WorkflowStatus, Valid: repair.WorkflowStatus != ""},
+		Reason:         repair.Reason,
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// ListCrawlRepairs retrieves the latest repairs of the crawls of the user
+func (r *crawlRepo) ListCrawlRepairs(ctx context.Context, userID string, limit int) ([]CrawlRepairResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	rows, err := queries.ListCrawlRepairsForUser(ctx, db.ListCrawlRepairsForUserParams{
+		UserID: userID,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	repairs := make([]CrawlRepairResponse, len(rows))
+	for i, row := range rows {
+		repairs[i] = CrawlRepairResponse{
+			ID:             row.ID,
+			CrawlID:        row.CrawlID,
+			URLID:          row.UrlID,
+			URL:            row.NormalizedUrl,
+			PreviousStatus: row.PreviousStatus,
+			NewStatus:      row.NewStatus,
+			WorkflowStatus: row.WorkflowStatus.String,
+			Reason:         row.Reason,
+			RepairedAt:     row.RepairedAt,
+		}
+	}
+	return repairs, nil
+}
@@ -1,7 +1,10 @@
 package crawl
 
 import (
+	"context"
+	"sykell-backend/internal/blobstore"
 	"sykell-backend/internal/config"
+	"sykell-backend/internal/quota"
 	"sykell-backend/internal/temporal"
 )
 
@@ -10,14 +13,21 @@ type CrawlService struct {
 	repo Repo
 	config *config.Config
 	temporalService *temporal.Service
+	snapshots blobstore.Store
+	quotas *quota.Service
+	executor Executor
 }
 
 
-// NewCrawlService creates a new CrawlService
-func NewCrawlService(repo Repo, config *config.Config, temporalService *temporal.Service) *CrawlService {
+// NewCrawlService creates a new CrawlService running crawls on the executor selected by the configuration, the
+// local executor runs them with the activities until ctx is done. Quotas are not enforced without a quota service
+func NewCrawlService(ctx context.Context, repo Repo, config *config.Config, temporalService *temporal.Service, snapshots blobstore.Store, quotas *quota.Service, activities *Activities) *CrawlService {
 	return &CrawlService{
 		repo: repo,
 		config: config,
 		temporalService: temporalService,
+		snapshots: snapshots,
+		quotas: quotas,
+		executor: newExecutor(ctx, config, temporalService, activities),
 	}
 }
\ No newline at end of file
@@ -0,0 +1,56 @@
+package crawl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"sykell-backend/internal/blobstore"
+)
+
+// ErrSnapshotNotFound is returned when a crawl does not exist for the user or has no archived snapshot
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// credentialHeaders are never archived with a snapshot, they carry the session the crawled site gave the crawler
+// or the credentials of its request profile
+var credentialHeaders = []string{"Set-Cookie", "Set-Cookie2", "Cookie", "Authorization", "Proxy-Authorization"}
+
+// snapshotHeaders returns a copy of the response headers without the ones carrying credentials
+func snapshotHeaders(header http.Header) http.Header {
+	headers := header.Clone()
+	for _, name := range credentialHeaders {
+		headers.Del(name)
+	}
+	return headers
+}
+
+// GetSnapshot returns the snapshot details of the specified crawl of the user
+func (s *CrawlService) GetSnapshot(ctx context.Context, userID string, crawlID string) (*SnapshotResponse, error) {
+	snapshot, err := s.repo.GetCrawlSnapshot(ctx, crawlID, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSnapshotNotFound
+		}
+		return nil, err
+	}
+	if snapshot.Hash == "" {
+		return nil, ErrSnapshotNotFound
+	}
+	return snapshot, nil
+}
+
+// GetSnapshotContent returns the snapshot details together with the raw HTML exactly as it was fetched
+func (s *CrawlService) GetSnapshotContent(ctx context.Context, userID string, crawlID string) (*SnapshotResponse, []byte, error) {
+	snapshot, err := s.GetSnapshot(ctx, userID, crawlID)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := blobstore.GetCompressed(ctx, s.snapshots, snapshot.Hash)
+	if err != nil {
+		if errors.Is(err, blobstore.ErrNotFound) {
+			return nil, nil, ErrSnapshotNotFound
+		}
+		return nil, nil, err
+	}
+	return snapshot, content, nil
+}
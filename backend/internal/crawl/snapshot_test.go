@@ -0,0 +1,38 @@
+package crawl
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotHeadersDropCredentials(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "text/html")
+	header.Add("Set-Cookie", "session=secret")
+	header.Add("Set-Cookie", "csrf=token")
+	header.Set("Authorization", "Bearer secret")
+
+	headers := snapshotHeaders(header)
+	assert.Equal(t, http.Header{"Content-Type": {"text/html"}}, headers)
+	// The response the crawl analyzes keeps its headers
+	assert.Len(t, header.Values("Set-Cookie"), 2)
+}
+
+func TestUpdateCrawlSnapshotDropsCredentials(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	mock.ExpectExec("UPDATE crawls").
+		WithArgs("hash-1", int64(12), []byte(`{"Content-Type":["text/html"]}`), "crawl-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	header := http.Header{"Content-Type": {"text/html"}, "Set-Cookie": {"session=secret"}}
+	require.NoError(t, NewRepo(mockDB).UpdateCrawlSnapshot(context.Background(), "crawl-1", "hash-1", 12, header))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -131,22 +131,27 @@ func (h *CrawlHandler) StreamCrawlUpdates(c echo.Context) error {
 	}
 }
 
-// NotifyCrawlUpdate sends a notification to invalidate a specific URL's data
-func NotifyCrawlUpdate(userID, urlID string) {
+// NotifyCrawlUpdate sends a notification to invalidate a specific URL's data, with the new status of its crawl if it changed
+func NotifyCrawlUpdate(userID, urlID, status string) {
 	notification := SSENotification{
 		Type:      "crawl_update",
 		URLID:     urlID,
 		UserID:    userID,
+		Status:    status,
 		Timestamp: time.Now(),
 	}
+	sseManager.broadcast(userID, notification)
+}
 
-	sseManager.mutex.RLock()
-	conns := sseManager.clients[userID]
+// broadcast sends the notification to every connection of the user without blocking
+func (m *SSEManager) broadcast(userID string, notification SSENotification) {
+	m.mutex.RLock()
+	conns := m.clients[userID]
 	var chans []chan SSENotification
 	for _, ch := range conns {
 		chans = append(chans, ch)
 	}
-	sseManager.mutex.RUnlock()
+	m.mutex.RUnlock()
 
 	sent := 0
 	for _, ch := range chans {
@@ -158,12 +163,24 @@ func NotifyCrawlUpdate(userID, urlID string) {
 	}
 	logger.Debug("SSE broadcast attempted", 
 		zap.String("user_id", userID), 
-		zap.String("url_id", urlID), 
+		zap.String("url_id", notification.URLID), 
+		zap.String("type", notification.Type),
 		zap.Int("connections", len(chans)),
 		zap.Int("sent", sent))
 }
 
 
+// NotifyCrawlProgress sends the progress of a crawl to the user's connections, progress events are dropped
+// for connections that are not keeping up since the next event supersedes them
+func NotifyCrawlProgress(userID, urlID string, progress CrawlProgress) {
+	sseManager.broadcast(userID, SSENotification{
+		Type:      "crawl_progress",
+		URLID:     urlID,
+		UserID:    userID,
+		Progress:  &progress,
+		Timestamp: time.Now(),
+	})
+}
 
 // sendSSEEvent sends an SSE event to the client
 func sendSSEEvent(c echo.Context, notification SSENotification) error {
@@ -2,14 +2,18 @@ package crawl
 
 import (
 	"context"
-	"time"
+	"fmt"
+	"sykell-backend/internal/logger"
 
 	"github.com/google/uuid"
-	"go.temporal.io/sdk/client"
+	"go.uber.org/zap"
 )
 
-// StartCrawl initiates a crawl for the specified URL by the user
-func (s *CrawlService) StartCrawl(ctx context.Context, userID string, urlID string) error {	
+// StartCrawl initiates a crawl for the specified URL by the user, on the task queue of its priority
+func (s *CrawlService) StartCrawl(ctx context.Context, userID string, urlID string, priority string) error {	
+	if !validPriority(priority) {
+		return ErrInvalidPriority
+	}
 		
 	// Verify that the URL belongs to the user
 	url, err := s.repo.GetUrlByIdAndUserId(ctx, urlID, userID)
@@ -25,35 +29,72 @@ func (s *CrawlService) StartCrawl(ctx context.Context, userID string, urlID stri
 	if activeCrawls > 0 {
 		return nil
 	}
-	// Enqueue the crawl task
-	workflowID := "crawl_" + url.ID + "_" + uuid.New().String()
-	if err = s.repo.QueueCrawl(ctx, urlID, workflowID); err != nil {
-		return err
-	}	
 
-	crawlID, err := s.repo.GetCrawlIDByWorkflowID(ctx, workflowID)
+	// Every crawl counts towards the daily quota of the user's plan, scheduled and backfill crawls also have a
+	// minimum interval. The limits are checked early here, and again when the crawl is queued since concurrent
+	// starts may have used them up in the meantime
+	if s.quotas != nil {
+		if err := s.quotas.CheckCrawls(ctx, userID, 1); err != nil {
+			return err
+		}
+		if scheduledPriority(priority) {
+			if err := s.quotas.CheckScheduleInterval(ctx, userID, url.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	// A user can only have a limited number of active crawls of each priority
+	remaining, err := s.remainingCrawls(ctx, userID, priority)
 	if err != nil {
 		return err
 	}
+	if remaining == 0 {
+		return ErrCrawlLimitReached
+	}
 
-	workflowOptions := client.StartWorkflowOptions{
-		ID:        workflowID,
-		TaskQueue: TaskQueueName,
-		WorkflowExecutionTimeout: 10 * time.Minute, // Set explicit workflow timeout
-		WorkflowTaskTimeout:      time.Minute,      // Set workflow task timeout		
-		StartDelay: 3 * time.Second, // Small delay to ensure the sse connection is ready
+	// Nothing is queued while the executor cannot start crawls, e.g. Temporal is unreachable
+	if err := s.executor.Ready(); err != nil {
+		return err
 	}
-	
-	// Start the workflow	
-	_, err = s.temporalService.GetTemporalClient().ExecuteWorkflow(ctx, workflowOptions, WorkflowName, WorlFlowInput{
+
+	// Enqueue the crawl task, a concurrent start of the same URL queues nothing and the limits are checked again
+	// under the lock of the user
+	crawlID := uuid.New().String()
+	workflowID := s.executor.CrawlWorkflowID(url.ID, uuid.New().String())
+	queued, err := s.repo.QueueCrawl(ctx, QueuedCrawl{
+		CrawlID: crawlID,
+		URLID: url.ID,
+		UserID: userID,
+		WorkflowID: workflowID,
+		Priority: priority,
+		ActiveLimit: userCrawlLimit(s.config, priority),
+		Quotas: s.quotas,
+	})
+	if err != nil || !queued {
+		return err
+	}
+
+	err = s.executor.Start(ctx, WorlFlowInput{
 		URLID: url.ID,
 		UserID: userID,
 		WorkflowID: workflowID,
 		URL: url.NormalizedUrl,
 		CrawlID: crawlID,
-	})
-	
-	
+	}, priority)
+	if err != nil {
+		// A crawl that may have started is left queued, the reconciler marks it failed once it finds no workflow
+		if startUnconfirmed(err) {
+			logger.Warn("Crawl start is unconfirmed", zap.Error(err), zap.String("crawl_id", crawlID))
+			return err
+		}
+		// The queued crawl would lock the URL, it is marked failed instead
+		message := fmt.Sprintf("The crawl could not be started: %v", err)
+		if failErr := s.repo.SetCrawlError(context.WithoutCancel(ctx), crawlID, message); failErr != nil {
+			logger.Error("Failed to mark unstarted crawl as failed", zap.Error(failErr), zap.String("crawl_id", crawlID))
+		}
+		return err
+	}
 
-	return err
+	return nil
 }
\ No newline at end of file
@@ -0,0 +1,134 @@
+package crawl
+
+import (
+	"context"
+	"sykell-backend/internal/quota"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueCrawl(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	// The crawl is inserted with its ID under the locks of the user and the URL
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE").WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("user-1"))
+	mock.ExpectQuery("FOR UPDATE").WithArgs("url-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("url-1"))
+	mock.ExpectQuery("SELECT COUNT").WithArgs("url-1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec("INSERT INTO crawls").WithArgs("crawl-1", "url-1", "interactive", "workflow-1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	queued, err := NewRepo(mockDB).QueueCrawl(context.Background(), QueuedCrawl{CrawlID: "crawl-1", URLID: "url-1", UserID: "user-1", WorkflowID: "workflow-1", Priority: PriorityInteractive})
+	require.NoError(t, err)
+	assert.True(t, queued)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueueCrawlSkipsActiveURL(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	// A crawl queued by a concurrent start is seen once the lock is held, nothing is inserted
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE").WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("user-1"))
+	mock.ExpectQuery("FOR UPDATE").WithArgs("url-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("url-1"))
+	mock.ExpectQuery("SELECT COUNT").WithArgs("url-1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	queued, err := NewRepo(mockDB).QueueCrawl(context.Background(), QueuedCrawl{CrawlID: "crawl-2", URLID: "url-1", UserID: "user-1", WorkflowID: "workflow-2", Priority: PriorityInteractive})
+	require.NoError(t, err)
+	assert.False(t, queued)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueueCrawlRechecksLimitsUnderLock(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	// The crawls a concurrent start queued before it released the lock of the user count towards the limit
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE").WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("user-1"))
+	mock.ExpectQuery("FOR UPDATE").WithArgs("url-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("url-1"))
+	mock.ExpectQuery("SELECT COUNT").WithArgs("url-1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT COUNT").WithArgs("user-1", "interactive").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+	mock.ExpectRollback()
+
+	queued, err := NewRepo(mockDB).QueueCrawl(context.Background(), QueuedCrawl{CrawlID: "crawl-3", URLID: "url-1", UserID: "user-1", WorkflowID: "workflow-3", Priority: PriorityInteractive, ActiveLimit: 5})
+	assert.ErrorIs(t, err, ErrCrawlLimitReached)
+	assert.False(t, queued)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueueCrawlChecksPlanInTransaction(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	// The daily quota is counted inside the transaction holding the lock of the user
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE").WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("user-1"))
+	mock.ExpectQuery("FOR UPDATE").WithArgs("url-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("url-1"))
+	mock.ExpectQuery("SELECT COUNT").WithArgs("url-1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT plan").WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"plan"}).AddRow("free"))
+	mock.ExpectQuery("SELECT COUNT").WithArgs("user-1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(200))
+	mock.ExpectRollback()
+
+	quotas := quota.NewService(quota.NewRepo(mockDB))
+	queued, err := NewRepo(mockDB).QueueCrawl(context.Background(), QueuedCrawl{CrawlID: "crawl-4", URLID: "url-1", UserID: "user-1", WorkflowID: "workflow-4", Priority: PriorityScheduled, Quotas: quotas})
+	var limitErr *quota.LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, quota.ResourceCrawlsPerDay, limitErr.Resource)
+	assert.False(t, queued)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueueCrawlSkipsIntervalForInteractiveCrawls(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	// An interactive crawl counts towards the daily quota but is not held back by the schedule interval
+	mock.ExpectBegin()
+	mock.ExpectQuery("FOR UPDATE").WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("user-1"))
+	mock.ExpectQuery("FOR UPDATE").WithArgs("url-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("url-1"))
+	mock.ExpectQuery("SELECT COUNT").WithArgs("url-1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT plan").WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"plan"}).AddRow("free"))
+	mock.ExpectQuery("SELECT COUNT").WithArgs("user-1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO crawls").WithArgs("crawl-5", "url-1", "interactive", "workflow-5").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	quotas := quota.NewService(quota.NewRepo(mockDB))
+	queued, err := NewRepo(mockDB).QueueCrawl(context.Background(), QueuedCrawl{CrawlID: "crawl-5", URLID: "url-1", UserID: "user-1", WorkflowID: "workflow-5", Priority: PriorityInteractive, Quotas: quotas})
+	require.NoError(t, err)
+	assert.True(t, queued)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -31,6 +31,11 @@ func (s *CrawlService) StopCrawl(ctx context.Context, userID string, urlID strin
 	
 	logger.Info("Found active crawls", zap.Int("count", len(activeCrawls)))
 
+	// Crawls are only marked stopped when their workflows can be canceled
+	if err := s.executor.Ready(); err != nil {
+		return err
+	}
+
 	for _, crawl := range activeCrawls {
 		logger.Info("Stopping crawl", 
 			zap.String("crawl_id", crawl.ID), 
@@ -44,7 +49,7 @@ func (s *CrawlService) StopCrawl(ctx context.Context, userID string, urlID strin
 		logger.Info("Successfully updated crawl status to stopped", zap.String("crawl_id", crawl.ID))
 
 		// Signal the workflow to stop		
-		if err = s.temporalService.GetTemporalClient().CancelWorkflow(ctx, crawl.WorkflowID, ""); err != nil {		
+		if err = s.executor.Stop(ctx, crawl.WorkflowID); err != nil {		
 			logger.Error("Error canceling workflow", zap.Error(err))
 			return fmt.Errorf("failed to cancel workflow: %w", err)
 		}
@@ -1,10 +1,17 @@
 package crawl
 
 import (
+	"context"
+	"fmt"
+	"sykell-backend/internal/blobstore"
 	"sykell-backend/internal/config"
+	"sykell-backend/internal/database"
+	"sykell-backend/internal/db"
 	"sykell-backend/internal/logger"
+	"sync"
 	"time"
 
+	"go.temporal.io/api/enums/v1"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/worker"
@@ -12,7 +19,11 @@ import (
 	"go.uber.org/zap"
 )
 
-// CrawlWorkflow is the main workflow for crawling a URL, it orchestrates the crawl activity
+// crawlBatchesChangeID versions the crawl workflow from the single crawl activity to the link check batches
+const crawlBatchesChangeID = "crawl-link-batches"
+
+// CrawlWorkflow is the main workflow for crawling a URL, it fetches the page and checks its links in batches,
+// the crawl can be paused between batches with the pause signal and continues with the resume signal
 func CrawlWorkflow(ctx workflow.Context, input WorlFlowInput) error {
 	logger := workflow.GetLogger(ctx)
 	logger.Info("Starting crawl workflow", "url", input.URL, "crawl_id", input.CrawlID, "user_id", input.UserID)
@@ -31,18 +42,171 @@ func CrawlWorkflow(ctx workflow.Context, input WorlFlowInput) error {
 	}
 	
 	ctx = workflow.WithActivityOptions(ctx, activityOptions)
+	pause := newPauseControl(ctx)
+
+	// The progress query reports the phase of the crawl and how many links were checked, batch by batch
+	progress := CrawlProgress{CrawlID: input.CrawlID, Phase: CrawlPhaseFetching}
+	if err := workflow.SetQueryHandler(ctx, CrawlProgressQueryName, func() (CrawlProgress, error) {
+		return progress.WithPercent(), nil
+	}); err != nil {
+		return err
+	}
 	
-	logger.Info("Executing crawl activity", "url", input.URL, "crawl_id", input.CrawlID)
-	err := workflow.ExecuteActivity(ctx, CrawlURLActivity, input).Get(ctx, nil)
+	// Crawls started before the links were checked in batches replay the single crawl activity they started with
+	if workflow.GetVersion(ctx, crawlBatchesChangeID, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		logger.Info("Executing crawl activity", "url", input.URL, "crawl_id", input.CrawlID)
+		if err := workflow.ExecuteActivity(ctx, activities.CrawlURLActivity, input).Get(ctx, nil); err != nil {
+			logger.Error("Crawl workflow failed", "error", err, "url", input.URL, "crawl_id", input.CrawlID)
+			return err
+		}
+		progress.Phase = CrawlPhaseDone
+		startLinkRecheck(ctx, input)
+		logger.Info("Crawl workflow completed successfully", "url", input.URL, "crawl_id", input.CrawlID)
+		return nil
+	}
+
+	logger.Info("Executing fetch activity", "url", input.URL, "crawl_id", input.CrawlID)
+	var page *PageFetchResult
+	err := workflow.ExecuteActivity(ctx, activities.FetchPageActivity, input).Get(ctx, &page)
 	if err != nil {
 		logger.Error("Crawl workflow failed", "error", err, "url", input.URL, "crawl_id", input.CrawlID)
 		return err
 	}
 
+	// Non-HTML resources are done once fetched
+	if page != nil {
+		progress.Phase = CrawlPhaseCheckingLinks
+		progress.LinksTotal = page.LinkCount
+
+		var results LinkCheckResults
+		for offset := 0; offset < page.LinkCount; offset += page.BatchSize {
+			if pause.paused {
+				progress.Phase = CrawlPhasePaused
+			}
+			resumed, err := pause.wait(ctx, input, page.MaxPause)
+			if err != nil {
+				return err
+			}
+			if !resumed {
+				logger.Warn("Crawl stopped after staying paused too long", "crawl_id", input.CrawlID, "max_pause", page.MaxPause)
+				return nil
+			}
+			progress.Phase = CrawlPhaseCheckingLinks
+
+			var batch LinkCheckResults
+			err = workflow.ExecuteActivity(ctx, activities.CheckLinkBatchActivity, LinkBatchInput{Crawl: input, Page: *page, Offset: offset}).Get(ctx, &batch)
+			if err != nil {
+				logger.Error("Link batch failed", "error", err, "crawl_id", input.CrawlID, "offset", offset)
+				return failCrawl(ctx, input, err)
+			}
+			results.Add(batch)
+			progress.LinksChecked = min(offset+page.BatchSize, page.LinkCount)
+		}
+
+		progress.Phase = CrawlPhaseFinalizing
+		if err := workflow.ExecuteActivity(ctx, activities.FinalizeCrawlActivity, input, results).Get(ctx, nil); err != nil {
+			logger.Error("Failed to finalize crawl", "error", err, "crawl_id", input.CrawlID)
+			return failCrawl(ctx, input, err)
+		}
+	}
+	progress.Phase = CrawlPhaseDone
+	startLinkRecheck(ctx, input)
+
 	logger.Info("Crawl workflow completed successfully", "url", input.URL, "crawl_id", input.CrawlID)
 	return nil
 }
 
+// startLinkRecheck starts the workflow rechecking the inaccessible links of the crawl, it outlives the crawl
+func startLinkRecheck(ctx workflow.Context, input WorlFlowInput) {
+	logger := workflow.GetLogger(ctx)
+	var recheck *LinkRecheckInput
+	if err := workflow.ExecuteActivity(ctx, activities.PlanLinkRecheckActivity, input).Get(ctx, &recheck); err != nil {
+		logger.Error("Failed to plan link recheck", "error", err, "crawl_id", input.CrawlID)
+	} else if recheck != nil {
+		childCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+			WorkflowID:        "recheck_" + input.CrawlID,
+			TaskQueue:         ScheduledTaskQueueName,
+			ParentClosePolicy: enums.PARENT_CLOSE_POLICY_ABANDON,
+		})
+		child := workflow.ExecuteChildWorkflow(childCtx, LinkRecheckWorkflowName, *recheck)
+		if err := child.GetChildWorkflowExecution().Get(ctx, nil); err != nil {
+			logger.Error("Failed to start link recheck workflow", "error", err, "crawl_id", input.CrawlID)
+		}
+	}
+}
+
+// pauseControl tracks the pause and resume signals of a crawl workflow, the last signal received wins
+type pauseControl struct {
+	paused bool
+}
+
+// newPauseControl starts listening to the pause and resume signals of the workflow
+func newPauseControl(ctx workflow.Context) *pauseControl {
+	control := &pauseControl{}
+	pauseCh := workflow.GetSignalChannel(ctx, PauseSignalName)
+	resumeCh := workflow.GetSignalChannel(ctx, ResumeSignalName)
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		selector := workflow.NewSelector(ctx)
+		selector.AddReceive(pauseCh, func(c workflow.ReceiveChannel, more bool) {
+			c.Receive(ctx, nil)
+			control.paused = true
+		})
+		selector.AddReceive(resumeCh, func(c workflow.ReceiveChannel, more bool) {
+			c.Receive(ctx, nil)
+			control.paused = false
+		})
+		for ctx.Err() == nil {
+			selector.Select(ctx)
+		}
+	})
+	return control
+}
+
+// wait blocks while the crawl is paused, recording the paused status until it is resumed. It returns false
+// when the crawl stayed paused longer than maxPause and was stopped instead, a zero maxPause waits indefinitely
+func (p *pauseControl) wait(ctx workflow.Context, input WorlFlowInput, maxPause time.Duration) (bool, error) {
+	if !p.paused {
+		return true, nil
+	}
+	logger := workflow.GetLogger(ctx)
+	logger.Info("Crawl paused", "crawl_id", input.CrawlID)
+	if err := workflow.ExecuteActivity(ctx, activities.SetCrawlStatusActivity, input, string(db.CrawlsStatusPaused), "").Get(ctx, nil); err != nil {
+		return false, err
+	}
+
+	resumed := true
+	var err error
+	if maxPause > 0 {
+		resumed, err = workflow.AwaitWithTimeout(ctx, maxPause, func() bool { return !p.paused })
+	} else {
+		err = workflow.Await(ctx, func() bool { return !p.paused })
+	}
+	if err != nil {
+		return false, err
+	}
+	status := db.CrawlsStatusRunning
+	if !resumed {
+		status = db.CrawlsStatusStopped
+	}
+	if err := workflow.ExecuteActivity(ctx, activities.SetCrawlStatusActivity, input, string(status), "").Get(ctx, nil); err != nil {
+		return false, err
+	}
+	logger.Info("Crawl pause ended", "crawl_id", input.CrawlID, "status", status)
+	return resumed, nil
+}
+
+// failCrawl records the error of a crawl that failed after its page was fetched, stopped crawls are left as they are
+func failCrawl(ctx workflow.Context, input WorlFlowInput, err error) error {
+	if temporal.IsCanceledError(err) {
+		return err
+	}
+	message := fmt.Sprintf("Crawl failed to complete: %v", err)
+	if statusErr := workflow.ExecuteActivity(ctx, activities.SetCrawlStatusActivity, input, string(db.CrawlsStatusError), message).Get(ctx, nil); statusErr != nil {
+		workflow.GetLogger(ctx).Error("Failed to record crawl error", "error", statusErr, "crawl_id", input.CrawlID)
+	}
+	return err
+}
+
 // StartWorker initializes and starts the Temporal worker to process crawl workflows and activities
 func StartWorker(config *config.Config) error {	
 	logger.Info("Attempting to connect to Temporal server", zap.String("host_port", config.TemporalHostPort))
@@ -77,21 +241,110 @@ func StartWorker(config *config.Config) error {
 
 	logger.Info("Successfully connected to Temporal server")
 
+	// The activities share the database pool, snapshot store, crawl transports and notifier of the worker
+	dbSQL, err := database.Open(config)
+	if err != nil {
+		logger.Error("Failed to connect to database", zap.Error(err))
+		return err
+	}
+	defer dbSQL.Close()
+	snapshots, err := blobstore.New(config)
+	if err != nil {
+		logger.Error("Failed to open blob store", zap.Error(err))
+		return err
+	}
+	crawlActivities := NewActivities(config, dbSQL, snapshots)
+	defer crawlActivities.closeIdleConnections()
+
+	// One worker per priority, each with its own activity slots so a backlog of one priority never holds up
+	// another. Every worker can run every workflow, workflows started before priorities existed stay on the
+	// original task queue of the interactive worker
+	queues := []struct {
+		name  string
+		slots int
+	}{
+		{TaskQueueName, config.CrawlSlotsInteractive},
+		{ScheduledTaskQueueName, config.CrawlSlotsScheduled},
+		{BackfillTaskQueueName, config.CrawlSlotsBackfill},
+	}
+	fatal := make(chan error, len(queues))
+	onFatalError := func(err error) {
+		fatal <- err
+	}
+	var workers []worker.Worker
+	defer func() {
+		stopWorkers(workers)
+	}()
+	for _, queue := range queues {
+		w := newCrawlWorker(temporalClient, config, queue.name, queue.slots, crawlActivities, onFatalError)
+		if err := w.Start(); err != nil {
+			logger.Error("Failed to start Temporal worker", zap.Error(err), zap.String("task_queue", queue.name))
+			return err
+		}
+		workers = append(workers, w)
+		logger.Info("Started Temporal worker on task queue", zap.String("task_queue", queue.name), zap.Int("slots", queue.slots))
+	}
+
+	// The reconciler repairs crawls left behind by workers that died, it keeps running across worker restarts
+	// until a worker starts with RECONCILE_INTERVAL=0
+	if config.ReconcileInterval > 0 {
+		if err := StartReconciler(context.Background(), temporalClient, config.ReconcileInterval); err != nil {
+			logger.Error("Failed to start crawl reconciler", zap.Error(err))
+		}
+	} else if err := StopReconciler(context.Background(), temporalClient); err != nil {
+		logger.Error("Failed to stop crawl reconciler", zap.Error(err))
+	} else {
+		logger.Info("Crawl reconciler disabled")
+	}
+
+	// On SIGINT or SIGTERM the workers stop polling and drain their running activities together, activities
+	// still running after the grace period are canceled and resume from their last heartbeat when retried
+	select {
+	case signal := <-worker.InterruptCh():
+		logger.Info("Draining Temporal workers", zap.Any("signal", signal), zap.Duration("grace_period", config.WorkerStopTimeout))
+	case err := <-fatal:
+		logger.Error("Temporal worker failed", zap.Error(err))
+		return err
+	}
+	stopWorkers(workers)
+	logger.Info("Temporal workers stopped")
+	return nil
+}
+
+// stopWorkers stops the workers at the same time and waits until each has drained its activities
+func stopWorkers(workers []worker.Worker) {
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.Stop()
+		}()
+	}
+	wg.Wait()
+}
+
+// newCrawlWorker creates a worker for the task queue with every crawl workflow and activity registered
+func newCrawlWorker(temporalClient client.Client, cfg *config.Config, taskQueue string, slots int, crawlActivities *Activities, onFatalError func(error)) worker.Worker {
 	// Create worker with debug-enabled options
-	w := worker.New(temporalClient, TaskQueueName, worker.Options{
+	w := worker.New(temporalClient, taskQueue, worker.Options{
 		EnableLoggingInReplay: true, // This ensures logs are visible during replay		
-		MaxConcurrentActivityExecutionSize: 2,
-		
+		MaxConcurrentActivityExecutionSize: max(slots, 1),
+		MaxConcurrentWorkflowTaskExecutionSize: max(cfg.WorkerWorkflowTasks, 1),
+		MaxConcurrentWorkflowTaskPollers: max(cfg.WorkerWorkflowPollers, 1),
+		MaxConcurrentActivityTaskPollers: max(cfg.WorkerActivityPollers, 1),
+		WorkerStopTimeout: cfg.WorkerStopTimeout,
+		OnFatalError: onFatalError,
 	})
 
 	// Register workflows
 	w.RegisterWorkflow(CrawlWorkflow)
+	w.RegisterWorkflow(ReanalyzeWorkflow)
+	w.RegisterWorkflow(LinkRecheckWorkflow)
+	w.RegisterWorkflow(BulkCrawlWorkflow)
+	w.RegisterWorkflow(ReconcileWorkflow)
 
 	// Register activities
-	w.RegisterActivity(CrawlURLActivity)
-	
-	logger.Info("Starting Temporal worker on task queue", zap.String("task_queue", TaskQueueName))
-	
-	// Start listening for tasks
-	return w.Run(worker.InterruptCh())
-}
\ No newline at end of file
+	w.RegisterActivity(crawlActivities)
+	return w
+}
@@ -0,0 +1,449 @@
+package crawl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sykell-backend/internal/blobstore"
+	"sykell-backend/internal/config"
+	"sykell-backend/internal/db"
+	"sykell-backend/internal/utils"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+const fixtureHome = `<!DOCTYPE html>
+<html><head><title>Fixture</title></head>
+<body id="top">
+<h1>Fixture site</h1>
+<a href="/about">About</a>
+<a href="/missing">Missing</a>
+<a href="#top">Top</a>
+<a href="#nowhere">Nowhere</a>
+</body></html>`
+
+// fixtureSite serves the pages crawled by the tests and counts the requests of every path
+type fixtureSite struct {
+	*httptest.Server
+	mu   sync.Mutex
+	hits map[string]int
+	// flakyFailures is how many requests to /flaky fail before it is served
+	flakyFailures int
+}
+
+func newFixtureSite(t *testing.T) *fixtureSite {
+	site := &fixtureSite{hits: make(map[string]int), flakyFailures: 2}
+	mux := http.NewServeMux()
+	page := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(fixtureHome))
+	}
+	mux.HandleFunc("/{$}", page)
+	mux.HandleFunc("/about", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<!DOCTYPE html><html><head><title>About</title></head><body><p>About the fixture</p></body></html>`))
+	})
+	mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		if site.Hits("/flaky") <= site.flakyFailures {
+			http.Error(w, "temporarily unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		page(w, r)
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html><body>" + strings.Repeat("x", 4096) + "</body></html>"))
+	})
+	site.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site.mu.Lock()
+		site.hits[r.URL.Path]++
+		site.mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(site.Close)
+	return site
+}
+
+// Hits returns how many requests were sent to the path
+func (s *fixtureSite) Hits(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits[path]
+}
+
+// memoryRepo keeps the state the crawl activities write in memory, the methods they do not use panic
+type memoryRepo struct {
+	Repo
+	mu           sync.Mutex
+	statuses     []string
+	errorMessage string
+	outcome      string
+	snapshotHash string
+	counts       LinkCounts
+	inaccessible []utils.LinkInfo
+}
+
+func (r *memoryRepo) setStatus(status db.CrawlsStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses = append(r.statuses, string(status))
+	return nil
+}
+
+// Status returns the current status of the crawl
+func (r *memoryRepo) Status() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.statuses) == 0 {
+		return string(db.CrawlsStatusQueued)
+	}
+	return r.statuses[len(r.statuses)-1]
+}
+
+// Statuses returns every status the crawl went through
+func (r *memoryRepo) Statuses() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.statuses...)
+}
+
+func (r *memoryRepo) SetCrawlRunning(ctx context.Context, crawlID string) error {
+	return r.setStatus(db.CrawlsStatusRunning)
+}
+
+func (r *memoryRepo) SetCrawlPaused(ctx context.Context, crawlID string) error {
+	return r.setStatus(db.CrawlsStatusPaused)
+}
+
+func (r *memoryRepo) SetCrawlStopped(ctx context.Context, crawlID string) error {
+	return r.setStatus(db.CrawlsStatusStopped)
+}
+
+func (r *memoryRepo) SetCrawlDone(ctx context.Context, crawlID string) error {
+	return r.setStatus(db.CrawlsStatusDone)
+}
+
+func (r *memoryRepo) SetCrawlError(ctx context.Context, crawlID string, errorMessage string) error {
+	r.mu.Lock()
+	r.errorMessage = errorMessage
+	r.mu.Unlock()
+	return r.setStatus(db.CrawlsStatusError)
+}
+
+func (r *memoryRepo) GetRequestProfile(ctx context.Context, urlID string) (*StoredRequestProfile, error) {
+	return nil, nil
+}
+
+func (r *memoryRepo) UpdateCrawlOutcome(ctx context.Context, crawlID string, outcome string, mimeType string, charset string, contentLength int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.outcome = outcome
+	return nil
+}
+
+func (r *memoryRepo) UpdateCrawlSnapshot(ctx context.Context, crawlID string, snapshotHash string, snapshotSize int64, headers http.Header) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.snapshotHash = snapshotHash
+	return nil
+}
+
+func (r *memoryRepo) SaveCrawlForms(ctx context.Context, crawlID string, forms []utils.FormInfo) error {
+	return nil
+}
+
+func (r *memoryRepo) UpdateCrawlFingerprint(ctx context.Context, crawlID string, contentHash string, simhash uint64) error {
+	return nil
+}
+
+func (r *memoryRepo) UpdateCrawlAnalysis(ctx context.Context, crawlID string, analysis pageAnalysis, version int) error {
+	return nil
+}
+
+func (r *memoryRepo) GetCachedLinkStatus(ctx context.Context, absoluteURL string) (utils.LinkStatus, bool, error) {
+	return utils.LinkStatus{}, false, nil
+}
+
+func (r *memoryRepo) CacheLinkStatus(ctx context.Context, absoluteURL string, status utils.LinkStatus, ttl time.Duration) error {
+	return nil
+}
+
+func (r *memoryRepo) DeleteExpiredLinkStatuses(ctx context.Context, limit int) (int64, error) {
+	return 0, nil
+}
+
+func (r *memoryRepo) UpdateCrawlLinkCacheStats(ctx context.Context, crawlID string, hits int, misses int) error {
+	return nil
+}
+
+func (r *memoryRepo) SaveInaccessibleLinks(ctx context.Context, crawlID string, links []utils.LinkInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inaccessible = links
+	return nil
+}
+
+func (r *memoryRepo) UpdateCrawlLinkCounts(ctx context.Context, crawlID string, counts LinkCounts) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counts = counts
+	return nil
+}
+
+func (r *memoryRepo) ConfirmPendingLinks(ctx context.Context, crawlID string) (int64, error) {
+	return 0, nil
+}
+
+// memoryNotifier records the notifications of the activities
+type memoryNotifier struct {
+	mu       sync.Mutex
+	updates  []string
+	progress []CrawlProgress
+}
+
+func (n *memoryNotifier) CrawlUpdated(userID, urlID, status string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.updates = append(n.updates, status)
+}
+
+func (n *memoryNotifier) CrawlProgress(userID, urlID string, progress CrawlProgress) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.progress = append(n.progress, progress)
+}
+
+// noRateLimits never makes a request wait
+type noRateLimits struct{}
+
+func (noRateLimits) Reserve(ctx context.Context, host string, nowMs int64, interval time.Duration, burst int, maxWait time.Duration) (time.Duration, error) {
+	return 0, nil
+}
+
+func (noRateLimits) Backoff(ctx context.Context, host string, untilMs int64) error {
+	return nil
+}
+
+func (noRateLimits) GetCrawlDelay(ctx context.Context, host string, nowMs int64) (time.Duration, bool, error) {
+	return 0, false, nil
+}
+
+func (noRateLimits) SaveCrawlDelay(ctx context.Context, host string, delay time.Duration, expiresMs int64) error {
+	return nil
+}
+
+// newTestActivities creates activities crawling the fixture site with in-memory dependencies, the requests are
+// sent through the default transport since the fixture site listens on a loopback address
+func newTestActivities(t *testing.T) (*Activities, *memoryRepo, *memoryNotifier) {
+	snapshots, err := blobstore.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	repo := &memoryRepo{}
+	notifier := &memoryNotifier{}
+	return &Activities{
+		Config: &config.Config{
+			CrawlMaxBodyBytes:        1 << 20,
+			LinkCheckBatchSize:       2,
+			CrawlMaxPause:            time.Hour,
+			LinkRecheckConfirmations: 1,
+		},
+		Repo:       repo,
+		RateLimits: noRateLimits{},
+		Snapshots:  snapshots,
+		Notifier:   notifier,
+		Transport:  http.DefaultTransport,
+	}, repo, notifier
+}
+
+func newCrawlWorkflowEnvironment(t *testing.T, a *Activities) *testsuite.TestWorkflowEnvironment {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterWorkflow(CrawlWorkflow)
+	env.RegisterActivity(a)
+	return env
+}
+
+func crawlInput(site *fixtureSite, path string) WorlFlowInput {
+	return WorlFlowInput{
+		URLID:      "url-1",
+		UserID:     "user-1",
+		WorkflowID: "crawl_url-1_1",
+		URL:        site.URL + path,
+		CrawlID:    "crawl-1",
+	}
+}
+
+func TestCrawlWorkflowCompletes(t *testing.T) {
+	site := newFixtureSite(t)
+	a, repo, notifier := newTestActivities(t)
+	env := newCrawlWorkflowEnvironment(t, a)
+
+	env.ExecuteWorkflow(CrawlWorkflow, crawlInput(site, "/"))
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	assert.Equal(t, string(db.CrawlsStatusDone), repo.Status())
+	assert.Equal(t, OutcomeHTML, repo.outcome)
+	assert.NotEmpty(t, repo.snapshotHash)
+	// The #top anchor resolves on the page and is not a link
+	assert.Equal(t, LinkCounts{Internal: 1, Inaccessible: 1, BrokenAnchor: 1}, repo.counts)
+	require.Len(t, repo.inaccessible, 1)
+	assert.Equal(t, site.URL+"/missing", repo.inaccessible[0].AbsoluteURL)
+	assert.Positive(t, site.Hits("/about"))
+
+	// The links were checked in two batches, the last progress event reports the crawl done
+	require.NotEmpty(t, notifier.progress)
+	assert.Equal(t, CrawlPhaseDone, notifier.progress[len(notifier.progress)-1].Phase)
+
+	value, err := env.QueryWorkflow(CrawlProgressQueryName)
+	require.NoError(t, err)
+	var progress CrawlProgress
+	require.NoError(t, value.Get(&progress))
+	assert.Equal(t, CrawlPhaseDone, progress.Phase)
+	assert.Equal(t, 3, progress.LinksChecked)
+}
+
+func TestCrawlWorkflowReplaysSingleActivityVersion(t *testing.T) {
+	site := newFixtureSite(t)
+	a, repo, _ := newTestActivities(t)
+	env := newCrawlWorkflowEnvironment(t, a)
+
+	// Workflows started before the link check batches run the single crawl activity
+	env.OnGetVersion(crawlBatchesChangeID, workflow.DefaultVersion, 1).Return(workflow.DefaultVersion)
+	var activityTypes []string
+	env.SetOnActivityStartedListener(func(info *activity.Info, ctx context.Context, args converter.EncodedValues) {
+		activityTypes = append(activityTypes, info.ActivityType.Name)
+	})
+
+	env.ExecuteWorkflow(CrawlWorkflow, crawlInput(site, "/"))
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	assert.Equal(t, []string{"CrawlURLActivity", "PlanLinkRecheckActivity"}, activityTypes)
+	assert.Equal(t, string(db.CrawlsStatusDone), repo.Status())
+	assert.Equal(t, LinkCounts{Internal: 1, Inaccessible: 1, BrokenAnchor: 1}, repo.counts)
+}
+
+func TestCrawlWorkflowRetriesFetch(t *testing.T) {
+	site := newFixtureSite(t)
+	a, repo, _ := newTestActivities(t)
+	env := newCrawlWorkflowEnvironment(t, a)
+
+	// The first two attempts fail with a 503 and are retried by the activity retry policy
+	env.ExecuteWorkflow(CrawlWorkflow, crawlInput(site, "/flaky"))
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	assert.Equal(t, 3, site.Hits("/flaky"))
+	assert.Equal(t, string(db.CrawlsStatusDone), repo.Status())
+}
+
+func TestCrawlWorkflowRecordsErrorStatus(t *testing.T) {
+	site := newFixtureSite(t)
+	a, repo, _ := newTestActivities(t)
+	env := newCrawlWorkflowEnvironment(t, a)
+
+	// Every attempt fails, the crawl is left in error once the retries are exhausted
+	env.ExecuteWorkflow(CrawlWorkflow, crawlInput(site, "/broken"))
+	require.True(t, env.IsWorkflowCompleted())
+	require.Error(t, env.GetWorkflowError())
+
+	assert.Equal(t, 3, site.Hits("/broken"))
+	assert.Equal(t, string(db.CrawlsStatusError), repo.Status())
+	assert.NotEmpty(t, repo.errorMessage)
+}
+
+func TestCrawlWorkflowDoesNotRetryNonRetryableErrors(t *testing.T) {
+	site := newFixtureSite(t)
+	a, repo, _ := newTestActivities(t)
+	a.Config.CrawlMaxBodyBytes = 1024
+	env := newCrawlWorkflowEnvironment(t, a)
+
+	env.ExecuteWorkflow(CrawlWorkflow, crawlInput(site, "/large"))
+	require.True(t, env.IsWorkflowCompleted())
+	require.Error(t, env.GetWorkflowError())
+
+	assert.Equal(t, 1, site.Hits("/large"))
+	assert.Equal(t, OutcomeTooLarge, repo.outcome)
+	assert.Equal(t, string(db.CrawlsStatusError), repo.Status())
+	assert.Contains(t, repo.errorMessage, "maximum body size")
+}
+
+func TestCrawlWorkflowPauseAndResume(t *testing.T) {
+	site := newFixtureSite(t)
+	a, repo, _ := newTestActivities(t)
+	env := newCrawlWorkflowEnvironment(t, a)
+
+	// The crawl pauses before its first batch of links and continues once resumed
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(PauseSignalName, nil)
+	}, 0)
+	env.RegisterDelayedCallback(func() {
+		value, err := env.QueryWorkflow(CrawlProgressQueryName)
+		require.NoError(t, err)
+		var progress CrawlProgress
+		require.NoError(t, value.Get(&progress))
+		assert.Equal(t, CrawlPhasePaused, progress.Phase)
+		assert.Equal(t, 0, site.Hits("/about"))
+		env.SignalWorkflow(ResumeSignalName, nil)
+	}, 10*time.Minute)
+
+	env.ExecuteWorkflow(CrawlWorkflow, crawlInput(site, "/"))
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	statuses := repo.Statuses()
+	assert.Equal(t, []string{"running", "paused", "running", "done"}, statuses)
+	assert.Positive(t, site.Hits("/about"))
+}
+
+func TestCrawlWorkflowStopsAfterMaxPause(t *testing.T) {
+	site := newFixtureSite(t)
+	a, repo, _ := newTestActivities(t)
+	env := newCrawlWorkflowEnvironment(t, a)
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(PauseSignalName, nil)
+	}, 0)
+
+	env.ExecuteWorkflow(CrawlWorkflow, crawlInput(site, "/"))
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	assert.Equal(t, string(db.CrawlsStatusStopped), repo.Status())
+	assert.Equal(t, 0, site.Hits("/about"))
+}
+
+func TestCrawlWorkflowCanceled(t *testing.T) {
+	site := newFixtureSite(t)
+	a, repo, _ := newTestActivities(t)
+	env := newCrawlWorkflowEnvironment(t, a)
+
+	// A crawl stopped while paused is canceled without recording an error
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(PauseSignalName, nil)
+	}, 0)
+	env.RegisterDelayedCallback(env.CancelWorkflow, 10*time.Minute)
+
+	env.ExecuteWorkflow(CrawlWorkflow, crawlInput(site, "/"))
+	require.True(t, env.IsWorkflowCompleted())
+	err := env.GetWorkflowError()
+	require.Error(t, err)
+	assert.True(t, temporal.IsCanceledError(err))
+
+	assert.Equal(t, string(db.CrawlsStatusPaused), repo.Status())
+	assert.Empty(t, repo.errorMessage)
+	assert.Equal(t, 0, site.Hits("/about"))
+}
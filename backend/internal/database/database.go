@@ -0,0 +1,36 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sykell-backend/internal/config"
+
+	_ "github.com/go-sql-driver/mysql" // MySQL driver
+)
+
+// Open opens the connection pool of the MySQL database with the configured limits and checks that the
+// database can be reached
+func Open(cfg *config.Config) (*sql.DB, error) {
+	db, err := sql.Open("mysql", cfg.DatabaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	Configure(db, cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.DefaultTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+	return db, nil
+}
+
+// Configure applies the configured pool limits to the database
+func Configure(db *sql.DB, cfg *config.Config) {
+	db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
+}
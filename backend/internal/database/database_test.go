@@ -0,0 +1,25 @@
+package database
+
+import (
+	"sykell-backend/internal/config"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigure(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	Configure(db, &config.Config{
+		DBMaxOpenConns:    8,
+		DBMaxIdleConns:    4,
+		DBConnMaxLifetime: time.Minute,
+		DBConnMaxIdleTime: time.Second,
+	})
+	assert.Equal(t, 8, db.Stats().MaxOpenConnections)
+}
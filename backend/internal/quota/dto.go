@@ -0,0 +1,18 @@
+package quota
+
+import "time"
+
+// UsageItem is the consumption of a quota against its limit, a zero limit means no limit
+type UsageItem struct {
+	Used  int64 `json:"used"`
+	Limit int64 `json:"limit"`
+}
+
+// Usage represents the response structure for the usage of the user's plan
+type Usage struct {
+	Plan                string    `json:"plan"`
+	URLs                UsageItem `json:"urls"`
+	CrawlsToday         UsageItem `json:"crawls_today"`
+	CrawlsResetAt       time.Time `json:"crawls_reset_at"`
+	MinScheduleInterval int64     `json:"min_schedule_interval_seconds"`
+}
@@ -0,0 +1,72 @@
+package quota
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Quota resources reported in a LimitError
+const (
+	ResourceURLs             = "urls"
+	ResourceCrawlsPerDay     = "crawls_per_day"
+	ResourceScheduleInterval = "schedule_interval"
+)
+
+// LimitError is returned when an action would exceed a quota of the user's plan, it is written as is
+// in the response body
+type LimitError struct {
+	Message  string     `json:"error"`
+	Resource string     `json:"resource"`
+	Plan     string     `json:"plan"`
+	Limit    int64      `json:"limit"` // Seconds for the schedule interval
+	Used     int64      `json:"used"`  // Seconds since the last scheduled crawl for the schedule interval
+	RetryAt  *time.Time `json:"retry_at,omitempty"`
+}
+
+func (e *LimitError) Error() string {
+	return e.Message
+}
+
+// StatusCode returns 429 for quotas that free up over time and 403 for the hard limits of the plan
+func (e *LimitError) StatusCode() int {
+	if e.RetryAt != nil {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusForbidden
+}
+
+// newURLLimitError reports that the user cannot add more URLs
+func newURLLimitError(plan Plan, used int64) *LimitError {
+	return &LimitError{
+		Message:  fmt.Sprintf("The %s plan allows at most %d URLs", plan.Name, plan.MaxURLs),
+		Resource: ResourceURLs,
+		Plan:     plan.Name,
+		Limit:    plan.MaxURLs,
+		Used:     used,
+	}
+}
+
+// newCrawlLimitError reports that the user cannot start more crawls today
+func newCrawlLimitError(plan Plan, used int64, resetAt time.Time) *LimitError {
+	return &LimitError{
+		Message:  fmt.Sprintf("The %s plan allows at most %d crawls per day", plan.Name, plan.CrawlsPerDay),
+		Resource: ResourceCrawlsPerDay,
+		Plan:     plan.Name,
+		Limit:    plan.CrawlsPerDay,
+		Used:     used,
+		RetryAt:  &resetAt,
+	}
+}
+
+// newScheduleLimitError reports that a URL was crawled by a schedule too recently
+func newScheduleLimitError(plan Plan, since time.Duration, retryAt time.Time) *LimitError {
+	return &LimitError{
+		Message:  fmt.Sprintf("The %s plan allows a scheduled crawl of a URL at most every %s", plan.Name, plan.MinScheduleInterval),
+		Resource: ResourceScheduleInterval,
+		Plan:     plan.Name,
+		Limit:    int64(plan.MinScheduleInterval.Seconds()),
+		Used:     int64(since.Seconds()),
+		RetryAt:  &retryAt,
+	}
+}
@@ -0,0 +1,51 @@
+package quota
+
+import (
+	"net/http"
+	"strconv"
+	"sykell-backend/internal/logger"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// Handler handles HTTP requests related to quotas
+type Handler struct {
+	quotaService *Service
+}
+
+// NewHandler creates a new quota Handler
+func NewHandler(quotaService *Service) *Handler {
+	return &Handler{
+		quotaService: quotaService,
+	}
+}
+
+// GetUsage handles retrieving the usage of the user's plan
+func (h *Handler) GetUsage(c echo.Context) error {
+	userID := c.Get("user_id")
+
+	ctx := c.Request().Context()
+
+	usage, err := h.quotaService.GetUsage(ctx, userID.(string))
+	if err != nil {
+		logger.Error("Error in get usage handler",
+			zap.Error(err),
+			zap.String("user_id", userID.(string)))
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to retrieve usage",
+		})
+	}
+
+	return c.JSON(http.StatusOK, usage)
+}
+
+// RespondLimit writes a LimitError as the response, telling the client when to retry for quotas that free up over time
+func RespondLimit(c echo.Context, err *LimitError) error {
+	if err.RetryAt != nil {
+		retryAfter := max(int(time.Until(*err.RetryAt).Seconds()), 1)
+		c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	}
+	return c.JSON(err.StatusCode(), err)
+}
@@ -0,0 +1,42 @@
+package quota
+
+import "time"
+
+// DefaultPlan is the plan of new users and of users on a plan that no longer exists
+const DefaultPlan = "free"
+
+// Plan holds the quotas of a plan, a zero limit means no limit
+type Plan struct {
+	Name                string
+	MaxURLs             int64         // URLs a user can add
+	CrawlsPerDay        int64         // Crawls a user can start per UTC day, including bulk crawls
+	MinScheduleInterval time.Duration // Shortest time between two scheduled crawls of a URL
+}
+
+// plans lists the available plans by name
+var plans = map[string]Plan{
+	"free": {
+		Name:                "free",
+		MaxURLs:             50,
+		CrawlsPerDay:        200,
+		MinScheduleInterval: 24 * time.Hour,
+	},
+	"pro": {
+		Name:                "pro",
+		MaxURLs:             1000,
+		CrawlsPerDay:        5000,
+		MinScheduleInterval: time.Hour,
+	},
+	"enterprise": {
+		Name:                "enterprise",
+		MinScheduleInterval: 5 * time.Minute,
+	},
+}
+
+// PlanByName returns the plan with the given name, falling back to the default plan for unknown names
+func PlanByName(name string) Plan {
+	if plan, ok := plans[name]; ok {
+		return plan
+	}
+	return plans[DefaultPlan]
+}
@@ -0,0 +1,70 @@
+package quota
+
+import (
+	"context"
+	"database/sql"
+	"sykell-backend/internal/config"
+	"sykell-backend/internal/db"
+	"time"
+)
+
+// Repo reads the plan of a user and what they consumed of it
+type Repo interface {
+	GetUserPlan(ctx context.Context, userID string) (string, error)
+	CountURLs(ctx context.Context, userID string) (int64, error)
+	CountCrawlsSince(ctx context.Context, userID string, since time.Time) (int64, error)
+	GetLatestScheduledCrawl(ctx context.Context, urlID string) (time.Time, bool, error)
+}
+
+type quotaRepo struct {
+	conn db.DBTX
+}
+
+// NewRepo creates a new instance of the quota repository, reading through the database or a transaction
+func NewRepo(conn db.DBTX) Repo {
+	return &quotaRepo{conn: conn}
+}
+
+// GetUserPlan returns the name of the user's plan
+func (r *quotaRepo) GetUserPlan(ctx context.Context, userID string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.conn)
+	return queries.GetUserPlan(ctx, userID)
+}
+
+// CountURLs returns how many URLs the user has
+func (r *quotaRepo) CountURLs(ctx context.Context, userID string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.conn)
+	return queries.CountUrlsForUser(ctx, userID)
+}
+
+// CountCrawlsSince returns how many crawls of the user's URLs were queued since the given time, leaving out those
+// that never started
+func (r *quotaRepo) CountCrawlsSince(ctx context.Context, userID string, since time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.conn)
+	return queries.CountCrawlsForUserSince(ctx, db.CountCrawlsForUserSinceParams{
+		UserID:   userID,
+		QueuedAt: sql.NullTime{Time: since, Valid: true},
+	})
+}
+
+// GetLatestScheduledCrawl returns when the latest scheduled or backfill crawl of the URL was queued, if there is one,
+// crawls that never started are left out
+func (r *quotaRepo) GetLatestScheduledCrawl(ctx context.Context, urlID string) (time.Time, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.conn)
+	queuedAt, err := queries.GetLatestScheduledCrawlQueuedAt(ctx, urlID)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return queuedAt.Time, queuedAt.Valid, nil
+}
@@ -0,0 +1,122 @@
+package quota
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Service checks actions against the quotas of the user's plan
+type Service struct {
+	repo Repo
+	now  func() time.Time
+}
+
+// NewService creates a new quota Service
+func NewService(repo Repo) *Service {
+	return &Service{
+		repo: repo,
+		now:  time.Now,
+	}
+}
+
+// WithRepo returns a copy of the service reading through the repo, e.g. one bound to the transaction that holds
+// the lock of the user and performs the action
+func (s *Service) WithRepo(repo Repo) *Service {
+	copied := *s
+	copied.repo = repo
+	return &copied
+}
+
+// plan returns the plan of the user
+func (s *Service) plan(ctx context.Context, userID string) (Plan, error) {
+	name, err := s.repo.GetUserPlan(ctx, userID)
+	if err == sql.ErrNoRows {
+		return PlanByName(DefaultPlan), nil
+	}
+	if err != nil {
+		return Plan{}, err
+	}
+	return PlanByName(name), nil
+}
+
+// dayBounds returns the start of the current UTC day, when the daily crawl quota was reset, and the start of the next
+func (s *Service) dayBounds() (time.Time, time.Time) {
+	start := s.now().UTC().Truncate(24 * time.Hour)
+	return start, start.Add(24 * time.Hour)
+}
+
+// CheckURLs returns a LimitError when adding the given number of URLs would exceed the user's plan
+func (s *Service) CheckURLs(ctx context.Context, userID string, adding int) error {
+	plan, err := s.plan(ctx, userID)
+	if err != nil || plan.MaxURLs == 0 {
+		return err
+	}
+	used, err := s.repo.CountURLs(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if used+int64(adding) > plan.MaxURLs {
+		return newURLLimitError(plan, used)
+	}
+	return nil
+}
+
+// CheckCrawls returns a LimitError when starting the given number of crawls would exceed the user's daily quota
+func (s *Service) CheckCrawls(ctx context.Context, userID string, starting int) error {
+	plan, err := s.plan(ctx, userID)
+	if err != nil || plan.CrawlsPerDay == 0 {
+		return err
+	}
+	start, reset := s.dayBounds()
+	used, err := s.repo.CountCrawlsSince(ctx, userID, start)
+	if err != nil {
+		return err
+	}
+	if used+int64(starting) > plan.CrawlsPerDay {
+		return newCrawlLimitError(plan, used, reset)
+	}
+	return nil
+}
+
+// CheckScheduleInterval returns a LimitError when the URL had a scheduled or backfill crawl more recently than the
+// user's plan allows
+func (s *Service) CheckScheduleInterval(ctx context.Context, userID string, urlID string) error {
+	plan, err := s.plan(ctx, userID)
+	if err != nil || plan.MinScheduleInterval == 0 {
+		return err
+	}
+	latest, ok, err := s.repo.GetLatestScheduledCrawl(ctx, urlID)
+	if err != nil || !ok {
+		return err
+	}
+	since := s.now().Sub(latest)
+	if since < plan.MinScheduleInterval {
+		return newScheduleLimitError(plan, since, latest.Add(plan.MinScheduleInterval))
+	}
+	return nil
+}
+
+// GetUsage returns the consumption of the user's plan against its limits
+func (s *Service) GetUsage(ctx context.Context, userID string) (*Usage, error) {
+	plan, err := s.plan(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	urls, err := s.repo.CountURLs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	start, reset := s.dayBounds()
+	crawls, err := s.repo.CountCrawlsSince(ctx, userID, start)
+	if err != nil {
+		return nil, err
+	}
+	return &Usage{
+		Plan:                plan.Name,
+		URLs:                UsageItem{Used: urls, Limit: plan.MaxURLs},
+		CrawlsToday:         UsageItem{Used: crawls, Limit: plan.CrawlsPerDay},
+		CrawlsResetAt:       reset,
+		MinScheduleInterval: int64(plan.MinScheduleInterval.Seconds()),
+	}, nil
+}
@@ -0,0 +1,140 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// memoryRepo keeps a single user's plan and consumption in memory
+type memoryRepo struct {
+	plan           string
+	urls           int64
+	crawlsQueued   []time.Time
+	latestSchedule map[string]time.Time
+}
+
+func (r *memoryRepo) GetUserPlan(ctx context.Context, userID string) (string, error) {
+	return r.plan, nil
+}
+
+func (r *memoryRepo) CountURLs(ctx context.Context, userID string) (int64, error) {
+	return r.urls, nil
+}
+
+func (r *memoryRepo) CountCrawlsSince(ctx context.Context, userID string, since time.Time) (int64, error) {
+	var count int64
+	for _, queuedAt := range r.crawlsQueued {
+		if !queuedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryRepo) GetLatestScheduledCrawl(ctx context.Context, urlID string) (time.Time, bool, error) {
+	queuedAt, ok := r.latestSchedule[urlID]
+	return queuedAt, ok, nil
+}
+
+func newTestService(repo Repo, now time.Time) *Service {
+	service := NewService(repo)
+	service.now = func() time.Time { return now }
+	return service
+}
+
+func TestCheckURLs(t *testing.T) {
+	repo := &memoryRepo{plan: "free", urls: 49}
+	service := newTestService(repo, time.Now())
+
+	if err := service.CheckURLs(context.Background(), "user-1", 1); err != nil {
+		t.Fatalf("expected the 50th URL to be allowed, got %v", err)
+	}
+
+	repo.urls = 50
+	var limitErr *LimitError
+	err := service.CheckURLs(context.Background(), "user-1", 1)
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("expected a LimitError, got %v", err)
+	}
+	if limitErr.Resource != ResourceURLs || limitErr.Limit != 50 || limitErr.Used != 50 {
+		t.Errorf("unexpected limit error %+v", limitErr)
+	}
+	if limitErr.StatusCode() != http.StatusForbidden {
+		t.Errorf("expected 403 for the URL limit, got %d", limitErr.StatusCode())
+	}
+
+	// Unlimited plans never count
+	repo.plan = "enterprise"
+	repo.urls = 100000
+	if err := service.CheckURLs(context.Background(), "user-1", 1); err != nil {
+		t.Errorf("expected no limit on the enterprise plan, got %v", err)
+	}
+}
+
+func TestCheckCrawlsResetsDaily(t *testing.T) {
+	now := time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC)
+	repo := &memoryRepo{plan: "free"}
+	// Crawls of the previous day do not count
+	for i := 0; i < 200; i++ {
+		repo.crawlsQueued = append(repo.crawlsQueued, now.Add(-24*time.Hour))
+	}
+	for i := 0; i < 199; i++ {
+		repo.crawlsQueued = append(repo.crawlsQueued, now.Add(-time.Hour))
+	}
+	service := newTestService(repo, now)
+
+	if err := service.CheckCrawls(context.Background(), "user-1", 1); err != nil {
+		t.Fatalf("expected the 200th crawl of the day to be allowed, got %v", err)
+	}
+
+	var limitErr *LimitError
+	err := service.CheckCrawls(context.Background(), "user-1", 2)
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("expected a LimitError, got %v", err)
+	}
+	if limitErr.StatusCode() != http.StatusTooManyRequests {
+		t.Errorf("expected 429 for the daily crawl quota, got %d", limitErr.StatusCode())
+	}
+	if want := time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC); !limitErr.RetryAt.Equal(want) {
+		t.Errorf("expected the quota to reset at %v, got %v", want, limitErr.RetryAt)
+	}
+}
+
+func TestCheckScheduleInterval(t *testing.T) {
+	now := time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC)
+	repo := &memoryRepo{plan: "pro", latestSchedule: map[string]time.Time{"url-1": now.Add(-30 * time.Minute)}}
+	service := newTestService(repo, now)
+
+	var limitErr *LimitError
+	err := service.CheckScheduleInterval(context.Background(), "user-1", "url-1")
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("expected a LimitError, got %v", err)
+	}
+	if want := now.Add(30 * time.Minute); !limitErr.RetryAt.Equal(want) {
+		t.Errorf("expected a retry at %v, got %v", want, limitErr.RetryAt)
+	}
+
+	if err := service.CheckScheduleInterval(context.Background(), "user-1", "url-2"); err != nil {
+		t.Errorf("expected a URL without scheduled crawls to be allowed, got %v", err)
+	}
+}
+
+func TestGetUsage(t *testing.T) {
+	now := time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC)
+	repo := &memoryRepo{plan: "unknown", urls: 3, crawlsQueued: []time.Time{now.Add(-time.Minute)}}
+	service := newTestService(repo, now)
+
+	usage, err := service.GetUsage(context.Background(), "user-1")
+	if err != nil {
+		t.Fatalf("GetUsage failed: %v", err)
+	}
+	if usage.Plan != DefaultPlan {
+		t.Errorf("expected unknown plans to fall back to %q, got %q", DefaultPlan, usage.Plan)
+	}
+	if usage.URLs != (UsageItem{Used: 3, Limit: 50}) || usage.CrawlsToday != (UsageItem{Used: 1, Limit: 200}) {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+}
@@ -0,0 +1,156 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sykell-backend/internal/config"
+	"time"
+)
+
+// Limiter defaults that are not configurable through the environment
+const (
+	DefaultMaxBackoff    = 10 * time.Minute // Cap on the delay requested by Retry-After
+	DefaultRetryAfter    = 30 * time.Second // Backoff after a 429 response without Retry-After
+	DefaultCrawlDelayTTL = 24 * time.Hour   // How long a host's robots.txt Crawl-delay is cached
+	MaxCrawlDelay        = 30 * time.Second // Cap on the Crawl-delay honored from robots.txt
+	RobotsAgent          = "SykellBot"      // Product token matched against robots.txt User-agent lines
+)
+
+// RateLimitError is returned when the next request slot of a host is further away than the maximum wait
+type RateLimitError struct {
+	Host string
+	Wait time.Duration
+}
+
+// Error implements the error interface
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit for host %s exceeded, next slot in %s", e.Host, e.Wait.Round(time.Second))
+}
+
+// Options configures the pacing of requests to each host
+type Options struct {
+	Interval      time.Duration // Time between requests to the same host once the burst is used up
+	Burst         int           // Requests that may be sent back to back to an idle host
+	MaxWait       time.Duration // Longest a request waits for its slot before failing with a RateLimitError
+	MaxBackoff    time.Duration
+	CrawlDelayTTL time.Duration
+}
+
+// OptionsFromConfig returns the limiter options of the configuration
+func OptionsFromConfig(cfg *config.Config) Options {
+	return Options{
+		Interval:      cfg.CrawlHostInterval,
+		Burst:         cfg.CrawlHostBurst,
+		MaxWait:       cfg.CrawlHostMaxWait,
+		MaxBackoff:    DefaultMaxBackoff,
+		CrawlDelayTTL: DefaultCrawlDelayTTL,
+	}
+}
+
+// Limiter paces requests per host with a token bucket whose state lives in the database,
+// so every worker process shares the same budget for a host
+type Limiter struct {
+	repo   Repo
+	opts   Options
+	robots *http.Client
+	now    func() time.Time
+}
+
+// NewLimiter creates a limiter, the robots client fetches robots.txt and must not be rate limited itself
+func NewLimiter(repo Repo, opts Options, robots *http.Client) *Limiter {
+	if opts.Burst < 1 {
+		opts.Burst = 1
+	}
+	return &Limiter{
+		repo:   repo,
+		opts:   opts,
+		robots: robots,
+		now:    time.Now,
+	}
+}
+
+// Wait blocks until a request to the URL's host may be sent
+func (l *Limiter) Wait(ctx context.Context, u *url.URL) error {
+	host := hostKey(u)
+	interval, burst, err := l.pace(ctx, u, host)
+	if err != nil {
+		return err
+	}
+
+	wait, err := l.repo.Reserve(ctx, host, l.now().UnixMilli(), interval, burst, l.opts.MaxWait)
+	if err != nil {
+		return err
+	}
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Backoff holds back every request to the URL's host for the given delay, capped at the maximum backoff
+func (l *Limiter) Backoff(ctx context.Context, u *url.URL, delay time.Duration) error {
+	if l.opts.MaxBackoff > 0 && delay > l.opts.MaxBackoff {
+		delay = l.opts.MaxBackoff
+	}
+	return l.repo.Backoff(ctx, hostKey(u), l.now().Add(delay).UnixMilli())
+}
+
+// pace returns the interval and burst of the host, a Crawl-delay from robots.txt slows the host down
+// to one request per delay
+func (l *Limiter) pace(ctx context.Context, u *url.URL, host string) (time.Duration, int, error) {
+	now := l.now()
+	delay, fresh, err := l.repo.GetCrawlDelay(ctx, host, now.UnixMilli())
+	if err != nil {
+		return 0, 0, err
+	}
+	if !fresh {
+		delay = l.fetchCrawlDelay(ctx, u)
+		if err := l.repo.SaveCrawlDelay(ctx, host, delay, now.Add(l.opts.CrawlDelayTTL).UnixMilli()); err != nil {
+			return 0, 0, err
+		}
+	}
+	if delay > l.opts.Interval {
+		return delay, 1, nil
+	}
+	return l.opts.Interval, l.opts.Burst, nil
+}
+
+// fetchCrawlDelay reads the Crawl-delay of the host's robots.txt, unreachable or missing files mean no delay
+func (l *Limiter) fetchCrawlDelay(ctx context.Context, u *url.URL) time.Duration {
+	if l.robots == nil {
+		return 0
+	}
+	robotsURL := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	delay, err := fetchRobotsCrawlDelay(ctx, l.robots, robotsURL.String(), RobotsAgent)
+	if err != nil {
+		return 0
+	}
+	if delay > MaxCrawlDelay {
+		return MaxCrawlDelay
+	}
+	return delay
+}
+
+// reserve computes how long a request must wait and the host's next slot using the generic cell rate algorithm,
+// a token bucket refilled with one token per interval and holding up to burst tokens
+func reserve(nowMs int64, nextSlotMs int64, blockedUntilMs int64, interval time.Duration, burst int) (time.Duration, int64) {
+	intervalMs := interval.Milliseconds()
+	tat := max(nextSlotMs, nowMs)
+	allowAt := max(tat-int64(burst-1)*intervalMs, nowMs, blockedUntilMs)
+	return time.Duration(allowAt-nowMs) * time.Millisecond, max(tat, allowAt) + intervalMs
+}
+
+// hostKey identifies the host of the URL, the port is kept since different ports may be different servers
+func hostKey(u *url.URL) string {
+	return strings.ToLower(u.Host)
+}
@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryRepo keeps the rate limit state in memory, applying the same reservation rules as the database repository
+type memoryRepo struct {
+	mu           sync.Mutex
+	nextSlot     map[string]int64
+	blockedUntil map[string]int64
+	crawlDelay   map[string]time.Duration
+}
+
+func newMemoryRepo() *memoryRepo {
+	return &memoryRepo{
+		nextSlot:     map[string]int64{},
+		blockedUntil: map[string]int64{},
+		crawlDelay:   map[string]time.Duration{},
+	}
+}
+
+func (r *memoryRepo) Reserve(ctx context.Context, host string, nowMs int64, interval time.Duration, burst int, maxWait time.Duration) (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	wait, next := reserve(nowMs, r.nextSlot[host], r.blockedUntil[host], interval, burst)
+	if maxWait > 0 && wait > maxWait {
+		return 0, &RateLimitError{Host: host, Wait: wait}
+	}
+	r.nextSlot[host] = next
+	return wait, nil
+}
+
+func (r *memoryRepo) Backoff(ctx context.Context, host string, untilMs int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.blockedUntil[host] = max(r.blockedUntil[host], untilMs)
+	return nil
+}
+
+func (r *memoryRepo) GetCrawlDelay(ctx context.Context, host string, nowMs int64) (time.Duration, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delay, ok := r.crawlDelay[host]
+	return delay, ok, nil
+}
+
+func (r *memoryRepo) SaveCrawlDelay(ctx context.Context, host string, delay time.Duration, expiresMs int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.crawlDelay[host] = delay
+	return nil
+}
+
+func TestReserve(t *testing.T) {
+	const now = int64(100_000)
+	interval := time.Second
+
+	// An idle host allows a burst of requests, then one per interval
+	next := int64(0)
+	var waits []time.Duration
+	for i := 0; i < 4; i++ {
+		var wait time.Duration
+		wait, next = reserve(now, next, 0, interval, 2)
+		waits = append(waits, wait)
+	}
+	want := []time.Duration{0, 0, time.Second, 2 * time.Second}
+	for i := range want {
+		if waits[i] != want[i] {
+			t.Errorf("reservation %d wait = %v, want %v", i, waits[i], want[i])
+		}
+	}
+
+	// A backoff holds every request until it expires
+	wait, _ := reserve(now, 0, now+30_000, interval, 2)
+	if wait != 30*time.Second {
+		t.Errorf("wait during backoff = %v, want 30s", wait)
+	}
+}
+
+func TestLimiterUsesCrawlDelay(t *testing.T) {
+	repo := newMemoryRepo()
+	repo.crawlDelay["example.com"] = 5 * time.Second
+	limiter := NewLimiter(repo, Options{Interval: 500 * time.Millisecond, Burst: 4, MaxWait: time.Second}, nil)
+	u, _ := url.Parse("https://example.com/page")
+
+	if err := limiter.Wait(context.Background(), u); err != nil {
+		t.Fatalf("first Wait() error = %v", err)
+	}
+	// The Crawl-delay removes the burst, the second request would wait 5s which exceeds the maximum wait
+	var rateLimitErr *RateLimitError
+	if err := limiter.Wait(context.Background(), u); !errors.As(err, &rateLimitErr) {
+		t.Fatalf("second Wait() error = %v, want a RateLimitError", err)
+	}
+	if rateLimitErr.Wait != 5*time.Second {
+		t.Errorf("RateLimitError.Wait = %v, want 5s", rateLimitErr.Wait)
+	}
+}
+
+func TestTransportBacksOffOnRetryAfter(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	repo := newMemoryRepo()
+	limiter := NewLimiter(repo, Options{Interval: 10 * time.Millisecond, Burst: 1, MaxWait: time.Second, MaxBackoff: time.Minute}, nil)
+	client := &http.Client{Transport: NewTransport(nil, limiter)}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", resp.StatusCode)
+	}
+
+	// The host is held back for the Retry-After delay, capped at the maximum backoff
+	var rateLimitErr *RateLimitError
+	if _, err := client.Get(server.URL); !errors.As(err, &rateLimitErr) {
+		t.Fatalf("second Get() error = %v, want a RateLimitError", err)
+	}
+	if rateLimitErr.Wait < 59*time.Second || rateLimitErr.Wait > time.Minute {
+		t.Errorf("RateLimitError.Wait = %v, want about 1m", rateLimitErr.Wait)
+	}
+	if requests != 1 {
+		t.Errorf("server received %d requests, want 1", requests)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{"Wed, 01 Jan 2025 12:00:30 GMT", 30 * time.Second, true},
+		{"", 0, false},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
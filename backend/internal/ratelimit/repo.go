@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"sykell-backend/internal/config"
+	"sykell-backend/internal/db"
+	"time"
+)
+
+// Repo stores the per-host rate limit state shared by all workers
+type Repo interface {
+	Reserve(ctx context.Context, host string, nowMs int64, interval time.Duration, burst int, maxWait time.Duration) (time.Duration, error)
+	Backoff(ctx context.Context, host string, untilMs int64) error
+	GetCrawlDelay(ctx context.Context, host string, nowMs int64) (time.Duration, bool, error)
+	SaveCrawlDelay(ctx context.Context, host string, delay time.Duration, expiresMs int64) error
+}
+
+type rateLimitRepo struct {
+	sqlDB *sql.DB
+}
+
+// NewRepo creates a new instance of the rate limit repository
+func NewRepo(sqlDB *sql.DB) Repo {
+	return &rateLimitRepo{sqlDB: sqlDB}
+}
+
+// Reserve takes the next request slot of the host under a row lock and returns how long to wait for it,
+// the slot is not taken and a RateLimitError is returned when the wait would exceed maxWait
+func (r *rateLimitRepo) Reserve(ctx context.Context, host string, nowMs int64, interval time.Duration, burst int, maxWait time.Duration) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	if err := queries.EnsureHostRateLimit(ctx, host); err != nil {
+		return 0, err
+	}
+
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	queries = queries.WithTx(tx)
+
+	state, err := queries.GetHostRateLimitForUpdate(ctx, host)
+	if err != nil {
+		return 0, err
+	}
+	wait, nextSlotMs := reserve(nowMs, state.NextSlotMs, state.BlockedUntilMs, interval, burst)
+	if maxWait > 0 && wait > maxWait {
+		return 0, &RateLimitError{Host: host, Wait: wait}
+	}
+	err = queries.UpdateHostNextSlot(ctx, db.UpdateHostNextSlotParams{
+		NextSlotMs: nextSlotMs,
+		Host:       host,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return wait, tx.Commit()
+}
+
+// Backoff pushes back the earliest time a request may be sent to the host, it never shortens an existing backoff
+func (r *rateLimitRepo) Backoff(ctx context.Context, host string, untilMs int64) error {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	if err := queries.EnsureHostRateLimit(ctx, host); err != nil {
+		return err
+	}
+	err := queries.ExtendHostBlockedUntil(ctx, db.ExtendHostBlockedUntilParams{
+		BlockedUntilMs: untilMs,
+		Host:           host,
+	})
+	return err
+}
+
+// GetCrawlDelay returns the cached Crawl-delay of the host and whether it is still fresh
+func (r *rateLimitRepo) GetCrawlDelay(ctx context.Context, host string, nowMs int64) (time.Duration, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	row, err := queries.GetHostCrawlDelay(ctx, host)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if row.CrawlDelayExpiresMs <= nowMs {
+		return 0, false, nil
+	}
+	return time.Duration(row.CrawlDelayMs.Int32) * time.Millisecond, true, nil
+}
+
+// SaveCrawlDelay caches the Crawl-delay of the host until expiresMs, a zero delay means the host sets none
+func (r *rateLimitRepo) SaveCrawlDelay(ctx context.Context, host string, delay time.Duration, expiresMs int64) error {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	if err := queries.EnsureHostRateLimit(ctx, host); err != nil {
+		return err
+	}
+	err := queries.UpdateHostCrawlDelay(ctx, db.UpdateHostCrawlDelayParams{
+		CrawlDelayMs:        sql.NullInt32{Int32: int32(delay.Milliseconds()), Valid: delay > 0},
+		CrawlDelayExpiresMs: expiresMs,
+		Host:                host,
+	})
+	return err
+}
@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRobotsSize limits how much of a robots.txt file is read
+const maxRobotsSize = 512 << 10
+
+// fetchRobotsCrawlDelay downloads a robots.txt file and returns the Crawl-delay that applies to the agent,
+// a missing file means no delay
+func fetchRobotsCrawlDelay(ctx context.Context, client *http.Client, robotsURL string, agent string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", robotsURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; "+agent+"/1.0)")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("robots.txt returned HTTP %d", resp.StatusCode)
+	}
+	return parseCrawlDelay(io.LimitReader(resp.Body, maxRobotsSize), agent), nil
+}
+
+// parseCrawlDelay returns the Crawl-delay of the group matching the agent, falling back to the * group
+// only when no group names the agent
+func parseCrawlDelay(r io.Reader, agent string) time.Duration {
+	agent = strings.ToLower(agent)
+	var (
+		groupAgents   []string
+		inRules       bool
+		agentDelay    time.Duration
+		agentMatched  bool
+		wildcardDelay time.Duration
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// A user-agent line after rules starts a new group
+			if inRules {
+				groupAgents = nil
+				inRules = false
+			}
+			groupAgents = append(groupAgents, strings.ToLower(value))
+			if strings.ToLower(value) == agent {
+				agentMatched = true
+			}
+		case "crawl-delay":
+			inRules = true
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds <= 0 {
+				continue
+			}
+			delay := time.Duration(seconds * float64(time.Second))
+			for _, groupAgent := range groupAgents {
+				if groupAgent == agent {
+					agentDelay = delay
+				} else if groupAgent == "*" {
+					wildcardDelay = delay
+				}
+			}
+		default:
+			inRules = true
+		}
+	}
+
+	if agentMatched {
+		return agentDelay
+	}
+	return wildcardDelay
+}
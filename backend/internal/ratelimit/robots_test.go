@@ -0,0 +1,32 @@
+package ratelimit
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCrawlDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		robots string
+		want   time.Duration
+	}{
+		{"No delay", "User-agent: *\nDisallow: /admin\n", 0},
+		{"Wildcard delay", "User-agent: *\nCrawl-delay: 2\n", 2 * time.Second},
+		{"Fractional delay", "User-agent: *\nCrawl-delay: 0.5 # half a second\n", 500 * time.Millisecond},
+		{"Agent group wins", "User-agent: *\nCrawl-delay: 10\n\nUser-agent: SykellBot\nCrawl-delay: 3\n", 3 * time.Second},
+		{"Agent group without delay", "User-agent: *\nCrawl-delay: 10\n\nUser-agent: sykellbot\nDisallow: /private\n", 0},
+		{"Shared group", "User-agent: Googlebot\nUser-agent: SykellBot\nCrawl-delay: 4\n", 4 * time.Second},
+		{"Other agent only", "User-agent: Googlebot\nCrawl-delay: 4\n", 0},
+		{"Invalid value", "User-agent: *\nCrawl-delay: soon\n", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseCrawlDelay(strings.NewReader(tt.robots), RobotsAgent); got != tt.want {
+				t.Errorf("parseCrawlDelay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transport is an http.RoundTripper that waits for the host's rate limit before every request,
+// including redirects, and backs off when the host answers 429 or 503 with Retry-After
+type Transport struct {
+	Base    http.RoundTripper
+	Limiter *Limiter
+}
+
+// NewTransport wraps the base transport with the limiter
+func NewTransport(base http.RoundTripper, limiter *Limiter) *Transport {
+	return &Transport{Base: base, Limiter: limiter}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.Limiter.Wait(req.Context(), req.URL); err != nil {
+		return nil, err
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), t.Limiter.now())
+		if !ok && resp.StatusCode == http.StatusTooManyRequests {
+			delay, ok = DefaultRetryAfter, true
+		}
+		if ok {
+			// The response is still returned, a failed backoff only loses the hint
+			t.Limiter.Backoff(req.Context(), req.URL, delay)
+		}
+	}
+	return resp, nil
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := date.Sub(now); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
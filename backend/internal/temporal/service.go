@@ -1,16 +1,31 @@
 package temporal
 
 import (
+	"errors"
+	"fmt"
+	"sync"
 	"sykell-backend/internal/config"
 	"time"
 
+	"go.temporal.io/api/serviceerror"
 	"go.temporal.io/sdk/client"
 )
 
+// ErrUnavailable is returned when the Temporal server cannot be reached
+var ErrUnavailable = errors.New("temporal is unavailable")
+
+// dialRetryInterval is how long after a failed connection requests fail right away instead of dialing again
+const dialRetryInterval = 5 * time.Second
+
 // Service provides Temporal-related services
 type Service struct {
 	config *config.Config
+	mu sync.Mutex
 	temporalClient client.Client
+	dialing bool      // A connection is being established outside the lock
+	lastErr error     // Error of the last failed connection
+	lastFailure time.Time
+	closed bool
 }
 
 // NewService creates a new Temporal Service
@@ -22,9 +37,15 @@ func NewService(config *config.Config) *Service {
 }
 
 
-// Setup initializes the Temporal client
-func (s *Service) Setup() {
-	s.temporalClient, _ = client.Dial(client.Options{
+// Setup initializes the Temporal client, a failed connection is retried by the next call to Client
+func (s *Service) Setup() error {
+	_, err := s.Client()
+	return err
+}
+
+// dial connects to the Temporal server, it is called without the lock so a slow connection blocks no other caller
+func (s *Service) dial() (client.Client, error) {
+	temporalClient, err := client.Dial(client.Options{
 		HostPort:  s.config.TemporalHostPort,
 		Namespace: s.config.Namespace,
 		ConnectionOptions: client.ConnectionOptions{
@@ -33,19 +54,71 @@ func (s *Service) Setup() {
 			KeepAliveTimeout: 20 * time.Second, // 20 seconds			
 		},		
 	})	
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return temporalClient, nil
 }
 
-// GetTemporalClient returns the Temporal client, initializing it if necessary
-func (s *Service) GetTemporalClient() client.Client {
-	if s.temporalClient == nil {
-		s.Setup()
+// Client returns the Temporal client, connecting to the server if necessary. It returns ErrUnavailable
+// when the server cannot be reached, right away while another caller is connecting or shortly after a
+// failed connection
+func (s *Service) Client() (client.Client, error) {
+	s.mu.Lock()
+	if s.temporalClient != nil {
+		defer s.mu.Unlock()
+		return s.temporalClient, nil
+	}
+	if s.closed {
+		s.mu.Unlock()
+		return nil, ErrUnavailable
+	}
+	if s.dialing || (s.lastErr != nil && time.Since(s.lastFailure) < dialRetryInterval) {
+		err := s.lastErr
+		s.mu.Unlock()
+		if err == nil {
+			err = ErrUnavailable
+		}
+		return nil, err
 	}
-	return s.temporalClient
+	s.dialing = true
+	s.mu.Unlock()
+
+	temporalClient, err := s.dial()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dialing = false
+	if err != nil {
+		s.lastErr = err
+		s.lastFailure = time.Now()
+		return nil, err
+	}
+	// The service was closed while connecting
+	if s.closed {
+		temporalClient.Close()
+		return nil, ErrUnavailable
+	}
+	s.lastErr = nil
+	s.temporalClient = temporalClient
+	return temporalClient, nil
 }
 
 // Close closes the Temporal client connection
 func (s *Service) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
 	if s.temporalClient != nil {
 		s.temporalClient.Close()
 	}
-}
\ No newline at end of file
+}
+
+// IsUnavailable reports whether the error means the Temporal server could not be reached or did not answer in time
+func IsUnavailable(err error) bool {
+	var unavailable *serviceerror.Unavailable
+	var deadlineExceeded *serviceerror.DeadlineExceeded
+	return errors.Is(err, ErrUnavailable) ||
+		errors.As(err, &unavailable) ||
+		errors.As(err, &deadlineExceeded)
+}
@@ -55,6 +55,5 @@ func (s *Service) AddURL(ctx context.Context, userID string, request AddRequest)
 	if err != nil {
 		return err
 	}	
-	s.repo.CreateURL(ctx, userID, normalizeURL, domain)
-	return nil
+	return s.repo.CreateURL(ctx, userID, normalizeURL, domain, s.quotas)
 }
\ No newline at end of file
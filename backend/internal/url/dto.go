@@ -12,6 +12,9 @@ type CrawlResult struct {
 	UrlCreatedAt           *time.Time `json:"url_created_at"`
 	CrawlID                *string   `json:"crawl_id"`
 	Status                 *string   `json:"status"`
+	Outcome                *string   `json:"outcome"`
+	MimeType               *string   `json:"mime_type"`
+	ContentLength          *int64    `json:"content_length"`
 	QueuedAt               *time.Time `json:"queued_at"`
 	StartedAt              *time.Time `json:"started_at"`
 	FinishedAt             *time.Time `json:"finished_at"`
@@ -26,7 +29,10 @@ type CrawlResult struct {
 	InternalLinksCount     *int32    `json:"internal_links_count"`
 	ExternalLinksCount     *int32    `json:"external_links_count"`
 	InaccessibleLinksCount *int32    `json:"inaccessible_links_count"`
+	BrokenAnchorsCount     *int32    `json:"broken_anchors_count"`
 	HasLoginForm           *bool     `json:"has_login_form"`
+	FormTypes              []string  `json:"form_types"`
+	FlaggedFormsCount      *int32    `json:"flagged_forms_count"`
 	ErrorMessage           *string   `json:"error_message"`
 	CrawlCreatedAt         *time.Time `json:"crawl_created_at"`
 	CrawlUpdatedAt         *time.Time `json:"crawl_updated_at"`
@@ -54,4 +60,97 @@ type AddRequest struct {
 	URL string `json:"url" validate:"required,url"`
 }
 
+// PageFingerprint holds the title and content fingerprints of a URL's latest completed crawl
+type PageFingerprint struct {
+	UrlID         string
+	NormalizedUrl string
+	CrawlID       string
+	PageTitle     string
+	ContentHash   string
+	HasSimHash    bool
+	SimHash       uint64
+}
+
+// DuplicateURL represents a URL that belongs to a duplicate group
+type DuplicateURL struct {
+	UrlID         string `json:"url_id"`
+	NormalizedUrl string `json:"normalized_url"`
+	CrawlID       string `json:"crawl_id"`
+	PageTitle     string `json:"page_title"`
+}
+
+// DuplicateGroup represents a set of URLs that share a title, content or near-identical content
+type DuplicateGroup struct {
+	Kind       string         `json:"kind"`                 // "title", "content" or "near_duplicate"
+	Key        string         `json:"key,omitempty"`        // Shared title or content hash
+	Similarity float64        `json:"similarity,omitempty"` // Lowest similarity between linked URLs of a near-duplicate group
+	Urls       []DuplicateURL `json:"urls"`
+}
+
+// DuplicatesResponse represents the duplicate content report for a user's URLs
+type DuplicatesResponse struct {
+	Threshold float64          `json:"threshold"`
+	Groups    []DuplicateGroup `json:"groups"`
+}
+
+// StoredRequestProfile represents a URL's request profile as stored, with its secrets still encrypted
+type StoredRequestProfile struct {
+	UrlID                string
+	UserAgent            string
+	BasicAuthUsername    string
+	EncryptedSecrets     string
+	ApplyToInternalLinks bool
+	LoginURL             string
+	LoginUsernameField   string
+	LoginPasswordField   string
+	LoginUsername        string
+	UpdatedAt            *time.Time
+}
+
+// RequestProfileRequest represents the request payload for saving a URL's request profile,
+// omitted headers, cookies, password or proxy keep their stored values
+type RequestProfileRequest struct {
+	UserAgent            string              `json:"user_agent"`
+	Headers              map[string]string   `json:"headers"`
+	Cookies              map[string]string   `json:"cookies"`
+	BasicAuthUsername    string              `json:"basic_auth_username"`
+	BasicAuthPassword    *string             `json:"basic_auth_password"`
+	ApplyToInternalLinks bool                `json:"apply_to_internal_links"`
+	Login                *LoginRecipeRequest `json:"login"`     // Omitted to crawl without logging in
+	ProxyURL             *string             `json:"proxy_url"` // Omitted to keep the stored proxy, empty to use the default
+}
+
+// LoginRecipeRequest represents the login form submitted before crawling a URL,
+// an omitted password or extra fields keep their stored values
+type LoginRecipeRequest struct {
+	LoginURL      string            `json:"login_url"`
+	UsernameField string            `json:"username_field"`
+	PasswordField string            `json:"password_field"`
+	Username      string            `json:"username"`
+	Password      *string           `json:"password"`
+	ExtraFields   map[string]string `json:"extra_fields"`
+}
+
+// RequestProfileResponse represents a URL's request profile, secret values are never returned
+type RequestProfileResponse struct {
+	UrlID                string               `json:"url_id"`
+	UserAgent            string               `json:"user_agent"`
+	HeaderNames          []string             `json:"header_names"`
+	CookieNames          []string             `json:"cookie_names"`
+	BasicAuthUsername    string               `json:"basic_auth_username"`
+	HasBasicAuthPassword bool                 `json:"has_basic_auth_password"`
+	ApplyToInternalLinks bool                 `json:"apply_to_internal_links"`
+	Login                *LoginRecipeResponse `json:"login"`
+	ProxyURL             string               `json:"proxy_url"` // Password redacted
+	UpdatedAt            *time.Time           `json:"updated_at"`
+}
 
+// LoginRecipeResponse represents a URL's login recipe, the password and extra field values are never returned
+type LoginRecipeResponse struct {
+	LoginURL        string   `json:"login_url"`
+	UsernameField   string   `json:"username_field"`
+	PasswordField   string   `json:"password_field"`
+	Username        string   `json:"username"`
+	HasPassword     bool     `json:"has_password"`
+	ExtraFieldNames []string `json:"extra_field_names"`
+}
@@ -0,0 +1,159 @@
+package url
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sykell-backend/internal/utils"
+)
+
+// Duplicate group kinds
+const (
+	DuplicateKindTitle         = "title"
+	DuplicateKindContent       = "content"
+	DuplicateKindNearDuplicate = "near_duplicate"
+)
+
+// DefaultSimilarityThreshold is the SimHash similarity above which two pages are considered near-duplicates
+const DefaultSimilarityThreshold = 0.9
+
+// FindDuplicates groups the user's URLs whose latest crawls share a title, identical content,
+// or near-duplicate content with a SimHash similarity of at least the given threshold
+func (s *Service) FindDuplicates(ctx context.Context, userID string, threshold float64) (DuplicatesResponse, error) {
+	if threshold <= 0 || threshold > 1 {
+		threshold = DefaultSimilarityThreshold
+	}
+
+	fingerprints, err := s.repo.GetLatestCrawlFingerprints(ctx, userID)
+	if err != nil {
+		return DuplicatesResponse{}, err
+	}
+
+	groups := []DuplicateGroup{}
+	groups = append(groups, groupByKey(fingerprints, DuplicateKindTitle, func(f PageFingerprint) string {
+		return strings.ToLower(strings.TrimSpace(f.PageTitle))
+	})...)
+	groups = append(groups, groupByKey(fingerprints, DuplicateKindContent, func(f PageFingerprint) string {
+		return f.ContentHash
+	})...)
+	groups = append(groups, groupNearDuplicates(fingerprints, threshold)...)
+
+	return DuplicatesResponse{
+		Threshold: threshold,
+		Groups:    groups,
+	}, nil
+}
+
+// groupByKey groups fingerprints sharing the same non-empty key, only groups with more than one URL are returned
+func groupByKey(fingerprints []PageFingerprint, kind string, key func(PageFingerprint) string) []DuplicateGroup {
+	byKey := make(map[string][]DuplicateURL)
+	var keys []string
+	for _, f := range fingerprints {
+		k := key(f)
+		if k == "" {
+			continue
+		}
+		if _, ok := byKey[k]; !ok {
+			keys = append(keys, k)
+		}
+		byKey[k] = append(byKey[k], toDuplicateURL(f))
+	}
+
+	groups := []DuplicateGroup{}
+	for _, k := range keys {
+		if len(byKey[k]) < 2 {
+			continue
+		}
+		groups = append(groups, DuplicateGroup{
+			Kind: kind,
+			Key:  k,
+			Urls: byKey[k],
+		})
+	}
+	return groups
+}
+
+// groupNearDuplicates clusters pages whose SimHash similarity reaches the threshold,
+// pages with identical content are already reported as content duplicates and are not linked again
+func groupNearDuplicates(fingerprints []PageFingerprint, threshold float64) []DuplicateGroup {
+	var candidates []PageFingerprint
+	for _, f := range fingerprints {
+		if f.HasSimHash && f.ContentHash != "" {
+			candidates = append(candidates, f)
+		}
+	}
+
+	// Union-find over the candidates, tracking the weakest link of every cluster
+	parent := make([]int, len(candidates))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	lowest := make(map[int]float64)
+
+	for i := 0; i < len(candidates); i++ {
+		for j := i + 1; j < len(candidates); j++ {
+			if candidates[i].ContentHash == candidates[j].ContentHash {
+				continue
+			}
+			similarity := utils.SimHashSimilarity(candidates[i].SimHash, candidates[j].SimHash)
+			if similarity < threshold {
+				continue
+			}
+			ri, rj := find(i), find(j)
+			min := similarity
+			if v, ok := lowest[ri]; ok && v < min {
+				min = v
+			}
+			if v, ok := lowest[rj]; ok && v < min {
+				min = v
+			}
+			delete(lowest, ri)
+			delete(lowest, rj)
+			if ri != rj {
+				parent[rj] = ri
+			}
+			lowest[ri] = min
+		}
+	}
+
+	clusters := make(map[int][]DuplicateURL)
+	var roots []int
+	for i, f := range candidates {
+		root := find(i)
+		if _, ok := lowest[root]; !ok {
+			continue
+		}
+		if _, ok := clusters[root]; !ok {
+			roots = append(roots, root)
+		}
+		clusters[root] = append(clusters[root], toDuplicateURL(f))
+	}
+	sort.Ints(roots)
+
+	groups := []DuplicateGroup{}
+	for _, root := range roots {
+		groups = append(groups, DuplicateGroup{
+			Kind:       DuplicateKindNearDuplicate,
+			Similarity: lowest[root],
+			Urls:       clusters[root],
+		})
+	}
+	return groups
+}
+
+// toDuplicateURL converts a fingerprint into its duplicate report entry
+func toDuplicateURL(f PageFingerprint) DuplicateURL {
+	return DuplicateURL{
+		UrlID:         f.UrlID,
+		NormalizedUrl: f.NormalizedUrl,
+		CrawlID:       f.CrawlID,
+		PageTitle:     f.PageTitle,
+	}
+}
@@ -0,0 +1,82 @@
+package url
+
+import (
+	"context"
+	"testing"
+
+	"sykell-backend/internal/config"
+	"sykell-backend/internal/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_FindDuplicates(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	service := NewService(NewRepo(mockDB), &config.Config{}, nil)
+
+	base := "the quick brown fox jumps over the lazy dog near the river bank on a sunny afternoon in the park"
+	near := base + " today"
+	other := "a completely different page about cooking fresh pasta with tomatoes basil and olive oil at home"
+
+	rows := sqlmock.NewRows([]string{"url_id", "normalized_url", "crawl_id", "page_title", "content_hash", "simhash"}).
+		AddRow("url-1", "https://example.com/a", "crawl-1", "Home", utils.ContentHash(base), int64(utils.SimHash(base))).
+		AddRow("url-2", "https://example.com/b", "crawl-2", "home ", utils.ContentHash(base), int64(utils.SimHash(base))).
+		AddRow("url-3", "https://example.com/c", "crawl-3", "Fox", utils.ContentHash(near), int64(utils.SimHash(near))).
+		AddRow("url-4", "https://example.com/d", "crawl-4", "Pasta", utils.ContentHash(other), int64(utils.SimHash(other))).
+		AddRow("url-5", "https://example.com/e", "crawl-5", nil, nil, nil)
+
+	mock.ExpectQuery("SELECT (.+) FROM urls u").
+		WithArgs("user-1").
+		WillReturnRows(rows)
+
+	result, err := service.FindDuplicates(context.Background(), "user-1", 0.8)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, 0.8, result.Threshold)
+
+	byKind := make(map[string][]DuplicateGroup)
+	for _, group := range result.Groups {
+		byKind[group.Kind] = append(byKind[group.Kind], group)
+	}
+
+	require.Len(t, byKind[DuplicateKindTitle], 1)
+	assert.Equal(t, "home", byKind[DuplicateKindTitle][0].Key)
+	assert.Len(t, byKind[DuplicateKindTitle][0].Urls, 2)
+
+	require.Len(t, byKind[DuplicateKindContent], 1)
+	assert.Len(t, byKind[DuplicateKindContent][0].Urls, 2)
+
+	require.Len(t, byKind[DuplicateKindNearDuplicate], 1)
+	nearGroup := byKind[DuplicateKindNearDuplicate][0]
+	assert.GreaterOrEqual(t, nearGroup.Similarity, 0.8)
+	var ids []string
+	for _, u := range nearGroup.Urls {
+		ids = append(ids, u.UrlID)
+	}
+	assert.Contains(t, ids, "url-3")
+	assert.NotContains(t, ids, "url-4")
+	assert.NotContains(t, ids, "url-5")
+}
+
+func TestService_FindDuplicates_DefaultThreshold(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	service := NewService(NewRepo(mockDB), &config.Config{}, nil)
+
+	mock.ExpectQuery("SELECT (.+) FROM urls u").
+		WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"url_id", "normalized_url", "crawl_id", "page_title", "content_hash", "simhash"}))
+
+	result, err := service.FindDuplicates(context.Background(), "user-1", 0)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultSimilarityThreshold, result.Threshold)
+	assert.Empty(t, result.Groups)
+}
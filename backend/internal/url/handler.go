@@ -1,8 +1,10 @@
 package url
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"sykell-backend/internal/quota"
 
 	"github.com/labstack/echo/v4"
 )
@@ -34,6 +36,10 @@ func (h *Handler) AddURL(c echo.Context) error {
 	ctx := c.Request().Context()
 
 	err := h.urlService.AddURL(ctx, userID.(string), req)
+	var limitErr *quota.LimitError
+	if errors.As(err, &limitErr) {
+		return quota.RespondLimit(c, limitErr)
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": "Failed to retrieve user profile",
@@ -97,4 +103,111 @@ func (h *Handler) ListURLs(c echo.Context) error {
 	return c.JSON(http.StatusOK, result)
 }
 
-	
\ No newline at end of file
+// FindDuplicates handles listing groups of URLs with duplicate or near-duplicate content
+func (h *Handler) FindDuplicates(c echo.Context) error {
+	userID := c.Get("user_id")
+	ctx := c.Request().Context()
+
+	threshold := DefaultSimilarityThreshold
+	if value := c.QueryParam("threshold"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed <= 0 || parsed > 1 {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "Threshold must be a number between 0 and 1",
+			})
+		}
+		threshold = parsed
+	}
+
+	result, err := h.urlService.FindDuplicates(ctx, userID.(string), threshold)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{
+			"error": "Failed to find duplicate URLs",
+		})
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// GetRequestProfile handles retrieving the request profile of a URL
+func (h *Handler) GetRequestProfile(c echo.Context) error {
+	userID := c.Get("user_id")
+	urlID := c.Param("id")
+	if urlID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Missing URL ID",
+		})
+	}
+
+	ctx := c.Request().Context()
+
+	profile, err := h.urlService.GetRequestProfile(ctx, userID.(string), urlID)
+	if err != nil {
+		return requestProfileError(c, err, "Failed to retrieve request profile")
+	}
+	return c.JSON(http.StatusOK, profile)
+}
+
+// SaveRequestProfile handles creating or updating the request profile of a URL
+func (h *Handler) SaveRequestProfile(c echo.Context) error {
+	userID := c.Get("user_id")
+	urlID := c.Param("id")
+	if urlID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Missing URL ID",
+		})
+	}
+
+	var req RequestProfileRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid request body",
+		})
+	}
+
+	ctx := c.Request().Context()
+
+	profile, err := h.urlService.SaveRequestProfile(ctx, userID.(string), urlID, req)
+	if err != nil {
+		return requestProfileError(c, err, "Failed to save request profile")
+	}
+	return c.JSON(http.StatusOK, profile)
+}
+
+// DeleteRequestProfile handles removing the request profile of a URL
+func (h *Handler) DeleteRequestProfile(c echo.Context) error {
+	userID := c.Get("user_id")
+	urlID := c.Param("id")
+	if urlID == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Missing URL ID",
+		})
+	}
+
+	ctx := c.Request().Context()
+
+	if err := h.urlService.DeleteRequestProfile(ctx, userID.(string), urlID); err != nil {
+		return requestProfileError(c, err, "Failed to delete request profile")
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// requestProfileError maps request profile errors to their HTTP responses
+func requestProfileError(c echo.Context, err error, message string) error {
+	switch {
+	case errors.Is(err, ErrURLNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "URL not found",
+		})
+	case errors.Is(err, ErrRequestProfileNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "Request profile not found",
+		})
+	case errors.Is(err, ErrInvalidRequestProfile):
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{
+		"error": message,
+	})
+}
@@ -8,7 +8,7 @@ import (
 func (s *Service) FindUrls(ctx context.Context, userID string, filters DashboardFilters) (PaginatedUrls, error) {
 	
 	// Map frontend sort column names to backend column names
-	sortBy := mapSortColumn(filters.SortBy)
+	sortBy := MapSortColumn(filters.SortBy)
 	sortDir := filters.SortOrder
 	if sortDir == "" {
 		sortDir = "desc"
@@ -33,8 +33,8 @@ func (s *Service) FindUrls(ctx context.Context, userID string, filters Dashboard
 	}, nil
 }
 
-// mapSortColumn maps frontend sort column names to backend column names
-func mapSortColumn(frontendColumn string) string {
+// MapSortColumn maps frontend sort column names to backend column names
+func MapSortColumn(frontendColumn string) string {
 	columnMap := map[string]string{
 		"url":                 "normalized_url",
 		"domain":              "domain",
@@ -44,6 +44,7 @@ func mapSortColumn(frontendColumn string) string {
 		"internal_links":      "internal_links_count",
 		"external_links":      "external_links_count",
 		"inaccessible_links":  "inaccessible_links_count",
+		"broken_anchors":      "broken_anchors_count",
 		"h1_count":            "h1_count",
 		"h2_count":            "h2_count",
 		"h3_count":            "h3_count",
@@ -51,6 +52,7 @@ func mapSortColumn(frontendColumn string) string {
 		"h5_count":            "h5_count",
 		"h6_count":            "h6_count",
 		"has_login_form":      "has_login_form",
+		"flagged_forms":       "flagged_forms_count",
 		"created_at":          "url_created_at",
 		"finished_at":         "finished_at",
 	}
@@ -0,0 +1,191 @@
+package url
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sykell-backend/internal/utils"
+)
+
+// Errors returned by the request profile operations
+var (
+	ErrURLNotFound            = errors.New("url not found")
+	ErrInvalidRequestProfile  = errors.New("invalid request profile")
+	ErrRequestProfileNotFound = errors.New("request profile not found")
+)
+
+// GetRequestProfile returns the request profile of the user's URL without its secret values
+func (s *Service) GetRequestProfile(ctx context.Context, userID string, urlID string) (*RequestProfileResponse, error) {
+	profile, stored, err := s.loadRequestProfile(ctx, userID, urlID)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, ErrRequestProfileNotFound
+	}
+	return toRequestProfileResponse(urlID, profile, stored), nil
+}
+
+// SaveRequestProfile creates or updates the request profile of the user's URL, encrypting its secrets
+func (s *Service) SaveRequestProfile(ctx context.Context, userID string, urlID string, req RequestProfileRequest) (*RequestProfileResponse, error) {
+	profile, _, err := s.loadRequestProfile(ctx, userID, urlID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Omitted secrets keep their stored values since they are never sent back to the client
+	profile.UserAgent = req.UserAgent
+	profile.BasicAuthUsername = req.BasicAuthUsername
+	profile.ApplyToInternalLinks = req.ApplyToInternalLinks
+	if req.Headers != nil {
+		profile.Headers = req.Headers
+	}
+	if req.Cookies != nil {
+		profile.Cookies = req.Cookies
+	}
+	if req.BasicAuthPassword != nil {
+		profile.BasicAuthPassword = *req.BasicAuthPassword
+	}
+	if profile.BasicAuthUsername == "" {
+		profile.BasicAuthPassword = ""
+	}
+	profile.Login = mergeLoginRecipe(profile.Login, req.Login)
+	if req.ProxyURL != nil {
+		profile.ProxyURL = *req.ProxyURL
+	}
+
+	if err := profile.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequestProfile, err)
+	}
+
+	sealed, err := profile.SealSecrets(utils.DeriveKey(s.config.SecretsKey))
+	if err != nil {
+		return nil, err
+	}
+	stored := StoredRequestProfile{
+		UrlID:                urlID,
+		UserAgent:            profile.UserAgent,
+		BasicAuthUsername:    profile.BasicAuthUsername,
+		EncryptedSecrets:     sealed,
+		ApplyToInternalLinks: profile.ApplyToInternalLinks,
+	}
+	if profile.Login != nil {
+		stored.LoginURL = profile.Login.LoginURL
+		stored.LoginUsernameField = profile.Login.UsernameField
+		stored.LoginPasswordField = profile.Login.PasswordField
+		stored.LoginUsername = profile.Login.Username
+	}
+	if err := s.repo.SaveRequestProfile(ctx, stored); err != nil {
+		return nil, err
+	}
+	return toRequestProfileResponse(urlID, profile, &stored), nil
+}
+
+// DeleteRequestProfile removes the request profile of the user's URL
+func (s *Service) DeleteRequestProfile(ctx context.Context, userID string, urlID string) error {
+	ok, err := s.repo.URLBelongsToUser(ctx, userID, urlID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrURLNotFound
+	}
+	return s.repo.DeleteRequestProfile(ctx, urlID)
+}
+
+// loadRequestProfile verifies the URL belongs to the user and returns its decrypted profile,
+// the stored profile is nil and the decrypted profile empty when the URL has none
+func (s *Service) loadRequestProfile(ctx context.Context, userID string, urlID string) (utils.RequestProfile, *StoredRequestProfile, error) {
+	var profile utils.RequestProfile
+	ok, err := s.repo.URLBelongsToUser(ctx, userID, urlID)
+	if err != nil {
+		return profile, nil, err
+	}
+	if !ok {
+		return profile, nil, ErrURLNotFound
+	}
+
+	stored, err := s.repo.GetRequestProfile(ctx, urlID)
+	if err != nil || stored == nil {
+		return profile, nil, err
+	}
+	profile.UserAgent = stored.UserAgent
+	profile.BasicAuthUsername = stored.BasicAuthUsername
+	profile.ApplyToInternalLinks = stored.ApplyToInternalLinks
+	if stored.LoginURL != "" {
+		profile.Login = &utils.LoginRecipe{
+			LoginURL:      stored.LoginURL,
+			UsernameField: stored.LoginUsernameField,
+			PasswordField: stored.LoginPasswordField,
+			Username:      stored.LoginUsername,
+		}
+	}
+	if err := profile.OpenSecrets(utils.DeriveKey(s.config.SecretsKey), stored.EncryptedSecrets); err != nil {
+		return profile, nil, err
+	}
+	return profile, stored, nil
+}
+
+// toRequestProfileResponse describes a request profile by the names of its secrets only
+func toRequestProfileResponse(urlID string, profile utils.RequestProfile, stored *StoredRequestProfile) *RequestProfileResponse {
+	response := &RequestProfileResponse{
+		UrlID:                urlID,
+		UserAgent:            profile.UserAgent,
+		HeaderNames:          []string{},
+		CookieNames:          []string{},
+		BasicAuthUsername:    profile.BasicAuthUsername,
+		HasBasicAuthPassword: profile.BasicAuthPassword != "",
+		ApplyToInternalLinks: profile.ApplyToInternalLinks,
+		ProxyURL:             utils.RedactProxyURL(profile.ProxyURL),
+		UpdatedAt:            stored.UpdatedAt,
+	}
+	for name := range profile.Headers {
+		response.HeaderNames = append(response.HeaderNames, name)
+	}
+	for name := range profile.Cookies {
+		response.CookieNames = append(response.CookieNames, name)
+	}
+	sort.Strings(response.HeaderNames)
+	sort.Strings(response.CookieNames)
+	if profile.Login != nil {
+		response.Login = &LoginRecipeResponse{
+			LoginURL:        profile.Login.LoginURL,
+			UsernameField:   profile.Login.UsernameField,
+			PasswordField:   profile.Login.PasswordField,
+			Username:        profile.Login.Username,
+			HasPassword:     profile.Login.Password != "",
+			ExtraFieldNames: []string{},
+		}
+		for name := range profile.Login.ExtraFields {
+			response.Login.ExtraFieldNames = append(response.Login.ExtraFieldNames, name)
+		}
+		sort.Strings(response.Login.ExtraFieldNames)
+	}
+	return response
+}
+
+// mergeLoginRecipe applies the requested login recipe over the stored one, keeping the stored
+// password and extra fields when they are omitted, a nil request removes the login
+func mergeLoginRecipe(current *utils.LoginRecipe, req *LoginRecipeRequest) *utils.LoginRecipe {
+	if req == nil {
+		return nil
+	}
+	recipe := &utils.LoginRecipe{
+		LoginURL:      req.LoginURL,
+		UsernameField: req.UsernameField,
+		PasswordField: req.PasswordField,
+		Username:      req.Username,
+		ExtraFields:   req.ExtraFields,
+	}
+	if current != nil {
+		recipe.Password = current.Password
+		if req.ExtraFields == nil {
+			recipe.ExtraFields = current.ExtraFields
+		}
+	}
+	if req.Password != nil {
+		recipe.Password = *req.Password
+	}
+	return recipe
+}
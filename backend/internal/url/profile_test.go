@@ -0,0 +1,160 @@
+package url
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"sykell-backend/internal/config"
+	"sykell-backend/internal/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var requestProfileColumns = []string{"url_id", "user_agent", "basic_auth_username", "encrypted_secrets", "apply_to_internal_links",
+	"login_url", "login_username_field", "login_password_field", "login_username", "created_at", "updated_at"}
+
+func TestService_SaveRequestProfile_KeepsOmittedSecrets(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	cfg := &config.Config{SecretsKey: "test-secret"}
+	service := NewService(NewRepo(mockDB), cfg, nil)
+
+	existing := utils.RequestProfile{
+		Headers:           map[string]string{"X-Api-Key": "abc"},
+		BasicAuthPassword: "hunter2",
+	}
+	sealed, err := existing.SealSecrets(utils.DeriveKey(cfg.SecretsKey))
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT (.+) FROM urls").
+		WithArgs("url-1", "user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "normalized_url", "domain", "created_at", "updated_at"}).
+			AddRow("url-1", "user-1", "https://staging.example.com", "staging.example.com", nil, nil))
+	mock.ExpectQuery("SELECT (.+) FROM url_request_profiles").
+		WithArgs("url-1").
+		WillReturnRows(sqlmock.NewRows(requestProfileColumns).
+			AddRow("url-1", nil, "staging", sealed, false, nil, nil, nil, nil, nil, nil))
+	mock.ExpectExec("INSERT INTO url_request_profiles").
+		WithArgs("url-1", "MobileBot/1.0", "staging", sqlmock.AnyArg(), true, nil, nil, nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	profile, err := service.SaveRequestProfile(context.Background(), "user-1", "url-1", RequestProfileRequest{
+		UserAgent:            "MobileBot/1.0",
+		Cookies:              map[string]string{"session": "s3cr3t"},
+		BasicAuthUsername:    "staging",
+		ApplyToInternalLinks: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, []string{"X-Api-Key"}, profile.HeaderNames)
+	assert.Equal(t, []string{"session"}, profile.CookieNames)
+	assert.True(t, profile.HasBasicAuthPassword)
+	assert.True(t, profile.ApplyToInternalLinks)
+}
+
+func TestService_SaveRequestProfile_RejectsInvalidProfile(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	service := NewService(NewRepo(mockDB), &config.Config{SecretsKey: "test-secret"}, nil)
+
+	mock.ExpectQuery("SELECT (.+) FROM urls").
+		WithArgs("url-1", "user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "normalized_url", "domain", "created_at", "updated_at"}).
+			AddRow("url-1", "user-1", "https://example.com", "example.com", nil, nil))
+	mock.ExpectQuery("SELECT (.+) FROM url_request_profiles").
+		WithArgs("url-1").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = service.SaveRequestProfile(context.Background(), "user-1", "url-1", RequestProfileRequest{
+		Headers: map[string]string{"X-Token": "1\r\nX-Injected: 2"},
+	})
+	assert.ErrorIs(t, err, ErrInvalidRequestProfile)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_GetRequestProfile_UnknownURL(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	service := NewService(NewRepo(mockDB), &config.Config{SecretsKey: "test-secret"}, nil)
+
+	mock.ExpectQuery("SELECT (.+) FROM urls").
+		WithArgs("url-1", "user-2").
+		WillReturnError(sql.ErrNoRows)
+
+	_, err = service.GetRequestProfile(context.Background(), "user-2", "url-1")
+	assert.ErrorIs(t, err, ErrURLNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_SaveRequestProfile_KeepsLoginPassword(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer mockDB.Close()
+
+	cfg := &config.Config{SecretsKey: "test-secret"}
+	service := NewService(NewRepo(mockDB), cfg, nil)
+
+	existing := utils.RequestProfile{
+		Login: &utils.LoginRecipe{Password: "hunter2", ExtraFields: map[string]string{"remember": "1"}},
+	}
+	sealed, err := existing.SealSecrets(utils.DeriveKey(cfg.SecretsKey))
+	require.NoError(t, err)
+
+	var saved string
+	mock.ExpectQuery("SELECT (.+) FROM urls").
+		WithArgs("url-1", "user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "normalized_url", "domain", "created_at", "updated_at"}).
+			AddRow("url-1", "user-1", "https://app.example.com/dashboard", "app.example.com", nil, nil))
+	mock.ExpectQuery("SELECT (.+) FROM url_request_profiles").
+		WithArgs("url-1").
+		WillReturnRows(sqlmock.NewRows(requestProfileColumns).
+			AddRow("url-1", nil, nil, sealed, false, "https://app.example.com/login", "email", "password", "old@example.com", nil, nil))
+	mock.ExpectExec("INSERT INTO url_request_profiles").
+		WithArgs("url-1", nil, nil, capturedArg{&saved}, false, "https://app.example.com/signin", "email", "password", "new@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	profile, err := service.SaveRequestProfile(context.Background(), "user-1", "url-1", RequestProfileRequest{
+		Login: &LoginRecipeRequest{
+			LoginURL:      "https://app.example.com/signin",
+			UsernameField: "email",
+			PasswordField: "password",
+			Username:      "new@example.com",
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	require.NotNil(t, profile.Login)
+	assert.Equal(t, "new@example.com", profile.Login.Username)
+	assert.True(t, profile.Login.HasPassword)
+	assert.Equal(t, []string{"remember"}, profile.Login.ExtraFieldNames)
+
+	opened := utils.RequestProfile{Login: &utils.LoginRecipe{}}
+	require.NoError(t, opened.OpenSecrets(utils.DeriveKey(cfg.SecretsKey), saved))
+	assert.Equal(t, "hunter2", opened.Login.Password)
+}
+
+// capturedArg matches any string argument and records its value
+type capturedArg struct {
+	value *string
+}
+
+// Match implements sqlmock.Argument
+func (a capturedArg) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	if ok {
+		*a.value = s
+	}
+	return ok
+}
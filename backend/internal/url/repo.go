@@ -3,16 +3,26 @@ package url
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"strings"
 	"sykell-backend/internal/config"
 	"sykell-backend/internal/db"
+	"sykell-backend/internal/quota"
+
+	"github.com/go-sql-driver/mysql"
 )
 
 // Repo defines the interface for URL repository operations
 type Repo interface {
 	RemoveURL(ctx context.Context, userID string, urlID string) error
-	CreateURL(ctx context.Context, userID string, normalizedURL string, domain string) error
+	CreateURL(ctx context.Context, userID string, normalizedURL string, domain string, quotas *quota.Service) error
 	CountURLsByFilter(ctx context.Context, userID string, query string) (int64, error)
 	GetUrlsWithLatestCrawlsFiltered(ctx context.Context, userID string, limit int32, offset int32, sortBy string, sortOrder string, filter string) ([]CrawlResult, error)
+	GetLatestCrawlFingerprints(ctx context.Context, userID string) ([]PageFingerprint, error)
+	URLBelongsToUser(ctx context.Context, userID string, urlID string) (bool, error)
+	GetRequestProfile(ctx context.Context, urlID string) (*StoredRequestProfile, error)
+	SaveRequestProfile(ctx context.Context, profile StoredRequestProfile) error
+	DeleteRequestProfile(ctx context.Context, urlID string) error
 }
 
 // urlRepo is the concrete implementation of the Repo interface
@@ -39,18 +49,43 @@ func (r *urlRepo) RemoveURL(ctx context.Context, userID string, urlID string) er
 	return err
 }
 
-// CreateURL creates a new URL entry for the specified user
-func (r *urlRepo) CreateURL(ctx context.Context, userID string, normalizedURL string, domain string) error {
+// mysqlDuplicateEntry is the MySQL error number of an insert violating a unique key
+const mysqlDuplicateEntry = 1062
+
+// CreateURL creates a new URL entry for the specified user, the URL quota of the user's plan is checked under a
+// lock of the user so concurrent adds cannot exceed it, it is not enforced when quotas is nil
+func (r *urlRepo) CreateURL(ctx context.Context, userID string, normalizedURL string, domain string, quotas *quota.Service) error {
 	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
 	defer cancel()
-	queries := db.New(r.sqlDB)
-	_, err := queries.CreateUrl(ctx, db.CreateUrlParams{
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	queries := db.New(r.sqlDB).WithTx(tx)
+
+	if quotas != nil {
+		if _, err := queries.LockUserQuota(ctx, userID); err != nil {
+			return err
+		}
+		if err := quotas.WithRepo(quota.NewRepo(tx)).CheckURLs(ctx, userID, 1); err != nil {
+			return err
+		}
+	}
+	_, err = queries.CreateUrl(ctx, db.CreateUrlParams{
 		UserID: 	userID,
 		NormalizedUrl: normalizedURL,
 		Domain: domain,
 	})
-	
-	return err
+	// A URL the user already has is left as is
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // CountURLsByUserID counts the number of URLs for a given user
@@ -88,6 +123,30 @@ func (r *urlRepo) GetUrlsWithLatestCrawlsFiltered(ctx context.Context, userID st
 	return crawlResults, nil
 }
 
+// GetLatestCrawlFingerprints retrieves the title and content fingerprints of the latest completed crawl of each URL of the user
+func (r *urlRepo) GetLatestCrawlFingerprints(ctx context.Context, userID string) ([]PageFingerprint, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	result, err := queries.GetLatestCrawlFingerprintsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	fingerprints := make([]PageFingerprint, len(result))
+	for i, row := range result {
+		fingerprints[i] = PageFingerprint{
+			UrlID:         row.UrlID,
+			NormalizedUrl: row.NormalizedUrl,
+			CrawlID:       row.CrawlID,
+			PageTitle:     row.PageTitle.String,
+			ContentHash:   row.ContentHash.String,
+			HasSimHash:    row.Simhash.Valid,
+			SimHash:       uint64(row.Simhash.Int64),
+		}
+	}
+	return fingerprints, nil
+}
+
 // convertDbRowToCrawlResult converts a database row to a CrawlResult
 func convertDbRowToCrawlResult(row db.GetUrlsWithLatestCrawlsFilteredRow) CrawlResult {
 	result := CrawlResult{
@@ -109,6 +168,15 @@ func convertDbRowToCrawlResult(row db.GetUrlsWithLatestCrawlsFilteredRow) CrawlR
 		statusStr := string(row.Status.CrawlsStatus)
 		result.Status = &statusStr
 	}
+	if row.Outcome.Valid {
+		result.Outcome = &row.Outcome.String
+	}
+	if row.MimeType.Valid {
+		result.MimeType = &row.MimeType.String
+	}
+	if row.ContentLength.Valid {
+		result.ContentLength = &row.ContentLength.Int64
+	}
 	if row.HtmlVersion.Valid {
 		result.HtmlVersion = &row.HtmlVersion.String
 	}
@@ -164,11 +232,99 @@ func convertDbRowToCrawlResult(row db.GetUrlsWithLatestCrawlsFilteredRow) CrawlR
 	if row.InaccessibleLinksCount.Valid {
 		result.InaccessibleLinksCount = &row.InaccessibleLinksCount.Int32
 	}
+	if row.BrokenAnchorsCount.Valid {
+		result.BrokenAnchorsCount = &row.BrokenAnchorsCount.Int32
+	}
 
 	// Convert nullable bool
 	if row.HasLoginForm.Valid {
 		result.HasLoginForm = &row.HasLoginForm.Bool
 	}
 
+	// Convert comma separated form classifications
+	if row.FormTypes.Valid {
+		result.FormTypes = []string{}
+		if row.FormTypes.String != "" {
+			result.FormTypes = strings.Split(row.FormTypes.String, ",")
+		}
+	}
+	if row.FlaggedFormsCount.Valid {
+		result.FlaggedFormsCount = &row.FlaggedFormsCount.Int32
+	}
+
 	return result
-}
\ No newline at end of file
+}
+// URLBelongsToUser reports whether the URL exists and belongs to the specified user
+func (r *urlRepo) URLBelongsToUser(ctx context.Context, userID string, urlID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	_, err := queries.GetUrlByIdAndUserId(ctx, db.GetUrlByIdAndUserIdParams{
+		ID:     urlID,
+		UserID: userID,
+	})
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetRequestProfile retrieves the stored request profile of a URL, or nil when it has none
+func (r *urlRepo) GetRequestProfile(ctx context.Context, urlID string) (*StoredRequestProfile, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	row, err := queries.GetRequestProfileByUrlId(ctx, urlID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	profile := &StoredRequestProfile{
+		UrlID:                row.UrlID,
+		UserAgent:            row.UserAgent.String,
+		BasicAuthUsername:    row.BasicAuthUsername.String,
+		EncryptedSecrets:     row.EncryptedSecrets,
+		ApplyToInternalLinks: row.ApplyToInternalLinks,
+		LoginURL:             row.LoginUrl.String,
+		LoginUsernameField:   row.LoginUsernameField.String,
+		LoginPasswordField:   row.LoginPasswordField.String,
+		LoginUsername:        row.LoginUsername.String,
+	}
+	if row.UpdatedAt.Valid {
+		profile.UpdatedAt = &row.UpdatedAt.Time
+	}
+	return profile, nil
+}
+
+// SaveRequestProfile creates or replaces the request profile of a URL
+func (r *urlRepo) SaveRequestProfile(ctx context.Context, profile StoredRequestProfile) error {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	err := queries.UpsertRequestProfile(ctx, db.UpsertRequestProfileParams{
+		UrlID:                profile.UrlID,
+		UserAgent:            sql.NullString{String: profile.UserAgent, Valid: profile.UserAgent != ""},
+		BasicAuthUsername:    sql.NullString{String: profile.BasicAuthUsername, Valid: profile.BasicAuthUsername != ""},
+		EncryptedSecrets:     profile.EncryptedSecrets,
+		ApplyToInternalLinks: profile.ApplyToInternalLinks,
+		LoginUrl:             sql.NullString{String: profile.LoginURL, Valid: profile.LoginURL != ""},
+		LoginUsernameField:   sql.NullString{String: profile.LoginUsernameField, Valid: profile.LoginUsernameField != ""},
+		LoginPasswordField:   sql.NullString{String: profile.LoginPasswordField, Valid: profile.LoginPasswordField != ""},
+		LoginUsername:        sql.NullString{String: profile.LoginUsername, Valid: profile.LoginUsername != ""},
+	})
+	return err
+}
+
+// DeleteRequestProfile removes the request profile of a URL
+func (r *urlRepo) DeleteRequestProfile(ctx context.Context, urlID string) error {
+	ctx, cancel := context.WithTimeout(ctx, config.DefaultTimeout)
+	defer cancel()
+	queries := db.New(r.sqlDB)
+	err := queries.DeleteRequestProfileByUrlId(ctx, urlID)
+	return err
+}
@@ -2,19 +2,22 @@ package url
 
 import (
 	"sykell-backend/internal/config"
+	"sykell-backend/internal/quota"
 )
 
 // Service provides URL-related services
 type Service struct {
 	repo	Repo
 	config	*config.Config
+	quotas	*quota.Service
 }
 
-// NewService creates a new URL Service
-func NewService(repo Repo, config *config.Config) *Service {
+// NewService creates a new URL Service, quotas are not enforced without a quota service
+func NewService(repo Repo, config *config.Config, quotas *quota.Service) *Service {
 	return &Service{
 		repo:	repo,
 		config:	config,
+		quotas:	quotas,
 	}
 }
 
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// simHashShingleSize is the number of consecutive words hashed together as one SimHash feature
+const simHashShingleSize = 3
+
+// invisibleElements lists elements whose text content is never rendered to the user
+var invisibleElements = map[string]bool{
+	"head":     true,
+	"script":   true,
+	"style":    true,
+	"noscript": true,
+	"template": true,
+	"svg":      true,
+	"iframe":   true,
+}
+
+// ExtractVisibleText returns the text a user would see on the page, skipping scripts, styles and other non-rendered elements
+func ExtractVisibleText(doc *html.Node) string {
+	var text strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && invisibleElements[n.Data] {
+			return
+		}
+		if n.Type == html.TextNode {
+			text.WriteString(n.Data)
+			text.WriteString(" ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return NormalizeText(text.String())
+}
+
+// NormalizeText lowercases the text, strips punctuation and collapses all whitespace into single spaces
+func NormalizeText(text string) string {
+	words := tokenize(text)
+	return strings.Join(words, " ")
+}
+
+// ContentHash returns the hex encoded SHA-256 hash of the normalized text
+func ContentHash(text string) string {
+	sum := sha256.Sum256([]byte(NormalizeText(text)))
+	return hex.EncodeToString(sum[:])
+}
+
+// SimHash computes a 64-bit SimHash fingerprint of the text using word shingles,
+// similar texts produce fingerprints with a small Hamming distance
+func SimHash(text string) uint64 {
+	words := tokenize(text)
+	if len(words) == 0 {
+		return 0
+	}
+
+	var weights [64]int
+	addFeature := func(feature string) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+
+	if len(words) < simHashShingleSize {
+		addFeature(strings.Join(words, " "))
+	} else {
+		for i := 0; i+simHashShingleSize <= len(words); i++ {
+			addFeature(strings.Join(words[i:i+simHashShingleSize], " "))
+		}
+	}
+
+	var fingerprint uint64
+	for i := 0; i < 64; i++ {
+		if weights[i] > 0 {
+			fingerprint |= 1 << uint(i)
+		}
+	}
+	return fingerprint
+}
+
+// HammingDistance returns the number of differing bits between two fingerprints
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// SimHashSimilarity returns the similarity of two SimHash fingerprints between 0 (unrelated) and 1 (identical)
+func SimHashSimilarity(a, b uint64) float64 {
+	return 1 - float64(HammingDistance(a, b))/64
+}
+
+// tokenize splits the text into lowercase words made of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractVisibleText(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{
+			name:     "Body text only",
+			html:     `<html><head><title>Title</title></head><body><p>Hello,   World!</p></body></html>`,
+			expected: "hello world",
+		},
+		{
+			name: "Scripts and styles are skipped",
+			html: `<html><body>
+				<script>var x = "hidden";</script>
+				<style>.a { color: red; }</style>
+				<noscript>Enable JavaScript</noscript>
+				<h1>Visible</h1><p>Text</p>
+			</body></html>`,
+			expected: "visible text",
+		},
+		{
+			name:     "Empty body",
+			html:     `<html><body></body></html>`,
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := parseHTML(tt.html)
+			result := ExtractVisibleText(doc)
+			if result != tt.expected {
+				t.Errorf("ExtractVisibleText() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	a := ContentHash("Hello   World")
+	b := ContentHash("hello world!")
+	c := ContentHash("Goodbye world")
+
+	if len(a) != 64 {
+		t.Errorf("ContentHash() length = %d, want 64", len(a))
+	}
+	if a != b {
+		t.Errorf("ContentHash() should ignore case, punctuation and whitespace: %q != %q", a, b)
+	}
+	if a == c {
+		t.Errorf("ContentHash() should differ for different text")
+	}
+}
+
+func TestSimHash(t *testing.T) {
+	base := strings.Repeat("the quick brown fox jumps over the lazy dog near the river bank ", 20)
+	nearDuplicate := base + "with one extra sentence at the very end"
+	different := strings.Repeat("completely unrelated content about cooking pasta with fresh tomatoes and basil ", 20)
+
+	tests := []struct {
+		name          string
+		a             string
+		b             string
+		minSimilarity float64
+		maxSimilarity float64
+	}{
+		{
+			name:          "Identical text",
+			a:             base,
+			b:             base,
+			minSimilarity: 1,
+			maxSimilarity: 1,
+		},
+		{
+			name:          "Near duplicate text",
+			a:             base,
+			b:             nearDuplicate,
+			minSimilarity: 0.85,
+			maxSimilarity: 1,
+		},
+		{
+			name:          "Different text",
+			a:             base,
+			b:             different,
+			minSimilarity: 0,
+			maxSimilarity: 0.8,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			similarity := SimHashSimilarity(SimHash(tt.a), SimHash(tt.b))
+			if similarity < tt.minSimilarity || similarity > tt.maxSimilarity {
+				t.Errorf("SimHashSimilarity() = %v, want between %v and %v", similarity, tt.minSimilarity, tt.maxSimilarity)
+			}
+		})
+	}
+}
+
+func TestHammingDistance(t *testing.T) {
+	tests := []struct {
+		a, b     uint64
+		expected int
+	}{
+		{0, 0, 0},
+		{0, 1, 1},
+		{0xFF, 0x0F, 4},
+		{0, ^uint64(0), 64},
+	}
+
+	for _, tt := range tests {
+		if result := HammingDistance(tt.a, tt.b); result != tt.expected {
+			t.Errorf("HammingDistance(%x, %x) = %d, want %d", tt.a, tt.b, result, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Form classifications
+const (
+	FormTypeLogin         = "login"
+	FormTypeSignup        = "signup"
+	FormTypePasswordReset = "password_reset"
+	FormTypeSearch        = "search"
+	FormTypeNewsletter    = "newsletter"
+	FormTypeContact       = "contact"
+	FormTypePayment       = "payment"
+	FormTypeOther         = "other"
+)
+
+// FormField represents a single input of a form
+type FormField struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	Autocomplete string `json:"autocomplete,omitempty"`
+}
+
+// FormInfo represents detailed information about a single form
+type FormInfo struct {
+	Method     string      `json:"method"`      // Upper-cased HTTP method, GET when missing
+	Action     string      `json:"action"`      // Resolved absolute action URL
+	Fields     []FormField `json:"fields"`      // Inputs, selects, textareas and buttons of the form
+	Type       string      `json:"type"`        // Classification of the form
+	IsHTTPS    bool        `json:"is_https"`    // Whether the form submits over https
+	IsOffSite  bool        `json:"is_off_site"` // Whether the form submits to another host
+	IsInsecure bool        `json:"is_insecure"` // Whether the form posts or sends a password over plain http
+}
+
+// IsFlagged reports whether the form submits somewhere it should not
+func (f FormInfo) IsFlagged() bool {
+	return f.IsInsecure || f.IsOffSite
+}
+
+// Field name patterns used by the form classification heuristics
+var (
+	paymentPattern       = regexp.MustCompile(`card|cc-?(num|number|exp|csc)|cvv|cvc|expir|iban|billing`)
+	signupPattern        = regexp.MustCompile(`sign-?up|register|registration|create.?account|confirm|repeat|retype`)
+	passwordResetPattern = regexp.MustCompile(`forgot|reset|recover|lost.?password`)
+	searchPattern        = regexp.MustCompile(`search|^q$|^query$|^s$|^keywords?$`)
+	newsletterPattern    = regexp.MustCompile(`newsletter|subscribe|subscription|mailing`)
+	contactPattern       = regexp.MustCompile(`contact|message|enquiry|inquiry|feedback|comment|subject`)
+)
+
+// ExtractForms records every form of the document with its method, action, fields and classification
+func ExtractForms(doc *html.Node, baseURL string) []FormInfo {
+	forms := []FormInfo{}
+	baseU, err := url.Parse(baseURL)
+	if err != nil {
+		return forms
+	}
+
+	var findForms func(*html.Node)
+	findForms = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "form" {
+			forms = append(forms, parseForm(n, baseU))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			findForms(c)
+		}
+	}
+	findForms(doc)
+	return forms
+}
+
+// FormTypes returns the sorted distinct classifications of the given forms
+func FormTypes(forms []FormInfo) []string {
+	seen := make(map[string]bool)
+	types := []string{}
+	for _, form := range forms {
+		if !seen[form.Type] {
+			seen[form.Type] = true
+			types = append(types, form.Type)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// parseForm extracts the details of a single form element
+func parseForm(n *html.Node, baseU *url.URL) FormInfo {
+	form := FormInfo{
+		Method: strings.ToUpper(strings.TrimSpace(getAttr(n, "method"))),
+		Fields: []FormField{},
+	}
+	if form.Method == "" {
+		form.Method = "GET"
+	}
+
+	// A missing or empty action submits to the page itself
+	actionURL := baseU
+	if action := strings.TrimSpace(getAttr(n, "action")); action != "" {
+		if parsed, err := url.Parse(action); err == nil {
+			actionURL = baseU.ResolveReference(parsed)
+		}
+	}
+	form.Action = actionURL.String()
+	form.IsHTTPS = actionURL.Scheme == "https"
+	form.IsOffSite = actionURL.Host != "" && !strings.EqualFold(actionURL.Host, baseU.Host)
+
+	// Collect every textual hint about the purpose of the form
+	var signals []string
+	signals = append(signals, getAttr(n, "id"), getAttr(n, "class"), getAttr(n, "name"), getAttr(n, "role"), getAttr(n, "action"))
+
+	var collect func(*html.Node)
+	collect = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			switch node.Data {
+			case "input", "select", "textarea", "button":
+				fieldType := strings.ToLower(getAttr(node, "type"))
+				switch {
+				case node.Data == "select" || node.Data == "textarea":
+					fieldType = node.Data
+				case node.Data == "button" && fieldType == "":
+					fieldType = "submit"
+				case fieldType == "":
+					fieldType = "text"
+				}
+				field := FormField{
+					Name:         getAttr(node, "name"),
+					Type:         fieldType,
+					Autocomplete: strings.ToLower(getAttr(node, "autocomplete")),
+				}
+				form.Fields = append(form.Fields, field)
+				signals = append(signals, field.Name, getAttr(node, "id"), getAttr(node, "placeholder"), getAttr(node, "value"), field.Autocomplete)
+				if node.Data == "button" {
+					signals = append(signals, extractTextContent(node))
+				}
+			}
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+
+	form.Type = classifyForm(form.Fields, strings.ToLower(strings.Join(signals, " ")))
+
+	hasPassword := false
+	for _, field := range form.Fields {
+		if field.Type == "password" {
+			hasPassword = true
+		}
+	}
+	form.IsInsecure = actionURL.Scheme == "http" && (form.Method == "POST" || hasPassword)
+
+	return form
+}
+
+// classifyForm assigns a type to a form from its field types and textual signals
+func classifyForm(fields []FormField, signals string) string {
+	var passwordCount, emailCount, textareaCount, inputCount int
+	hasSearchInput := false
+	hasNewPassword := false
+	names := make([]string, 0, len(fields))
+
+	for _, field := range fields {
+		name := strings.ToLower(field.Name)
+		names = append(names, name)
+		switch field.Type {
+		case "password":
+			passwordCount++
+			if field.Autocomplete == "new-password" {
+				hasNewPassword = true
+			}
+		case "email":
+			emailCount++
+		case "textarea":
+			textareaCount++
+		case "search":
+			hasSearchInput = true
+		}
+		if strings.HasPrefix(field.Autocomplete, "cc-") || paymentPattern.MatchString(name) {
+			return FormTypePayment
+		}
+		if name == "email" || strings.Contains(name, "e-mail") {
+			if field.Type != "email" {
+				emailCount++
+			}
+		}
+		switch field.Type {
+		case "submit", "button", "reset", "hidden", "image":
+		default:
+			inputCount++
+		}
+	}
+
+	// Forms with password inputs are login, signup or password reset forms
+	if passwordCount > 0 {
+		switch {
+		case passwordCount >= 2 || hasNewPassword || signupPattern.MatchString(signals):
+			if passwordResetPattern.MatchString(signals) {
+				return FormTypePasswordReset
+			}
+			return FormTypeSignup
+		default:
+			return FormTypeLogin
+		}
+	}
+
+	if passwordResetPattern.MatchString(signals) && emailCount > 0 {
+		return FormTypePasswordReset
+	}
+
+	if hasSearchInput || strings.Contains(signals, "search") {
+		return FormTypeSearch
+	}
+	for _, name := range names {
+		if searchPattern.MatchString(name) {
+			return FormTypeSearch
+		}
+	}
+
+	if textareaCount > 0 || contactPattern.MatchString(signals) {
+		return FormTypeContact
+	}
+
+	if emailCount > 0 && (newsletterPattern.MatchString(signals) || inputCount <= 2) {
+		return FormTypeNewsletter
+	}
+
+	if signupPattern.MatchString(signals) && emailCount > 0 {
+		return FormTypeSignup
+	}
+
+	return FormTypeOther
+}
+
+// getAttr returns the value of the named attribute of the node, or an empty string
+func getAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractFormsClassification(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{
+			name: "Login form",
+			html: `<form method="post" action="/login">
+				<input type="email" name="email">
+				<input type="password" name="password">
+				<button type="submit">Log in</button>
+			</form>`,
+			expected: FormTypeLogin,
+		},
+		{
+			name: "Signup form with password confirmation",
+			html: `<form method="post" action="/account">
+				<input type="email" name="email">
+				<input type="password" name="password">
+				<input type="password" name="password_confirmation">
+				<button>Create account</button>
+			</form>`,
+			expected: FormTypeSignup,
+		},
+		{
+			name: "Signup form with new-password autocomplete",
+			html: `<form method="post">
+				<input type="text" name="username">
+				<input type="password" name="pw" autocomplete="new-password">
+			</form>`,
+			expected: FormTypeSignup,
+		},
+		{
+			name: "Password reset form",
+			html: `<form method="post" action="/forgot-password">
+				<input type="email" name="email">
+				<button>Send reset link</button>
+			</form>`,
+			expected: FormTypePasswordReset,
+		},
+		{
+			name: "Search form",
+			html: `<form action="/search">
+				<input type="text" name="q">
+				<button>Go</button>
+			</form>`,
+			expected: FormTypeSearch,
+		},
+		{
+			name: "Newsletter form",
+			html: `<form method="post" action="/newsletter">
+				<input type="email" name="email" placeholder="Your email">
+				<button>Subscribe</button>
+			</form>`,
+			expected: FormTypeNewsletter,
+		},
+		{
+			name: "Contact form",
+			html: `<form method="post" action="/contact">
+				<input type="text" name="name">
+				<input type="email" name="email">
+				<textarea name="message"></textarea>
+				<button>Send</button>
+			</form>`,
+			expected: FormTypeContact,
+		},
+		{
+			name: "Payment form",
+			html: `<form method="post" action="/checkout">
+				<input type="text" name="name">
+				<input type="text" name="cardnumber" autocomplete="cc-number">
+				<input type="text" name="cvc">
+			</form>`,
+			expected: FormTypePayment,
+		},
+		{
+			name: "Unclassified form",
+			html: `<form method="post">
+				<select name="language"><option>en</option></select>
+				<input type="text" name="nickname">
+				<input type="text" name="city">
+			</form>`,
+			expected: FormTypeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := parseHTML("<html><body>" + tt.html + "</body></html>")
+			forms := ExtractForms(doc, "https://mysite.com/page")
+			if len(forms) != 1 {
+				t.Fatalf("ExtractForms() found %d forms, want 1", len(forms))
+			}
+			if forms[0].Type != tt.expected {
+				t.Errorf("ExtractForms() type = %q, want %q", forms[0].Type, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExtractFormsDetails(t *testing.T) {
+	doc := parseHTML(`<html><body>
+		<form method="post" action="/login">
+			<input type="text" name="user">
+			<input type="password" name="pass">
+			<button>Sign in</button>
+		</form>
+		<form method="post" action="http://mysite.com/subscribe">
+			<input type="email" name="email">
+		</form>
+		<form action="https://other.com/search">
+			<input type="search" name="q">
+		</form>
+	</body></html>`)
+
+	forms := ExtractForms(doc, "https://mysite.com/page")
+	if len(forms) != 3 {
+		t.Fatalf("ExtractForms() found %d forms, want 3", len(forms))
+	}
+
+	login := forms[0]
+	if login.Method != "POST" || login.Action != "https://mysite.com/login" {
+		t.Errorf("login form method/action = %s %s, want POST https://mysite.com/login", login.Method, login.Action)
+	}
+	expectedFields := []FormField{
+		{Name: "user", Type: "text"},
+		{Name: "pass", Type: "password"},
+		{Name: "", Type: "submit"},
+	}
+	if !reflect.DeepEqual(login.Fields, expectedFields) {
+		t.Errorf("login form fields = %+v, want %+v", login.Fields, expectedFields)
+	}
+	if !login.IsHTTPS || login.IsFlagged() {
+		t.Errorf("login form should be https and not flagged: %+v", login)
+	}
+
+	insecure := forms[1]
+	if insecure.IsHTTPS || !insecure.IsInsecure || !insecure.IsFlagged() {
+		t.Errorf("form posting to http should be flagged as insecure: %+v", insecure)
+	}
+
+	offSite := forms[2]
+	if offSite.Method != "GET" || !offSite.IsOffSite || !offSite.IsFlagged() {
+		t.Errorf("form submitting to another host should be flagged as off-site: %+v", offSite)
+	}
+
+	types := FormTypes(forms)
+	expectedTypes := []string{FormTypeLogin, FormTypeNewsletter, FormTypeSearch}
+	if !reflect.DeepEqual(types, expectedTypes) {
+		t.Errorf("FormTypes() = %v, want %v", types, expectedTypes)
+	}
+}
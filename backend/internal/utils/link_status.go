@@ -0,0 +1,283 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"sykell-backend/internal/ratelimit"
+
+	"golang.org/x/net/html"
+)
+
+// Reasons explaining the status of a checked link
+const (
+	LinkReasonOK                = "ok"
+	LinkReasonRedirected        = "redirected"
+	LinkReasonHTTPError         = "http_error"
+	LinkReasonSoftNotFound      = "soft_404"
+	LinkReasonTooManyRedirects  = "too_many_redirects"
+	LinkReasonTimeout           = "timeout"
+	LinkReasonDNSError          = "dns_error"
+	LinkReasonConnectionRefused = "connection_refused"
+	LinkReasonTLSError          = "tls_error"
+	LinkReasonBlocked           = "blocked"
+	LinkReasonRateLimited       = "rate_limited"
+	LinkReasonNetworkError      = "network_error"
+	LinkReasonInvalidURL        = "invalid_url"
+)
+
+// softNotFoundSniffSize is how much of a page the ranged GET reads to detect soft 404s
+const softNotFoundSniffSize = 32 << 10
+
+// softNotFoundMaxText is the most visible text a page may have to be a soft 404 when only its title or only its
+// first heading announces a missing page, articles about missing pages are longer
+const softNotFoundMaxText = 1024
+
+// softNotFoundPhrases are matched against the title and first heading of a page answered with 2xx
+var softNotFoundPhrases = []string{
+	"not found",
+	"page doesn't exist",
+	"page does not exist",
+	"page cannot be found",
+	"page can't be found",
+	"no longer exists",
+	"no longer available",
+}
+
+// LinkStatus is the result of checking a link
+type LinkStatus struct {
+	StatusCode  *int   `json:"status_code"`            // Final HTTP status code, nil if the link could not be reached
+	Reason      string `json:"reason"`                 // One of the LinkReason constants
+	RedirectURL string `json:"redirect_url,omitempty"` // Where the redirects ended, if the link was redirected
+	// RetryAfter is when the host of a rate limited link has a free slot again, rate limited links are not broken
+	RetryAfter time.Duration `json:"-"`
+}
+
+// Accessible reports whether the link leads to a working page
+func (s LinkStatus) Accessible() bool {
+	return s.Reason == LinkReasonOK || s.Reason == LinkReasonRedirected
+}
+
+// Status returns the checked status of the link
+func (l LinkInfo) Status() LinkStatus {
+	return LinkStatus{StatusCode: l.StatusCode, Reason: l.Reason, RedirectURL: l.RedirectURL}
+}
+
+// CheckLink checks a single link without the cache, e.g. to confirm that a link found inaccessible is still broken
+func (c *LinkChecker) CheckLink(absoluteURL string, internal bool) LinkStatus {
+	status, _ := c.probeURL(absoluteURL, internal)
+	return status
+}
+
+// probeURL checks a URL with HEAD and, when the server rejects HEAD, the request fails or an internal page is
+// HTML, with a ranged GET that also reveals soft 404s, returning the error of the last attempt
+func (c *LinkChecker) probeURL(urlStr string, internal bool) (LinkStatus, error) {
+	client := c.statusClient()
+
+	req, err := c.newRequest("HEAD", urlStr, internal)
+	if err != nil {
+		return LinkStatus{Reason: LinkReasonInvalidURL}, err
+	}
+
+	// Try HEAD request first (faster)
+	resp, err := client.Do(req)
+	if isRateLimited(err) {
+		return failedStatus(err), err
+	}
+	if err == nil {
+		resp.Body.Close()
+		if !needsRangedGet(resp, internal) {
+			return linkStatusOf(urlStr, resp, nil), nil
+		}
+	}
+
+	getReq, err := c.newRequest("GET", urlStr, internal)
+	if err != nil {
+		return LinkStatus{Reason: LinkReasonInvalidURL}, err
+	}
+	getReq.Header.Set("Range", fmt.Sprintf("bytes=0-%d", softNotFoundSniffSize-1))
+	resp, err = client.Do(getReq)
+	if err != nil {
+		return failedStatus(err), err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, softNotFoundSniffSize))
+	return linkStatusOf(urlStr, resp, body), nil
+}
+
+// needsRangedGet reports whether a HEAD response has to be confirmed with a GET: the server does not
+// allow or implement HEAD, or an internal page is HTML and may be a soft 404. External pages are trusted
+// on their HEAD status so every external link costs a single request
+func needsRangedGet(resp *http.Response, internal bool) bool {
+	switch resp.StatusCode {
+	case http.StatusMethodNotAllowed, http.StatusForbidden, http.StatusNotImplemented:
+		return true
+	}
+	return internal && resp.StatusCode < 300 && isHTMLResponse(resp)
+}
+
+// linkStatusOf builds the status of a link from the final response, the body is only given for GET requests
+func linkStatusOf(urlStr string, resp *http.Response, body []byte) LinkStatus {
+	code := resp.StatusCode
+	// The ranged GET only asked for the start of the page, an empty page cannot satisfy any range
+	if code == http.StatusPartialContent || code == http.StatusRequestedRangeNotSatisfiable {
+		code = http.StatusOK
+	}
+	status := LinkStatus{StatusCode: &code, Reason: LinkReasonOK}
+
+	if resp.Request != nil && resp.Request.URL != nil {
+		if final := withoutFragment(resp.Request.URL); final != strings.SplitN(urlStr, "#", 2)[0] {
+			status.RedirectURL = final
+		}
+	}
+
+	switch {
+	case code >= 400:
+		status.Reason = LinkReasonHTTPError
+	case code >= 300 && resp.Header.Get("Location") != "":
+		// The redirect limit was reached before the final page
+		status.Reason = LinkReasonTooManyRedirects
+	case body != nil && isHTMLResponse(resp) && isSoftNotFound(body):
+		status.Reason = LinkReasonSoftNotFound
+	case status.RedirectURL != "":
+		status.Reason = LinkReasonRedirected
+	}
+	return status
+}
+
+// isHTMLResponse reports whether the response declares an HTML body
+func isHTMLResponse(resp *http.Response) bool {
+	return strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "html")
+}
+
+// isSoftNotFound reports whether the start of an HTML page announces a missing page, both its title and first
+// heading have to announce it, or one of them on a page with little text
+func isSoftNotFound(body []byte) bool {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	titleMatches := announcesNotFound(ExtractTitle(doc))
+	headingMatches := announcesNotFound(firstHeading(doc))
+	if titleMatches && headingMatches {
+		return true
+	}
+	return (titleMatches || headingMatches) && visibleTextLength(doc) <= softNotFoundMaxText
+}
+
+// announcesNotFound reports whether a title or heading says the page is missing
+func announcesNotFound(text string) bool {
+	text = strings.ToLower(text)
+	for _, phrase := range softNotFoundPhrases {
+		if strings.Contains(text, phrase) {
+			return true
+		}
+	}
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool { return r < '0' || r > '9' }) {
+		if word == "404" {
+			return true
+		}
+	}
+	return false
+}
+
+// visibleTextLength returns the length of the text in the body of the document, without scripts and styles
+func visibleTextLength(doc *html.Node) int {
+	length := 0
+	var count func(*html.Node)
+	count = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "head" || n.Data == "script" || n.Data == "style" || n.Data == "noscript") {
+			return
+		}
+		if n.Type == html.TextNode {
+			length += len(strings.TrimSpace(n.Data))
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			count(c)
+		}
+	}
+	count(doc)
+	return length
+}
+
+// firstHeading returns the text of the first h1 of the document
+func firstHeading(doc *html.Node) string {
+	var heading string
+	var find func(*html.Node) bool
+	find = func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.Data == "h1" {
+			heading = extractTextContent(n)
+			return true
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if find(c) {
+				return true
+			}
+		}
+		return false
+	}
+	find(doc)
+	return heading
+}
+
+// failedStatus returns the status of a link check that got no response, with the wait of our own rate limit
+func failedStatus(err error) LinkStatus {
+	status := LinkStatus{Reason: failureReason(err)}
+	var rateLimitErr *ratelimit.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		status.RetryAfter = rateLimitErr.Wait
+	}
+	return status
+}
+
+// isRateLimited reports whether the request was not sent because its host had no free slot soon enough
+func isRateLimited(err error) bool {
+	var rateLimitErr *ratelimit.RateLimitError
+	return errors.As(err, &rateLimitErr)
+}
+
+// failureReason returns the reason code of a link check that got no response
+func failureReason(err error) string {
+	var rateLimitErr *ratelimit.RateLimitError
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &rateLimitErr):
+		return LinkReasonRateLimited
+	case errors.Is(err, ErrBlockedAddress):
+		return LinkReasonBlocked
+	case errors.As(err, &dnsErr):
+		return LinkReasonDNSError
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return LinkReasonConnectionRefused
+	case errors.As(err, &certErr):
+		return LinkReasonTLSError
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return LinkReasonTimeout
+	}
+	return LinkReasonNetworkError
+}
+
+// isPermanentFailure reports whether a failed link check is unlikely to succeed when retried soon:
+// unknown hosts, refused connections, invalid certificates and blocked addresses
+func isPermanentFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsNotFound
+	}
+	var certErr *tls.CertificateVerificationError
+	return errors.Is(err, ErrBlockedAddress) || errors.Is(err, syscall.ECONNREFUSED) || errors.As(err, &certErr)
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProbeURL(t *testing.T) {
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/no-head":
+			if r.Method == "HEAD" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			ranges = append(ranges, r.Header.Get("Range"))
+			w.WriteHeader(http.StatusPartialContent)
+		case "/soft-404":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, "<html><head><title>Oops!</title></head><body><h1>Sorry, this page does not exist</h1></body></html>")
+		case "/404-guide":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprintf(w, "<html><head><title>How to fix a 404 error</title></head><body><h1>Fixing broken links</h1><p>%s</p></body></html>", strings.Repeat("Check your redirects. ", 100))
+		case "/article":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, "<html><head><title>Top 10 tips for 2024</title></head><body><h1>Tips</h1></body></html>")
+		case "/moved":
+			http.Redirect(w, r, "/article", http.StatusMovedPermanently)
+		case "/loop":
+			hop := 0
+			fmt.Sscan(r.URL.Query().Get("hop"), &hop)
+			http.Redirect(w, r, fmt.Sprintf("/loop?hop=%d", hop+1), http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path         string
+		internal     bool
+		wantCode     int
+		wantReason   string
+		wantRedirect string
+	}{
+		{"/no-head", false, http.StatusOK, LinkReasonOK, ""},
+		{"/soft-404", true, http.StatusOK, LinkReasonSoftNotFound, ""},
+		{"/404-guide", true, http.StatusOK, LinkReasonOK, ""},
+		{"/article", true, http.StatusOK, LinkReasonOK, ""},
+		{"/moved", false, http.StatusOK, LinkReasonRedirected, server.URL + "/article"},
+		{"/loop", false, http.StatusFound, LinkReasonTooManyRedirects, server.URL + "/loop?hop=4"},
+		{"/missing", false, http.StatusNotFound, LinkReasonHTTPError, ""},
+	}
+
+	checker := &LinkChecker{}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			status, err := checker.probeURL(server.URL+tt.path, tt.internal)
+			if err != nil {
+				t.Fatalf("probeURL() error = %v", err)
+			}
+			if status.StatusCode == nil || *status.StatusCode != tt.wantCode {
+				t.Errorf("probeURL() status code = %v, want %d", status.StatusCode, tt.wantCode)
+			}
+			if status.Reason != tt.wantReason {
+				t.Errorf("probeURL() reason = %q, want %q", status.Reason, tt.wantReason)
+			}
+			if status.RedirectURL != tt.wantRedirect {
+				t.Errorf("probeURL() redirect URL = %q, want %q", status.RedirectURL, tt.wantRedirect)
+			}
+			if status.Accessible() != (tt.wantReason == LinkReasonOK || tt.wantReason == LinkReasonRedirected) {
+				t.Errorf("probeURL() accessible = %v for reason %q", status.Accessible(), status.Reason)
+			}
+		})
+	}
+
+	if len(ranges) != 1 || !strings.HasPrefix(ranges[0], "bytes=0-") {
+		t.Errorf("GET fallback ranges = %v, want a single ranged request", ranges)
+	}
+
+	// External HTML pages are trusted on their HEAD status, soft 404s are only looked for on internal pages
+	status, err := checker.probeURL(server.URL+"/soft-404", false)
+	if err != nil {
+		t.Fatalf("probeURL() error = %v", err)
+	}
+	if status.Reason != LinkReasonOK {
+		t.Errorf("probeURL() reason of an external page = %q, want %q", status.Reason, LinkReasonOK)
+	}
+}
+
+func TestProbeURLUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	serverURL := server.URL
+	server.Close()
+
+	status, err := (&LinkChecker{}).probeURL(serverURL+"/page", false)
+	if err == nil {
+		t.Fatal("probeURL() error = nil, want a connection error")
+	}
+	if status.StatusCode != nil || status.Reason != LinkReasonConnectionRefused {
+		t.Errorf("probeURL() = %+v, want no status code and reason %q", status, LinkReasonConnectionRefused)
+	}
+}
@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Login recipe limits
+const (
+	maxLoginPageSize    = 5 << 20 // How much of the login page and the login response is read
+	maxLoginURL         = 2083
+	maxLoginFieldName   = 255
+	maxLoginExtraFields = 20
+)
+
+// ErrLoginFailed is returned when the login form was submitted but the site did not accept the credentials
+var ErrLoginFailed = errors.New("login failed")
+
+// LoginRecipe describes how to sign in to a site through its login form before crawling
+type LoginRecipe struct {
+	LoginURL      string            `json:"login_url"`      // Page that contains the login form
+	UsernameField string            `json:"username_field"` // Name of the username or email input
+	PasswordField string            `json:"password_field"` // Name of the password input
+	Username      string            `json:"username"`
+	Password      string            `json:"password"`
+	ExtraFields   map[string]string `json:"extra_fields"` // Additional values submitted with the form
+}
+
+// Validate checks that the recipe can be used to submit a login form
+func (r *LoginRecipe) Validate() error {
+	u, err := url.Parse(r.LoginURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("login URL must be an absolute http or https URL")
+	}
+	if len(r.LoginURL) > maxLoginURL {
+		return fmt.Errorf("login URL must be at most %d characters", maxLoginURL)
+	}
+	if strings.TrimSpace(r.UsernameField) == "" || strings.TrimSpace(r.PasswordField) == "" {
+		return fmt.Errorf("login username and password field names are required")
+	}
+	if len(r.UsernameField) > maxLoginFieldName || len(r.PasswordField) > maxLoginFieldName {
+		return fmt.Errorf("login field names must be at most %d characters", maxLoginFieldName)
+	}
+	if r.Username == "" || len(r.Username) > maxProfileUsername {
+		return fmt.Errorf("invalid login username")
+	}
+	if len(r.ExtraFields) > maxLoginExtraFields {
+		return fmt.Errorf("at most %d extra login fields are allowed", maxLoginExtraFields)
+	}
+	for name := range r.ExtraFields {
+		if strings.TrimSpace(name) == "" || len(name) > maxLoginFieldName {
+			return fmt.Errorf("invalid extra login field %q", name)
+		}
+	}
+	return nil
+}
+
+// PerformFormLogin loads the login page, fills in the login form, keeping hidden fields such as CSRF tokens,
+// and submits it, the session cookies end up in the client's cookie jar
+func PerformFormLogin(ctx context.Context, client *http.Client, recipe *LoginRecipe, prepare func(req *http.Request)) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", recipe.LoginURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create login page request: %w", err)
+	}
+	req.Header.Set("User-Agent", DefaultUserAgent)
+	if prepare != nil {
+		prepare(req)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch login page: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("login page returned HTTP %d", resp.StatusCode)
+	}
+
+	doc, err := html.Parse(io.LimitReader(resp.Body, maxLoginPageSize))
+	if err != nil {
+		return fmt.Errorf("failed to parse login page: %w", err)
+	}
+	form := findFormWithField(doc, recipe.PasswordField)
+	if form == nil {
+		return fmt.Errorf("no form with a %q field found on the login page", recipe.PasswordField)
+	}
+
+	values := formValues(form)
+	values.Set(recipe.UsernameField, recipe.Username)
+	values.Set(recipe.PasswordField, recipe.Password)
+	for name, value := range recipe.ExtraFields {
+		values.Set(name, value)
+	}
+
+	// The action is resolved against the final login page URL, after redirects
+	pageURL := resp.Request.URL
+	actionURL := pageURL
+	if action := strings.TrimSpace(getAttr(form, "action")); action != "" {
+		parsed, err := url.Parse(action)
+		if err != nil {
+			return fmt.Errorf("invalid login form action: %w", err)
+		}
+		actionURL = pageURL.ResolveReference(parsed)
+	}
+
+	var submit *http.Request
+	if strings.EqualFold(strings.TrimSpace(getAttr(form, "method")), "get") {
+		target := *actionURL
+		target.RawQuery = values.Encode()
+		submit, err = http.NewRequestWithContext(ctx, "GET", target.String(), nil)
+	} else {
+		submit, err = http.NewRequestWithContext(ctx, "POST", actionURL.String(), strings.NewReader(values.Encode()))
+		if err == nil {
+			submit.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create login request: %w", err)
+	}
+	submit.Header.Set("User-Agent", DefaultUserAgent)
+	submit.Header.Set("Referer", pageURL.String())
+	if prepare != nil {
+		prepare(submit)
+	}
+
+	result, err := client.Do(submit)
+	if err != nil {
+		return fmt.Errorf("failed to submit login form: %w", err)
+	}
+	defer result.Body.Close()
+	if result.StatusCode >= 400 {
+		return fmt.Errorf("%w: login form submission returned HTTP %d", ErrLoginFailed, result.StatusCode)
+	}
+
+	// Landing on a page that asks for the password again means the credentials were rejected
+	resultDoc, err := html.Parse(io.LimitReader(result.Body, maxLoginPageSize))
+	if err == nil && findFormWithField(resultDoc, recipe.PasswordField) != nil {
+		return fmt.Errorf("%w: the login form was shown again after submitting the credentials", ErrLoginFailed)
+	}
+	return nil
+}
+
+// findFormWithField returns the first form of the document that contains a field with the given name
+func findFormWithField(doc *html.Node, fieldName string) *html.Node {
+	var found *html.Node
+	var findForm func(*html.Node)
+	findForm = func(n *html.Node) {
+		if found != nil {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "form" && hasField(n, fieldName) {
+			found = n
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			findForm(c)
+		}
+	}
+	findForm(doc)
+	return found
+}
+
+// hasField reports whether the form contains an input, select or textarea with the given name
+func hasField(form *html.Node, fieldName string) bool {
+	var has func(*html.Node) bool
+	has = func(n *html.Node) bool {
+		if n.Type == html.ElementNode && (n.Data == "input" || n.Data == "select" || n.Data == "textarea") && getAttr(n, "name") == fieldName {
+			return true
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if has(c) {
+				return true
+			}
+		}
+		return false
+	}
+	return has(form)
+}
+
+// formValues collects the values a browser would submit for the form without user input,
+// such as hidden CSRF tokens, prefilled inputs and checked checkboxes
+func formValues(form *html.Node) url.Values {
+	values := url.Values{}
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			name := getAttr(n, "name")
+			switch {
+			case name == "":
+			case n.Data == "input":
+				switch strings.ToLower(getAttr(n, "type")) {
+				case "submit", "button", "reset", "image", "file":
+				case "checkbox", "radio":
+					if hasAttr(n, "checked") {
+						value := getAttr(n, "value")
+						if value == "" {
+							value = "on"
+						}
+						values.Add(name, value)
+					}
+				default:
+					values.Add(name, getAttr(n, "value"))
+				}
+			case n.Data == "textarea":
+				values.Add(name, extractTextContent(n))
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(form)
+	return values
+}
+
+// hasAttr reports whether the node has the named attribute, regardless of its value
+func hasAttr(n *html.Node, key string) bool {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"testing"
+)
+
+// newLoginServer serves a login form protected by a CSRF token and a page that requires the session cookie
+func newLoginServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body>
+				<form action="/search"><input name="q"></form>
+				<form method="post" action="/session">
+					<input type="hidden" name="csrf" value="token-123">
+					<input type="email" name="email">
+					<input type="password" name="password">
+					<input type="checkbox" name="remember" value="yes" checked>
+					<button type="submit" name="go">Sign in</button>
+				</form>
+			</body></html>`))
+			return
+		}
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	})
+	mux.HandleFunc("/session", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("csrf") != "token-123" || r.PostForm.Get("remember") != "yes" || r.PostForm.Has("go") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.PostForm.Get("email") != "user@example.com" || r.PostForm.Get("password") != "hunter2" {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+	})
+	mux.HandleFunc("/dashboard", func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("session"); err != nil || cookie.Value != "abc" {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		w.Write([]byte(`<html><body><h1>Dashboard</h1></body></html>`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestPerformFormLogin(t *testing.T) {
+	server := newLoginServer(t)
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  error
+	}{
+		{"Valid credentials", "hunter2", nil},
+		{"Wrong password", "wrong", ErrLoginFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jar, _ := cookiejar.New(nil)
+			client := &http.Client{Jar: jar}
+			recipe := &LoginRecipe{
+				LoginURL:      server.URL + "/login",
+				UsernameField: "email",
+				PasswordField: "password",
+				Username:      "user@example.com",
+				Password:      tt.password,
+			}
+
+			err := PerformFormLogin(context.Background(), client, recipe, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PerformFormLogin() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			resp, err := client.Get(server.URL + "/dashboard")
+			if err != nil {
+				t.Fatalf("fetching the protected page failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.Request.URL.Path != "/dashboard" {
+				t.Errorf("protected page redirected to %s, want the session to be kept", resp.Request.URL.Path)
+			}
+		})
+	}
+}
+
+func TestLoginRecipeValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		recipe  LoginRecipe
+		wantErr bool
+	}{
+		{"Valid recipe", LoginRecipe{LoginURL: "https://example.com/login", UsernameField: "email", PasswordField: "password", Username: "me"}, false},
+		{"Relative login URL", LoginRecipe{LoginURL: "/login", UsernameField: "email", PasswordField: "password", Username: "me"}, true},
+		{"Missing field name", LoginRecipe{LoginURL: "https://example.com/login", UsernameField: "email", Username: "me"}, true},
+		{"Missing username", LoginRecipe{LoginURL: "https://example.com/login", UsernameField: "email", PasswordField: "password"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.recipe.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
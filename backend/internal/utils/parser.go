@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -91,11 +92,16 @@ func CountHeadings(doc *html.Node) map[string]int {
 
 // LinkInfo represents detailed information about a single link
 type LinkInfo struct {
-	Href        string `json:"href"`         // Original href attribute value
-	AbsoluteURL string `json:"absolute_url"` // Resolved absolute URL
-	IsInternal  bool   `json:"is_internal"`  // Whether link is internal to the domain
-	AnchorText  string `json:"anchor_text"`  // Text content of the link
-	StatusCode  *int   `json:"status_code"`  // HTTP status code (nil if not checked)
+	Href         string        `json:"href"`                   // Original href attribute value
+	AbsoluteURL  string        `json:"absolute_url"`           // Resolved absolute URL
+	IsInternal   bool          `json:"is_internal"`            // Whether link is internal to the domain
+	AnchorText   string        `json:"anchor_text"`            // Text content of the link
+	StatusCode   *int          `json:"status_code"`            // HTTP status code (nil if not checked)
+	Reason       string        `json:"reason,omitempty"`       // Why the link is considered accessible or not, one of the LinkReason constants
+	RedirectURL  string        `json:"redirect_url,omitempty"` // Where the redirects of the link ended, if it was redirected
+	Fragment     string        `json:"fragment,omitempty"`     // Fragment part of the link, if any
+	BrokenAnchor bool          `json:"broken_anchor"`          // Whether the fragment points to an anchor missing on the target page
+	RetryAfter   time.Duration `json:"-"`                      // When the host of a rate limited link has a free slot again
 }
 
 // LinkAnalysis represents the result of link analysis
@@ -104,104 +110,204 @@ type LinkAnalysis struct {
 	Links    []LinkInfo     `json:"links"`
 }
 
-// CountLinks analyzes and counts internal, external, and inaccessible links in the HTML document
+// DefaultUserAgent is the User-Agent sent by the crawler unless a request profile overrides it
+const DefaultUserAgent = "Mozilla/5.0 (compatible; SykellBot/1.0)"
+
+// LinkChecker checks the accessibility and anchors of the links of a page
+type LinkChecker struct {
+	// Client sends every request, a client with the default timeouts is used when nil
+	Client *http.Client
+	// PrepareInternal customizes requests sent to the host of the analyzed page, e.g. to add credentials
+	PrepareInternal func(req *http.Request)
+	// CheckRedirect is applied to the redirects followed by the default clients, e.g. to remove the credentials
+	// PrepareInternal added when a redirect leaves the host
+	CheckRedirect func(req *http.Request, via []*http.Request) error
+	// Jar holds the cookies of a logged in session, used by the default clients when Client is nil
+	Jar http.CookieJar
+	// Transport sends the requests of the default clients, e.g. through an outbound proxy
+	Transport http.RoundTripper
+	// Cache remembers link statuses across pages and crawls, links checked with credentials bypass it
+	Cache LinkStatusCache
+}
+
+// LinkStatusCache stores link check results so the same URL is not checked again on every page
+type LinkStatusCache interface {
+	// Get returns the cached status of the URL
+	Get(absoluteURL string) (status LinkStatus, found bool)
+	// Put stores the status of a checked URL
+	Put(absoluteURL string, status LinkStatus)
+}
+
+// CountLinks analyzes and counts internal, external, inaccessible and broken anchor links in the HTML document
 func CountLinks(doc *html.Node, baseURL string) LinkAnalysis {
-	result := LinkAnalysis{
-		Counts: map[string]int{
-			"internal":     0,
-			"external":     0,
-			"inaccessible": 0,
-		},
-		Links: []LinkInfo{},
+	return (&LinkChecker{}).CountLinks(doc, baseURL)
+}
+
+// CountLinks analyzes and counts internal, external, inaccessible and broken anchor links in the HTML document
+func (c *LinkChecker) CountLinks(doc *html.Node, baseURL string) LinkAnalysis {
+	links := ExtractLinks(doc, baseURL)
+	c.CheckLinks(links, PageAnchors(doc, baseURL))
+	return LinkAnalysis{
+		Counts: CountLinkCategories(links),
+		Links:  links,
 	}
+}
+
+// ExtractLinks collects the links of the HTML document without checking them, fragment-only links
+// are validated right away against the anchors of the document and only kept when they are broken
+func ExtractLinks(doc *html.Node, baseURL string) []LinkInfo {
+	links := []LinkInfo{}
 
 	baseU, err := url.Parse(baseURL)
 	if err != nil {
-		return result
+		return links
 	}
+	anchors := ExtractAnchors(doc)
 
-	var countNodes func(*html.Node)
-	countNodes = func(n *html.Node) {
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
 		if n.Type == html.ElementNode && n.Data == "a" {
-			var hrefValue string
-			var anchorText string
-			
-			// Extract href attribute
-			for _, attr := range n.Attr {
-				if attr.Key == "href" {
-					hrefValue = attr.Val
-					break
-				}
-			}
-			
-			// Extract anchor text
-			anchorText = extractTextContent(n)
-			
-			// Skip if no href
-			if hrefValue == "" {
-				return
-			}
-			
-			// Skip fragment-only links
-			if strings.HasPrefix(hrefValue, "#") {
-				return
-			}
-			
-			// Skip javascript: and mailto: links
-			if strings.HasPrefix(strings.ToLower(hrefValue), "javascript:") || 
-			   strings.HasPrefix(strings.ToLower(hrefValue), "mailto:") ||
-			   strings.HasPrefix(strings.ToLower(hrefValue), "tel:") {
-				return
+			if link, ok := extractLink(n, baseU, anchors); ok {
+				links = append(links, link)
 			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			collect(child)
+		}
+	}
+	collect(doc)
+	return links
+}
 
-			linkURL, err := url.Parse(hrefValue)
-			if err != nil {
-				return
-			}
+// extractLink builds the link of an anchor element, it returns false for anchors that are not checked
+func extractLink(n *html.Node, baseU *url.URL, anchors map[string]bool) (LinkInfo, bool) {
+	var hrefValue string
+	for _, attr := range n.Attr {
+		if attr.Key == "href" {
+			hrefValue = attr.Val
+			break
+		}
+	}
+	// Skip if no href
+	if hrefValue == "" {
+		return LinkInfo{}, false
+	}
+	anchorText := extractTextContent(n)
 
-			// Resolve relative URLs
-			if !linkURL.IsAbs() {
-				linkURL = baseU.ResolveReference(linkURL)
-			}
+	// Fragment-only links are validated against the anchors of the current page
+	if strings.HasPrefix(hrefValue, "#") {
+		fragment := strings.TrimPrefix(hrefValue, "#")
+		if decoded, err := url.PathUnescape(fragment); err == nil {
+			fragment = decoded
+		}
+		if isFragmentValid(fragment, anchors) {
+			return LinkInfo{}, false
+		}
+		linkURL := *baseU
+		linkURL.Fragment = fragment
+		return LinkInfo{
+			Href:         hrefValue,
+			AbsoluteURL:  linkURL.String(),
+			IsInternal:   true,
+			AnchorText:   anchorText,
+			Fragment:     fragment,
+			BrokenAnchor: true,
+		}, true
+	}
 
-			absoluteURL := linkURL.String()
-			
-			// Create LinkInfo
-			linkInfo := LinkInfo{
-				Href:        hrefValue,
-				AbsoluteURL: absoluteURL,
-				IsInternal:  linkURL.Host == baseU.Host,
-				AnchorText:  anchorText,
-			}
+	// Skip javascript:, mailto: and tel: links
+	lower := strings.ToLower(hrefValue)
+	if strings.HasPrefix(lower, "javascript:") || strings.HasPrefix(lower, "mailto:") || strings.HasPrefix(lower, "tel:") {
+		return LinkInfo{}, false
+	}
 
-			// Check URL accessibility by making HTTP request
-			statusCode := checkURLStatus(absoluteURL)
-			linkInfo.StatusCode = statusCode
-			
-			// Categorize link based on status code
-			if statusCode == nil {
-				// Could not reach the URL at all
-				result.Counts["inaccessible"]++
-			} else if *statusCode >= 400 {
-				// 4xx or 5xx status codes are inaccessible
-				result.Counts["inaccessible"]++
-			} else if linkURL.Host == baseU.Host {
-				// Accessible internal link
-				result.Counts["internal"]++
-			} else {
-				// Accessible external link
-				result.Counts["external"]++
+	linkURL, err := url.Parse(hrefValue)
+	if err != nil {
+		return LinkInfo{}, false
+	}
+	// Resolve relative URLs
+	if !linkURL.IsAbs() {
+		linkURL = baseU.ResolveReference(linkURL)
+	}
+
+	return LinkInfo{
+		Href:        hrefValue,
+		AbsoluteURL: linkURL.String(),
+		IsInternal:  linkURL.Host == baseU.Host,
+		AnchorText:  anchorText,
+		Fragment:    linkURL.Fragment,
+	}, true
+}
+
+// PageAnchors returns the anchors of the document keyed by its URL without fragment, to seed CheckLinks
+func PageAnchors(doc *html.Node, baseURL string) map[string]map[string]bool {
+	pageAnchors := map[string]map[string]bool{}
+	if baseU, err := url.Parse(baseURL); err == nil {
+		pageAnchors[withoutFragment(baseU)] = ExtractAnchors(doc)
+	}
+	return pageAnchors
+}
+
+// CheckLinks checks the accessibility of the links in place and validates the fragments of accessible internal
+// links, pageAnchors holds the anchors of pages already fetched keyed by URL without fragment and is filled as
+// target pages are fetched, fragment-only links were already validated by ExtractLinks and are not checked again
+func (c *LinkChecker) CheckLinks(links []LinkInfo, pageAnchors map[string]map[string]bool) {
+	for i := range links {
+		link := &links[i]
+		if link.BrokenAnchor {
+			continue
+		}
+
+		// Check URL accessibility by making HTTP request
+		status := c.linkStatus(link.AbsoluteURL, link.IsInternal)
+		link.StatusCode = status.StatusCode
+		link.Reason = status.Reason
+		link.RedirectURL = status.RedirectURL
+		link.RetryAfter = status.RetryAfter
+
+		// Fragments of accessible internal links are validated against the target page anchors
+		if link.IsInternal && link.Fragment != "" && status.Accessible() {
+			linkURL, err := url.Parse(link.AbsoluteURL)
+			if err != nil {
+				continue
 			}
-			
-			// Add to links array
-			result.Links = append(result.Links, linkInfo)
+			target := withoutFragment(linkURL)
+			anchors, ok := pageAnchors[target]
+			if !ok {
+				anchors = c.fetchAnchors(target)
+				pageAnchors[target] = anchors
+			}
+			// Anchors are only validated when the target page could be fetched and parsed
+			link.BrokenAnchor = anchors != nil && !isFragmentValid(link.Fragment, anchors)
 		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			countNodes(c)
+	}
+}
+
+// CountLinkCategories counts checked links as internal, external, inaccessible or broken anchor
+func CountLinkCategories(links []LinkInfo) map[string]int {
+	counts := map[string]int{
+		"internal":      0,
+		"external":      0,
+		"inaccessible":  0,
+		"broken_anchor": 0,
+	}
+	for _, link := range links {
+		switch {
+		case link.BrokenAnchor:
+			// Reachable page, or the current page, without the referenced anchor
+			counts["broken_anchor"]++
+		case !link.Status().Accessible() && link.Reason != LinkReasonRateLimited:
+			// Unreachable, 4xx or 5xx, soft 404 or stuck in redirects, our own rate limit is not a failure of the link
+			counts["inaccessible"]++
+		case link.IsInternal:
+			// Accessible internal link
+			counts["internal"]++
+		default:
+			// Accessible external link
+			counts["external"]++
 		}
 	}
-	countNodes(doc)
-	return result
+	return counts
 }
 
 // extractTextContent extracts the text content from a node and its children
@@ -220,45 +326,137 @@ func extractTextContent(n *html.Node) string {
 	return strings.TrimSpace(text.String())
 }
 
-// checkURLStatus performs a HEAD request to check the status code of a URL
-func checkURLStatus(urlStr string) *int {
-	client := &http.Client{
-		Timeout: 15 * time.Second, // Increased timeout
+// newRequest creates a crawler request, applying the internal request customization for internal links
+func (c *LinkChecker) newRequest(method string, urlStr string, internal bool) (*http.Request, error) {
+	req, err := http.NewRequest(method, urlStr, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Set a reasonable User-Agent to avoid blocking
+	req.Header.Set("User-Agent", DefaultUserAgent)
+	if internal && c.PrepareInternal != nil {
+		c.PrepareInternal(req)
+	}
+	return req, nil
+}
+
+// statusClient returns the client used to check link statuses
+func (c *LinkChecker) statusClient() *http.Client {
+	if c.Client != nil {
+		return c.Client
+	}
+	return &http.Client{
+		Jar:       c.Jar,
+		Transport: c.Transport,
+		Timeout:   15 * time.Second, // Increased timeout
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			// Allow up to 5 redirects
 			if len(via) >= 5 {
 				return http.ErrUseLastResponse
 			}
+			if c.CheckRedirect != nil {
+				return c.CheckRedirect(req, via)
+			}
 			return nil
 		},
 	}
+}
 
-	// Set a reasonable User-Agent to avoid blocking
-	req, err := http.NewRequest("HEAD", urlStr, nil)
+// linkStatus returns the status of a link from the cache, checking and caching it on a miss,
+// links sent with the page's credentials or session are always checked since their status depends on them
+func (c *LinkChecker) linkStatus(urlStr string, internal bool) LinkStatus {
+	cacheable := c.Cache != nil && c.Jar == nil && !(internal && c.PrepareInternal != nil)
+	if cacheable {
+		if status, found := c.Cache.Get(urlStr); found {
+			return status
+		}
+	}
+	status, err := c.probeURL(urlStr, internal)
+	// Transient failures such as timeouts are not cached, unreachable hosts are
+	if cacheable && (status.StatusCode != nil || isPermanentFailure(err)) {
+		c.Cache.Put(urlStr, status)
+	}
+	return status
+}
+
+// maxAnchorPageSize limits how much of a target page is read when looking up its anchors
+const maxAnchorPageSize = 5 << 20
+
+// ExtractAnchors collects the element ids and named anchors that fragments in the document can point to
+func ExtractAnchors(doc *html.Node) map[string]bool {
+	anchors := make(map[string]bool)
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for _, attr := range n.Attr {
+				if attr.Key == "id" && attr.Val != "" {
+					anchors[attr.Val] = true
+				}
+				if attr.Key == "name" && n.Data == "a" && attr.Val != "" {
+					anchors[attr.Val] = true
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(doc)
+	return anchors
+}
+
+// isFragmentValid reports whether a fragment resolves on a page with the given anchors,
+// empty and "top" fragments always scroll to the top, and client-side routes ("#!", "#/") are not anchors
+func isFragmentValid(fragment string, anchors map[string]bool) bool {
+	if fragment == "" || strings.EqualFold(fragment, "top") {
+		return true
+	}
+	if strings.HasPrefix(fragment, "!") || strings.HasPrefix(fragment, "/") {
+		return true
+	}
+	return anchors[fragment]
+}
+
+// withoutFragment returns the string form of the URL with its fragment removed
+func withoutFragment(u *url.URL) string {
+	copied := *u
+	copied.Fragment = ""
+	copied.RawFragment = ""
+	return copied.String()
+}
+
+// fetchAnchors downloads an internal HTML page and returns its anchors, or nil if the page could not be fetched or parsed
+func (c *LinkChecker) fetchAnchors(urlStr string) map[string]bool {
+	client := c.Client
+	if client == nil {
+		client = &http.Client{
+			Jar:           c.Jar,
+			Transport:     c.Transport,
+			Timeout:       15 * time.Second,
+			CheckRedirect: c.CheckRedirect,
+		}
+	}
+
+	req, err := c.newRequest("GET", urlStr, true)
 	if err != nil {
 		return nil
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; SykellBot/1.0)")
 
-	// Try HEAD request first (faster)
 	resp, err := client.Do(req)
 	if err != nil {
-		// If HEAD fails, try GET request
-		getReq, err := http.NewRequest("GET", urlStr, nil)
-		if err != nil {
-			return nil
-		}
-		getReq.Header.Set("User-Agent", "Mozilla/5.0 (compatible; SykellBot/1.0)")
-		
-		resp, err = client.Do(getReq)
-		if err != nil {
-			// If both fail, return nil (unknown status)
-			return nil
-		}
+		return nil
 	}
 	defer resp.Body.Close()
 
-	return &resp.StatusCode
+	if resp.StatusCode >= 400 || !strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "html") {
+		return nil
+	}
+
+	doc, err := html.Parse(io.LimitReader(resp.Body, maxAnchorPageSize))
+	if err != nil {
+		return nil
+	}
+	return ExtractAnchors(doc)
 }
 
 // HasLoginForm checks if the HTML document contains a login form
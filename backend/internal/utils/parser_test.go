@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -499,7 +500,71 @@ func TestHasLoginForm(t *testing.T) {
 	}
 }
 
-func TestCheckURLStatus(t *testing.T) {
+func TestExtractAnchors(t *testing.T) {
+	doc := parseHTML(`<html><body>
+		<h2 id="section-1">Section 1</h2>
+		<div id="content"><a name="legacy">Legacy anchor</a></div>
+		<input name="not-an-anchor">
+	</body></html>`)
+
+	anchors := ExtractAnchors(doc)
+	for _, expected := range []string{"section-1", "content", "legacy"} {
+		if !anchors[expected] {
+			t.Errorf("ExtractAnchors() missing %q", expected)
+		}
+	}
+	if anchors["not-an-anchor"] {
+		t.Errorf("ExtractAnchors() should only treat name attributes of <a> elements as anchors")
+	}
+}
+
+func TestCountLinksBrokenAnchors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		switch r.URL.Path {
+		case "/target":
+			w.Write([]byte(`<html><body><h2 id="exists">Exists</h2></body></html>`))
+		default:
+			w.Write([]byte(`<html><body></body></html>`))
+		}
+	}))
+	defer server.Close()
+
+	doc := parseHTML(`<html><body>
+		<h1 id="intro">Intro</h1>
+		<a href="#intro">Valid in-page anchor</a>
+		<a href="#top">Top of page</a>
+		<a href="#/route">Client-side route</a>
+		<a href="#missing">Broken in-page anchor</a>
+		<a href="/target#exists">Valid anchor on target page</a>
+		<a href="/target#gone">Broken anchor on target page</a>
+		<a href="/target">Plain internal link</a>
+	</body></html>`)
+
+	result := CountLinks(doc, server.URL+"/page")
+
+	if result.Counts["broken_anchor"] != 2 {
+		t.Errorf("CountLinks() broken_anchor = %d, want 2", result.Counts["broken_anchor"])
+	}
+	if result.Counts["internal"] != 2 {
+		t.Errorf("CountLinks() internal = %d, want 2", result.Counts["internal"])
+	}
+	if result.Counts["inaccessible"] != 0 {
+		t.Errorf("CountLinks() inaccessible = %d, want 0", result.Counts["inaccessible"])
+	}
+
+	broken := map[string]bool{}
+	for _, link := range result.Links {
+		if link.BrokenAnchor {
+			broken[link.Href] = true
+		}
+	}
+	if !broken["#missing"] || !broken["/target#gone"] {
+		t.Errorf("CountLinks() broken anchors = %v, want #missing and /target#gone", broken)
+	}
+}
+
+func TestProbeURLStatusCode(t *testing.T) {
 	// Create a test server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
@@ -551,17 +616,18 @@ func TestCheckURLStatus(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := checkURLStatus(tt.url)
+			status, _ := (&LinkChecker{}).probeURL(tt.url, false)
+			result := status.StatusCode
 			
 			if tt.expected == nil {
 				if result != nil {
-					t.Errorf("checkURLStatus() = %v, want nil", *result)
+					t.Errorf("probeURL() status code = %v, want nil", *result)
 				}
 			} else {
 				if result == nil {
-					t.Errorf("checkURLStatus() = nil, want %v", *tt.expected)
+					t.Errorf("probeURL() status code = nil, want %v", *tt.expected)
 				} else if *result != *tt.expected {
-					t.Errorf("checkURLStatus() = %v, want %v", *result, *tt.expected)
+					t.Errorf("probeURL() status code = %v, want %v", *result, *tt.expected)
 				}
 			}
 		})
@@ -611,4 +677,113 @@ func BenchmarkHasLoginForm(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		HasLoginForm(doc)
 	}
-}
\ No newline at end of file
+}
+// mapLinkStatusCache is an in-memory LinkStatusCache
+type mapLinkStatusCache map[string]LinkStatus
+
+func (m mapLinkStatusCache) Get(absoluteURL string) (LinkStatus, bool) {
+	status, ok := m[absoluteURL]
+	return status, ok
+}
+
+func (m mapLinkStatusCache) Put(absoluteURL string, status LinkStatus) {
+	m[absoluteURL] = status
+}
+
+func TestLinkCheckerUsesCache(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	doc := parseHTML(`<html><body>
+		<a href="/about">About</a>
+		<a href="/missing">Missing</a>
+		<a href="/about">About again</a>
+	</body></html>`)
+	cache := mapLinkStatusCache{}
+	checker := &LinkChecker{Cache: cache}
+
+	first := checker.CountLinks(doc, server.URL+"/")
+	afterFirst := requests
+	second := checker.CountLinks(doc, server.URL+"/")
+
+	if afterFirst != 2 {
+		t.Errorf("first crawl sent %d requests, want 2", afterFirst)
+	}
+	if requests != afterFirst {
+		t.Errorf("second crawl sent %d requests, want 0", requests-afterFirst)
+	}
+	if !reflect.DeepEqual(first.Counts, second.Counts) {
+		t.Errorf("cached counts = %v, want %v", second.Counts, first.Counts)
+	}
+	if status := cache[server.URL+"/missing"]; status.StatusCode == nil || *status.StatusCode != http.StatusNotFound {
+		t.Errorf("cached status of /missing = %+v, want 404", status)
+	}
+
+	// Links checked with the page's credentials bypass the cache
+	credentialed := &LinkChecker{Cache: mapLinkStatusCache{}, PrepareInternal: func(req *http.Request) {}}
+	before := requests
+	credentialed.CountLinks(doc, server.URL+"/")
+	if requests-before != 3 {
+		t.Errorf("credentialed crawl sent %d requests, want 3", requests-before)
+	}
+}
+
+func TestCheckLinksInBatches(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/docs":
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><body><h2 id="usage">Usage</h2></body></html>`)
+		}
+	}))
+	defer server.Close()
+
+	doc := parseHTML(`<html><body>
+		<h1 id="intro">Intro</h1>
+		<a href="#intro">Intro</a>
+		<a href="#nowhere">Nowhere</a>
+		<a href="/docs">Docs</a>
+		<a href="/missing">Missing</a>
+		<a href="/guide#usage">Usage</a>
+		<a href="/guide#setup">Setup</a>
+		<a href="mailto:team@example.com">Mail</a>
+	</body></html>`)
+	baseURL := server.URL + "/"
+
+	links := ExtractLinks(doc, baseURL)
+	if len(links) != 5 {
+		t.Fatalf("ExtractLinks() returned %d links, want 5", len(links))
+	}
+	for _, link := range links {
+		if link.StatusCode != nil {
+			t.Errorf("ExtractLinks() checked %s", link.AbsoluteURL)
+		}
+	}
+
+	// Checking the links in batches gives the same counts as checking the whole page at once
+	checker := &LinkChecker{}
+	pageAnchors := PageAnchors(doc, baseURL)
+	for start := 0; start < len(links); start += 2 {
+		checker.CheckLinks(links[start:min(start+2, len(links))], pageAnchors)
+	}
+	want := checker.CountLinks(doc, baseURL).Counts
+	if got := CountLinkCategories(links); !reflect.DeepEqual(got, want) {
+		t.Errorf("batched counts = %v, want %v", got, want)
+	}
+	if want["broken_anchor"] != 2 || want["inaccessible"] != 1 || want["internal"] != 2 {
+		t.Errorf("CountLinks() counts = %v, want 2 broken anchors, 1 inaccessible and 2 internal", want)
+	}
+}
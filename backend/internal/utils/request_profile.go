@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Request profile limits
+const (
+	maxProfileHeaders   = 50
+	maxProfileCookies   = 50
+	maxProfileUserAgent = 512
+	maxProfileUsername  = 255
+)
+
+// forbiddenProfileHeaders are managed by the HTTP client and cannot be overridden by a profile
+var forbiddenProfileHeaders = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+	"Cookie":            true,
+}
+
+// RequestProfile holds the custom request settings used to crawl a URL
+type RequestProfile struct {
+	UserAgent            string            `json:"user_agent"`
+	Headers              map[string]string `json:"headers"`
+	Cookies              map[string]string `json:"cookies"`
+	BasicAuthUsername    string            `json:"basic_auth_username"`
+	BasicAuthPassword    string            `json:"basic_auth_password"`
+	ApplyToInternalLinks bool              `json:"apply_to_internal_links"` // Whether internal link checks use the profile as well
+	Login                *LoginRecipe      `json:"login"`                   // Form login submitted before crawling, nil when the URL needs none
+	ProxyURL             string            `json:"proxy_url"`               // Outbound proxy overriding the configured one, empty to use the default
+}
+
+// requestProfileSecrets holds the parts of a request profile that are encrypted at rest
+type requestProfileSecrets struct {
+	Headers           map[string]string `json:"headers"`
+	Cookies           map[string]string `json:"cookies"`
+	BasicAuthPassword string            `json:"basic_auth_password"`
+	LoginPassword     string            `json:"login_password,omitempty"`
+	LoginExtraFields  map[string]string `json:"login_extra_fields,omitempty"`
+	ProxyURL          string            `json:"proxy_url,omitempty"`
+}
+
+// Validate checks that the profile only contains well-formed headers and cookies
+func (p *RequestProfile) Validate() error {
+	if len(p.UserAgent) > maxProfileUserAgent {
+		return fmt.Errorf("user agent must be at most %d characters", maxProfileUserAgent)
+	}
+	if !httpguts.ValidHeaderFieldValue(p.UserAgent) {
+		return fmt.Errorf("invalid user agent")
+	}
+	if len(p.BasicAuthUsername) > maxProfileUsername || strings.Contains(p.BasicAuthUsername, ":") {
+		return fmt.Errorf("invalid basic auth username")
+	}
+	if len(p.Headers) > maxProfileHeaders {
+		return fmt.Errorf("at most %d headers are allowed", maxProfileHeaders)
+	}
+	for name, value := range p.Headers {
+		if !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(value) {
+			return fmt.Errorf("invalid header %q", name)
+		}
+		if forbiddenProfileHeaders[http.CanonicalHeaderKey(name)] {
+			return fmt.Errorf("header %q cannot be set", name)
+		}
+	}
+	if len(p.Cookies) > maxProfileCookies {
+		return fmt.Errorf("at most %d cookies are allowed", maxProfileCookies)
+	}
+	for name, value := range p.Cookies {
+		if err := (&http.Cookie{Name: name, Value: value}).Valid(); err != nil {
+			return fmt.Errorf("invalid cookie %q", name)
+		}
+	}
+	if p.ProxyURL != "" {
+		if _, err := ParseProxyURL(p.ProxyURL); err != nil {
+			return err
+		}
+	}
+	if p.Login != nil {
+		return p.Login.Validate()
+	}
+	return nil
+}
+
+// Apply adds the profile's user agent, headers, cookies and basic auth credentials to the request
+func (p *RequestProfile) Apply(req *http.Request) {
+	if p.UserAgent != "" {
+		req.Header.Set("User-Agent", p.UserAgent)
+	}
+	for name, value := range p.Headers {
+		req.Header.Set(name, value)
+	}
+	for _, name := range sortedKeys(p.Cookies) {
+		req.AddCookie(&http.Cookie{Name: name, Value: p.Cookies[name]})
+	}
+	if p.BasicAuthUsername != "" {
+		req.SetBasicAuth(p.BasicAuthUsername, p.BasicAuthPassword)
+	}
+}
+
+// maxProfileRedirects is how many redirects a request sent with the profile follows, as many as the default client
+const maxProfileRedirects = 10
+
+// CheckRedirect is the redirect policy of the clients sending requests with the profile, the profile's headers,
+// cookies and basic auth credentials are removed from a redirect to another host than the one first requested
+func (p *RequestProfile) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxProfileRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxProfileRedirects)
+	}
+	if len(via) > 0 && !strings.EqualFold(req.URL.Host, via[0].URL.Host) {
+		p.remove(req)
+	}
+	return nil
+}
+
+// remove deletes the credentials Apply added from the request, the cookies of a login session are added back by
+// the client's jar for the hosts they belong to
+func (p *RequestProfile) remove(req *http.Request) {
+	for name := range p.Headers {
+		req.Header.Del(name)
+	}
+	if len(p.Cookies) > 0 {
+		req.Header.Del("Cookie")
+	}
+	if p.BasicAuthUsername != "" {
+		req.Header.Del("Authorization")
+	}
+}
+
+// SealSecrets encrypts the headers, cookies, passwords and proxy of the profile
+func (p *RequestProfile) SealSecrets(key []byte) (string, error) {
+	secrets := requestProfileSecrets{
+		Headers:           p.Headers,
+		Cookies:           p.Cookies,
+		BasicAuthPassword: p.BasicAuthPassword,
+		ProxyURL:          p.ProxyURL,
+	}
+	if p.Login != nil {
+		secrets.LoginPassword = p.Login.Password
+		secrets.LoginExtraFields = p.Login.ExtraFields
+	}
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return "", err
+	}
+	return Encrypt(key, plaintext)
+}
+
+// OpenSecrets decrypts secrets sealed by SealSecrets into the profile, the login secrets are only
+// restored when the profile's login recipe has been set
+func (p *RequestProfile) OpenSecrets(key []byte, sealed string) error {
+	plaintext, err := Decrypt(key, sealed)
+	if err != nil {
+		return err
+	}
+	var secrets requestProfileSecrets
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return fmt.Errorf("failed to decode request profile secrets: %w", err)
+	}
+	p.Headers = secrets.Headers
+	p.Cookies = secrets.Cookies
+	p.BasicAuthPassword = secrets.BasicAuthPassword
+	p.ProxyURL = secrets.ProxyURL
+	if p.Login != nil {
+		p.Login.Password = secrets.LoginPassword
+		p.Login.ExtraFields = secrets.LoginExtraFields
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of the map in a stable order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
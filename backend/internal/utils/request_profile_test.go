@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := DeriveKey("test-secret")
+
+	sealed, err := Encrypt(key, []byte("hunter2"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if strings.Contains(sealed, "hunter2") {
+		t.Errorf("Encrypt() leaked the plaintext: %s", sealed)
+	}
+
+	plaintext, err := Decrypt(key, sealed)
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if string(plaintext) != "hunter2" {
+		t.Errorf("Decrypt() = %q, want %q", plaintext, "hunter2")
+	}
+
+	if _, err := Decrypt(DeriveKey("other-secret"), sealed); err == nil {
+		t.Errorf("Decrypt() with the wrong key should fail")
+	}
+}
+
+func TestRequestProfileSecretsRoundTrip(t *testing.T) {
+	key := DeriveKey("test-secret")
+	profile := RequestProfile{
+		UserAgent:         "MobileBot/1.0",
+		Headers:           map[string]string{"X-Api-Key": "abc"},
+		Cookies:           map[string]string{"session": "s3cr3t"},
+		BasicAuthUsername: "staging",
+		BasicAuthPassword: "hunter2",
+	}
+
+	sealed, err := profile.SealSecrets(key)
+	if err != nil {
+		t.Fatalf("SealSecrets() error = %v", err)
+	}
+
+	opened := RequestProfile{UserAgent: profile.UserAgent, BasicAuthUsername: profile.BasicAuthUsername}
+	if err := opened.OpenSecrets(key, sealed); err != nil {
+		t.Fatalf("OpenSecrets() error = %v", err)
+	}
+	if opened.Headers["X-Api-Key"] != "abc" || opened.Cookies["session"] != "s3cr3t" || opened.BasicAuthPassword != "hunter2" {
+		t.Errorf("OpenSecrets() = %+v, want the original secrets", opened)
+	}
+}
+
+func TestRequestProfileValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile RequestProfile
+		wantErr bool
+	}{
+		{"Valid profile", RequestProfile{UserAgent: "Bot", Headers: map[string]string{"X-Token": "1"}, Cookies: map[string]string{"sid": "2"}}, false},
+		{"Invalid header name", RequestProfile{Headers: map[string]string{"Bad Header": "1"}}, true},
+		{"Header value with newline", RequestProfile{Headers: map[string]string{"X-Token": "1\r\nX-Injected: 2"}}, true},
+		{"Forbidden header", RequestProfile{Headers: map[string]string{"host": "example.com"}}, true},
+		{"Invalid cookie name", RequestProfile{Cookies: map[string]string{"bad;name": "1"}}, true},
+		{"Username with colon", RequestProfile{BasicAuthUsername: "a:b"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.profile.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLinkCheckerAppliesProfileToInternalLinks(t *testing.T) {
+	var internalAuth, externalAuth []string
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		internalAuth = append(internalAuth, user)
+		if user != "staging" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer internal.Close()
+	external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		externalAuth = append(externalAuth, user)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer external.Close()
+
+	profile := RequestProfile{BasicAuthUsername: "staging", BasicAuthPassword: "hunter2"}
+	checker := &LinkChecker{PrepareInternal: profile.Apply}
+
+	doc, _ := html.Parse(strings.NewReader(`<html><body>
+		<a href="/private">Private</a>
+		<a href="` + external.URL + `/public">Public</a>
+	</body></html>`))
+	result := checker.CountLinks(doc, internal.URL+"/page")
+
+	if result.Counts["inaccessible"] != 0 {
+		t.Errorf("CountLinks() inaccessible = %d, want 0", result.Counts["inaccessible"])
+	}
+	for _, user := range internalAuth {
+		if user != "staging" {
+			t.Errorf("internal link check sent user %q, want %q", user, "staging")
+		}
+	}
+	for _, user := range externalAuth {
+		if user != "" {
+			t.Errorf("external link check leaked credentials for user %q", user)
+		}
+	}
+}
+
+func TestRequestProfileCheckRedirectRemovesCredentials(t *testing.T) {
+	var leaked []string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "" || r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" {
+			leaked = append(leaked, r.Header.Get("X-Api-Key"), r.Header.Get("Authorization"), r.Header.Get("Cookie"))
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer other.Close()
+	var originKey string
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/moved" {
+			http.Redirect(w, r, other.URL+"/landing", http.StatusFound)
+			return
+		}
+		originKey = r.Header.Get("X-Api-Key")
+		http.Redirect(w, r, "/moved", http.StatusFound)
+	}))
+	defer origin.Close()
+
+	profile := RequestProfile{
+		Headers:           map[string]string{"X-Api-Key": "secret"},
+		Cookies:           map[string]string{"session": "secret"},
+		BasicAuthUsername: "staging",
+		BasicAuthPassword: "hunter2",
+	}
+	req, _ := http.NewRequest("GET", origin.URL+"/start", nil)
+	profile.Apply(req)
+	client := &http.Client{CheckRedirect: profile.CheckRedirect}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if originKey != "secret" {
+		t.Errorf("origin got X-Api-Key %q, want the profile's header", originKey)
+	}
+	if len(leaked) > 0 {
+		t.Errorf("redirect to another host leaked the profile's credentials: %q", leaked)
+	}
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+)
+
+// ErrBlockedAddress is returned when a request would connect to a private, loopback, link-local or otherwise internal address
+var ErrBlockedAddress = errors.New("destination address is not allowed")
+
+// blockedPrefixes are special-purpose ranges that are not covered by the netip classification helpers
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "This" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // Documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // Benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // Documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // Documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // Reserved, including the broadcast address
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, can embed internal IPv4 addresses
+	netip.MustParsePrefix("2001:db8::/32"),   // Documentation
+}
+
+// AddressGuard decides which IP addresses crawler connections may be opened to
+type AddressGuard struct {
+	allowed []netip.Prefix
+}
+
+// NewAddressGuard creates a guard that blocks internal addresses except for the allowlisted IPs or CIDR ranges
+func NewAddressGuard(allowlist []string) (*AddressGuard, error) {
+	guard := &AddressGuard{}
+	for _, entry := range allowlist {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid allowlist entry %q", entry)
+			}
+			guard.allowed = append(guard.allowed, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowlist entry %q", entry)
+		}
+		guard.allowed = append(guard.allowed, prefix.Masked())
+	}
+	return guard, nil
+}
+
+// Allowed reports whether connections to the address are permitted
+func (g *AddressGuard) Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range g.allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return !isInternalAddress(addr)
+}
+
+// Control is a net.Dialer control function, it runs after DNS resolution for every connection,
+// including the ones opened for redirects, so the resolved address is checked rather than the host name
+func (g *AddressGuard) Control(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !g.Allowed(addr) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	return nil
+}
+
+// CheckHost resolves the host and fails with ErrBlockedAddress when any of its addresses is not allowed, it
+// checks the targets of proxied requests, the dialer only sees the address of the proxy
+func (g *AddressGuard) CheckHost(ctx context.Context, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !g.Allowed(addr) {
+			return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !g.Allowed(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrBlockedAddress, host, addr)
+		}
+	}
+	return nil
+}
+
+// isInternalAddress reports whether the address belongs to a private, loopback, link-local or reserved range,
+// the cloud metadata endpoints 169.254.169.254 and fd00:ec2::254 are link-local and private respectively
+func isInternalAddress(addr netip.Addr) bool {
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
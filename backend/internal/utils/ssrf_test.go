@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+func TestAddressGuardAllowed(t *testing.T) {
+	guard, err := NewAddressGuard([]string{"10.0.5.0/24", "192.168.1.10"})
+	if err != nil {
+		t.Fatalf("NewAddressGuard() error = %v", err)
+	}
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.11", false},
+		{"169.254.169.254", false},
+		{"fd00:ec2::254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+		{"10.0.5.20", true},
+		{"192.168.1.10", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := guard.Allowed(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("Allowed(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAddressGuardRejectsInvalidEntries(t *testing.T) {
+	if _, err := NewAddressGuard([]string{"not-an-ip"}); err == nil {
+		t.Errorf("NewAddressGuard() should reject invalid entries")
+	}
+}
+
+func TestCrawlTransportBlocksInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	guard, _ := NewAddressGuard(nil)
+	client := &http.Client{Transport: NewCrawlTransport(nil, guard)}
+	_, err := client.Get(server.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("Get() error = %v, want %v", err, ErrBlockedAddress)
+	}
+
+	allowed, _ := NewAddressGuard([]string{"127.0.0.1"})
+	client = &http.Client{Transport: NewCrawlTransport(nil, allowed)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() with an allowlisted address error = %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestCrawlTransportBlocksInternalTargetsThroughProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	// The proxy itself is allowlisted, the internal targets it would fetch are not
+	guard, _ := NewAddressGuard([]string{"127.0.0.1"})
+	client := &http.Client{Transport: NewCrawlTransport(proxyURL, guard)}
+	for _, target := range []string{"http://169.254.169.254/latest/meta-data/", "http://10.0.0.1/"} {
+		_, err := client.Get(target)
+		if !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("Get(%s) error = %v, want %v", target, err, ErrBlockedAddress)
+		}
+	}
+	if len(proxied) != 0 {
+		t.Errorf("proxy received %v, want no request", proxied)
+	}
+
+	// Allowlisted targets are still sent through the proxy
+	resp, err := client.Get("http://127.0.0.1:1/page")
+	if err != nil {
+		t.Fatalf("Get() through the proxy error = %v", err)
+	}
+	resp.Body.Close()
+	if len(proxied) != 1 || proxied[0] != "http://127.0.0.1:1/page" {
+		t.Errorf("proxy received %v, want the allowlisted target", proxied)
+	}
+}
+
+func TestCrawlTransportIgnoresProxyEnvironment(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "http://proxy.example.com:3128")
+	transport := NewCrawlTransport(nil, nil)
+	if transport.Proxy != nil {
+		t.Errorf("NewCrawlTransport() without a proxy should not use the proxy environment variables")
+	}
+}
@@ -0,0 +1,4 @@
+ALTER TABLE crawls
+DROP KEY idx_crawls_content_hash,
+DROP COLUMN simhash,
+DROP COLUMN content_hash;
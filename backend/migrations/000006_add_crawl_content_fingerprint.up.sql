@@ -0,0 +1,6 @@
+-- Content fingerprints used to detect duplicate and near-duplicate pages across a user's URLs
+-- simhash is stored as a signed BIGINT holding the raw 64 bits of the fingerprint
+ALTER TABLE crawls
+ADD COLUMN content_hash CHAR(64) NULL AFTER has_login_form,
+ADD COLUMN simhash BIGINT NULL AFTER content_hash,
+ADD KEY idx_crawls_content_hash (content_hash);
@@ -0,0 +1,3 @@
+-- Links whose fragment points to an anchor that does not exist on the target page
+ALTER TABLE crawls
+ADD COLUMN broken_anchors_count INT UNSIGNED DEFAULT 0 AFTER inaccessible_links_count;
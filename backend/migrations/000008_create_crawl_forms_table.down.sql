@@ -0,0 +1,5 @@
+ALTER TABLE crawls
+DROP COLUMN flagged_forms_count,
+DROP COLUMN form_types;
+
+DROP TABLE crawl_forms;
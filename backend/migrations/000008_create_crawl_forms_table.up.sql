@@ -0,0 +1,23 @@
+CREATE TABLE crawl_forms (
+  id           CHAR(36) PRIMARY KEY DEFAULT (UUID()),
+  crawl_id     CHAR(36) NOT NULL,
+  position     INT UNSIGNED NOT NULL,
+  form_type    VARCHAR(32) NOT NULL,
+  method       VARCHAR(16) NOT NULL,
+  action       VARCHAR(2083) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci NOT NULL,
+  fields       JSON NOT NULL,
+  is_https     BOOLEAN NOT NULL,
+  is_off_site  BOOLEAN NOT NULL,
+  is_insecure  BOOLEAN NOT NULL,
+
+  created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+  CONSTRAINT fk_forms_crawl FOREIGN KEY (crawl_id) REFERENCES crawls(id) ON DELETE CASCADE,
+  KEY idx_forms_crawl (crawl_id, position),
+  KEY idx_forms_type  (crawl_id, form_type)
+);
+
+-- Summary of the form classifications found on the crawled page
+ALTER TABLE crawls
+ADD COLUMN form_types VARCHAR(255) NULL AFTER has_login_form,
+ADD COLUMN flagged_forms_count INT UNSIGNED DEFAULT 0 AFTER form_types;
@@ -0,0 +1,5 @@
+ALTER TABLE crawls
+DROP COLUMN content_length,
+DROP COLUMN charset,
+DROP COLUMN mime_type,
+DROP COLUMN outcome;
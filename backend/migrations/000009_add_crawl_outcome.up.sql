@@ -0,0 +1,6 @@
+-- Outcome of the fetch and the detected type, encoding and size of the crawled resource
+ALTER TABLE crawls
+ADD COLUMN outcome VARCHAR(32) NULL AFTER status,
+ADD COLUMN mime_type VARCHAR(255) NULL AFTER outcome,
+ADD COLUMN charset VARCHAR(64) NULL AFTER mime_type,
+ADD COLUMN content_length BIGINT NULL AFTER charset;
@@ -0,0 +1,4 @@
+ALTER TABLE crawls
+DROP COLUMN response_headers,
+DROP COLUMN snapshot_size,
+DROP COLUMN snapshot_hash;
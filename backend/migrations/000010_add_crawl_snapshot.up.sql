@@ -0,0 +1,5 @@
+-- Content address of the archived raw HTML and the response headers it was served with
+ALTER TABLE crawls
+ADD COLUMN snapshot_hash CHAR(64) NULL AFTER content_length,
+ADD COLUMN snapshot_size BIGINT NULL AFTER snapshot_hash,
+ADD COLUMN response_headers JSON NULL AFTER snapshot_size;
@@ -0,0 +1,4 @@
+ALTER TABLE crawls
+DROP KEY idx_crawls_analysis_version,
+DROP COLUMN analyzed_at,
+DROP COLUMN analysis_version;
@@ -0,0 +1,5 @@
+-- Version of the extractors that produced the stored analysis, crawls analyzed before versioning are version 1
+ALTER TABLE crawls
+ADD COLUMN analysis_version INT UNSIGNED NOT NULL DEFAULT 1 AFTER response_headers,
+ADD COLUMN analyzed_at TIMESTAMP NULL AFTER analysis_version,
+ADD KEY idx_crawls_analysis_version (analysis_version);
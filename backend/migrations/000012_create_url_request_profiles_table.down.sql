@@ -0,0 +1 @@
+DROP TABLE url_request_profiles;
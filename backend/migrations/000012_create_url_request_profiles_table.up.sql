@@ -0,0 +1,13 @@
+CREATE TABLE url_request_profiles (
+  url_id                  CHAR(36) PRIMARY KEY,
+  user_agent              VARCHAR(512) NULL,
+  basic_auth_username     VARCHAR(255) NULL,
+  -- Headers, cookies and the basic auth password, encrypted with AES-256-GCM
+  encrypted_secrets       TEXT NOT NULL,
+  apply_to_internal_links BOOLEAN NOT NULL DEFAULT FALSE,
+
+  created_at              TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at              TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+  CONSTRAINT fk_request_profiles_url FOREIGN KEY (url_id) REFERENCES urls(id) ON DELETE CASCADE
+);
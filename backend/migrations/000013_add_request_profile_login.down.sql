@@ -0,0 +1,5 @@
+ALTER TABLE url_request_profiles
+DROP COLUMN login_username,
+DROP COLUMN login_password_field,
+DROP COLUMN login_username_field,
+DROP COLUMN login_url;
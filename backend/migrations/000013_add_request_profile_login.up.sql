@@ -0,0 +1,6 @@
+-- Login recipe submitted before crawling, the login password is stored with the encrypted secrets
+ALTER TABLE url_request_profiles
+ADD COLUMN login_url VARCHAR(2083) NULL AFTER apply_to_internal_links,
+ADD COLUMN login_username_field VARCHAR(255) NULL AFTER login_url,
+ADD COLUMN login_password_field VARCHAR(255) NULL AFTER login_username_field,
+ADD COLUMN login_username VARCHAR(255) NULL AFTER login_password_field;
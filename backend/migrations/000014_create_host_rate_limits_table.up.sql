@@ -0,0 +1,13 @@
+-- Per-host politeness state shared by all workers, times are Unix milliseconds
+CREATE TABLE host_rate_limits (
+  host                   VARCHAR(255) PRIMARY KEY,
+  -- Theoretical arrival time of the next request, the state of the host's token bucket
+  next_slot_ms           BIGINT NOT NULL DEFAULT 0,
+  -- No request is sent before this time, set from Retry-After responses
+  blocked_until_ms       BIGINT NOT NULL DEFAULT 0,
+  -- Crawl-delay from robots.txt, NULL when the host does not set one
+  crawl_delay_ms         INT UNSIGNED NULL,
+  crawl_delay_expires_ms BIGINT NOT NULL DEFAULT 0,
+
+  updated_at             TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
@@ -0,0 +1,5 @@
+ALTER TABLE crawls
+DROP COLUMN link_cache_misses,
+DROP COLUMN link_cache_hits;
+
+DROP TABLE link_status_cache;
@@ -0,0 +1,16 @@
+-- Link check results shared by all crawls, keyed like crawl_links.absolute_url_hash
+CREATE TABLE link_status_cache (
+  url_hash     BINARY(16) PRIMARY KEY,
+  absolute_url VARCHAR(2083) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci NOT NULL,
+  -- NULL when the URL could not be reached
+  status_code  INT NULL,
+  checked_at   TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at   TIMESTAMP NOT NULL,
+
+  KEY idx_link_status_cache_expires (expires_at)
+);
+
+-- How many link statuses of a crawl came from the cache
+ALTER TABLE crawls
+ADD COLUMN link_cache_hits INT UNSIGNED NOT NULL DEFAULT 0 AFTER broken_anchors_count,
+ADD COLUMN link_cache_misses INT UNSIGNED NOT NULL DEFAULT 0 AFTER link_cache_hits;
@@ -0,0 +1,10 @@
+ALTER TABLE link_status_cache
+DROP COLUMN redirect_url,
+DROP COLUMN reason;
+
+ALTER TABLE crawl_links
+MODIFY COLUMN is_accessible BOOLEAN AS (CASE WHEN status_code IS NULL THEN NULL WHEN status_code BETWEEN 400 AND 599 THEN 0 ELSE 1 END) STORED;
+
+ALTER TABLE crawl_links
+DROP COLUMN redirect_url,
+DROP COLUMN reason;
@@ -0,0 +1,15 @@
+-- Why a link is considered accessible or not, and where its redirects ended
+ALTER TABLE crawl_links
+ADD COLUMN reason VARCHAR(32) NULL AFTER status_code,
+ADD COLUMN redirect_url VARCHAR(2083) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci NULL AFTER reason;
+
+-- Soft 404s and redirect loops answer with a non-error status but are not accessible
+ALTER TABLE crawl_links
+MODIFY COLUMN is_accessible BOOLEAN AS (CASE WHEN reason IN ('soft_404', 'too_many_redirects') THEN 0 WHEN status_code IS NULL THEN NULL WHEN status_code BETWEEN 400 AND 599 THEN 0 ELSE 1 END) STORED;
+
+-- Cached statuses have no reason yet, they are checked again on the next crawl
+DELETE FROM link_status_cache;
+
+ALTER TABLE link_status_cache
+ADD COLUMN reason VARCHAR(32) NOT NULL AFTER status_code,
+ADD COLUMN redirect_url VARCHAR(2083) CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci NULL AFTER reason;
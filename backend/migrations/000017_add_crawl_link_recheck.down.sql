@@ -0,0 +1,6 @@
+ALTER TABLE crawl_links
+DROP KEY idx_links_recheck,
+DROP COLUMN last_checked_at,
+DROP COLUMN consecutive_failures,
+DROP COLUMN recheck_status,
+DROP COLUMN occurrences;
@@ -0,0 +1,7 @@
+-- Inaccessible links are rechecked before they are confirmed broken
+ALTER TABLE crawl_links
+ADD COLUMN occurrences INT UNSIGNED NOT NULL DEFAULT 1 AFTER anchor_text,
+ADD COLUMN recheck_status ENUM('pending', 'confirmed', 'recovered') NOT NULL DEFAULT 'pending' AFTER occurrences,
+ADD COLUMN consecutive_failures INT UNSIGNED NOT NULL DEFAULT 1 AFTER recheck_status,
+ADD COLUMN last_checked_at TIMESTAMP NULL AFTER consecutive_failures,
+ADD KEY idx_links_recheck (crawl_id, recheck_status);
@@ -0,0 +1,4 @@
+UPDATE crawls SET status = 'stopped' WHERE status = 'paused';
+
+ALTER TABLE crawls
+MODIFY COLUMN status ENUM('queued', 'running', 'stopped', 'done', 'error') NOT NULL DEFAULT 'queued';
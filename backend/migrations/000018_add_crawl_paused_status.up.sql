@@ -0,0 +1,3 @@
+-- Crawls can be paused between link check batches and resumed later
+ALTER TABLE crawls
+MODIFY COLUMN status ENUM('queued', 'running', 'paused', 'stopped', 'done', 'error') NOT NULL DEFAULT 'queued';
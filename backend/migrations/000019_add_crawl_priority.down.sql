@@ -0,0 +1,2 @@
+ALTER TABLE crawls
+DROP COLUMN priority;
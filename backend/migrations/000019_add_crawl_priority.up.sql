@@ -0,0 +1,3 @@
+-- Crawls run on the task queue of their priority, the per-user limits are counted per priority
+ALTER TABLE crawls
+ADD COLUMN priority ENUM('interactive', 'scheduled', 'backfill') NOT NULL DEFAULT 'interactive' AFTER status;
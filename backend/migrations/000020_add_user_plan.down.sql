@@ -0,0 +1,5 @@
+ALTER TABLE crawls
+DROP KEY idx_crawls_url_queued_at;
+
+ALTER TABLE users
+DROP COLUMN plan;
@@ -0,0 +1,6 @@
+-- Every user is on a plan that sets their quotas, the daily crawl quota is counted from the queue time
+ALTER TABLE users
+ADD COLUMN plan VARCHAR(32) NOT NULL DEFAULT 'free' AFTER password_hash;
+
+ALTER TABLE crawls
+ADD KEY idx_crawls_url_queued_at (url_id, queued_at);
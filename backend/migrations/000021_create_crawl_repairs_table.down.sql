@@ -0,0 +1,4 @@
+ALTER TABLE crawls
+DROP KEY idx_crawls_status_updated_at;
+
+DROP TABLE IF EXISTS crawl_repairs;
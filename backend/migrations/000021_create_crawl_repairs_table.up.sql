@@ -0,0 +1,19 @@
+-- Crawls whose row was repaired by the reconciler because it no longer matched the state of its workflow
+CREATE TABLE crawl_repairs (
+  id              CHAR(36) PRIMARY KEY DEFAULT (UUID()),
+  crawl_id        CHAR(36) NOT NULL,
+  previous_status VARCHAR(16) NOT NULL,
+  -- The status the crawl was repaired to, the previous status when only its workflow was canceled
+  new_status      VARCHAR(16) NOT NULL,
+  -- NULL when the workflow does not exist
+  workflow_status VARCHAR(32) NULL,
+  reason          VARCHAR(512) NOT NULL,
+  repaired_at     TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+  CONSTRAINT fk_crawl_repairs_crawl FOREIGN KEY (crawl_id) REFERENCES crawls(id) ON DELETE CASCADE,
+  KEY idx_crawl_repairs_crawl (crawl_id),
+  KEY idx_crawl_repairs_repaired_at (repaired_at)
+);
+
+ALTER TABLE crawls
+ADD KEY idx_crawls_status_updated_at (status, updated_at);
@@ -0,0 +1,2 @@
+ALTER TABLE crawl_links
+DROP COLUMN recheck_round;
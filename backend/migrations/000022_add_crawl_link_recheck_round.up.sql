@@ -0,0 +1,3 @@
+-- The round a link failure was last recorded for, a retried recheck round does not count it twice
+ALTER TABLE crawl_links
+ADD COLUMN recheck_round INT UNSIGNED NOT NULL DEFAULT 0 AFTER consecutive_failures;
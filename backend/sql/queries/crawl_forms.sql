@@ -0,0 +1,22 @@
+-- name: CreateCrawlForm :exec
+INSERT INTO crawl_forms (
+    crawl_id, position, form_type, method, action, fields, is_https, is_off_site, is_insecure
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?
+);
+
+-- name: DeleteCrawlFormsByCrawlId :exec
+DELETE FROM crawl_forms
+WHERE crawl_id = ?;
+
+-- name: GetFormsForLatestCrawlByUrlId :many
+SELECT id, crawl_id, position, form_type, method, action, fields, is_https, is_off_site, is_insecure, created_at
+FROM crawl_forms
+WHERE crawl_id = (
+    SELECT c.id
+    FROM crawls c
+    WHERE c.url_id = ? AND c.status = 'done'
+    ORDER BY c.created_at DESC
+    LIMIT 1
+)
+ORDER BY position;
@@ -1,6 +1,42 @@
 -- name: CreateInaccessibleLink :execresult
 INSERT INTO crawl_links (
-    crawl_id, href, absolute_url, is_internal, status_code, anchor_text
+    crawl_id, href, absolute_url, is_internal, status_code, reason, redirect_url, anchor_text, occurrences
 ) VALUES (
-    ?, ?, ?, ?, ?, ?
+    ?, ?, ?, ?, ?, ?, ?, ?, ?
 );
+
+-- name: DeleteCrawlLinksByCrawlId :exec
+DELETE FROM crawl_links
+WHERE crawl_id = ?;
+
+-- name: GetPendingCrawlLinks :many
+SELECT id, absolute_url, is_internal, occurrences, consecutive_failures, recheck_round
+FROM crawl_links
+WHERE crawl_id = ? AND recheck_status = 'pending'
+ORDER BY id;
+
+-- name: UpdateCrawlLinkRecheckFailure :exec
+UPDATE crawl_links
+SET status_code = sqlc.arg(status_code),
+    reason = sqlc.arg(reason),
+    redirect_url = sqlc.arg(redirect_url),
+    recheck_status = IF(consecutive_failures + 1 >= sqlc.arg(confirmations), 'confirmed', 'pending'),
+    consecutive_failures = consecutive_failures + 1,
+    recheck_round = sqlc.arg(round),
+    last_checked_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id) AND recheck_round < sqlc.arg(round);
+
+-- name: UpdateCrawlLinkRecovered :exec
+UPDATE crawl_links
+SET status_code = ?,
+    reason = ?,
+    redirect_url = ?,
+    recheck_status = 'recovered',
+    consecutive_failures = 0,
+    last_checked_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
+-- name: ConfirmPendingCrawlLinks :execrows
+UPDATE crawl_links
+SET recheck_status = 'confirmed'
+WHERE crawl_id = ? AND recheck_status = 'pending';
@@ -0,0 +1,39 @@
+-- name: ListCrawlsForReconciliation :many
+SELECT c.id, c.url_id, u.user_id, c.status, c.workflow_id
+FROM crawls c
+JOIN urls u ON u.id = c.url_id
+WHERE c.id > ?
+  AND c.updated_at < ?
+  AND (c.status IN ('queued', 'running', 'paused') OR (c.status = 'stopped' AND c.updated_at >= ?))
+ORDER BY c.id
+LIMIT ?;
+
+-- name: RepairCrawl :execresult
+UPDATE crawls
+SET status = ?,
+    finished_at = IFNULL(finished_at, CURRENT_TIMESTAMP),
+    updated_at = CURRENT_TIMESTAMP,
+    error_message = ?
+WHERE id = ? AND status = ?;
+
+-- name: TouchStoppedCrawl :execresult
+-- A stopped crawl whose workflow was canceled by the reconciler is not checked again before the grace period
+UPDATE crawls
+SET updated_at = CURRENT_TIMESTAMP
+WHERE id = ? AND status = 'stopped';
+
+-- name: CreateCrawlRepair :exec
+INSERT INTO crawl_repairs (
+    crawl_id, previous_status, new_status, workflow_status, reason
+) VALUES (
+    ?, ?, ?, ?, ?
+);
+
+-- name: ListCrawlRepairsForUser :many
+SELECT r.id, r.crawl_id, c.url_id, u.normalized_url, r.previous_status, r.new_status, r.workflow_status, r.reason, r.repaired_at
+FROM crawl_repairs r
+JOIN crawls c ON c.id = r.crawl_id
+JOIN urls u ON u.id = c.url_id
+WHERE u.user_id = ?
+ORDER BY r.repaired_at DESC
+LIMIT ?;
@@ -1,7 +1,7 @@
 -- name: CountOfActiveCrawlForUrlId :one
 SELECT COUNT(*)
 FROM crawls
-WHERE url_id = ? AND status IN ('queued', 'running');
+WHERE url_id = ? AND status IN ('queued', 'running', 'paused');
 
 -- name: GetCrawlByWorkflowID :one
 SELECT id, url_id, status, queued_at, started_at, finished_at, error_message, workflow_id,
@@ -19,24 +19,38 @@ SELECT id, url_id, status, queued_at, started_at, finished_at, error_message, wo
        internal_links_count, external_links_count, inaccessible_links_count, has_login_form,
        created_at, updated_at
 FROM crawls
-WHERE url_id = ? AND status IN ('queued', 'running')
+WHERE url_id = ? AND status IN ('queued', 'running', 'paused')
 ORDER BY created_at DESC;
 
+-- name: LockUrlForCrawl :one
+SELECT id
+FROM urls
+WHERE id = ?
+FOR UPDATE;
+
 -- name: QueueCrawl :execresult
 INSERT INTO crawls (
-    url_id, status, workflow_id, queued_at
+    id, url_id, status, priority, workflow_id, queued_at
 ) VALUES (
-    ?, 'queued', ?, CURRENT_TIMESTAMP
+    ?, ?, 'queued', ?, ?, CURRENT_TIMESTAMP
 );
 
+-- name: CountActiveCrawlsForUser :one
+SELECT COUNT(*)
+FROM crawls c
+JOIN urls u ON u.id = c.url_id
+WHERE u.user_id = ? AND c.priority = ? AND c.status IN ('queued', 'running', 'paused');
+
 
 -- name: SetCrawlRunning :exec
+-- Stopped crawls and crawls that failed before they started are never brought back, a crawl whose fetch
+-- failed an attempt runs again when it is retried
 UPDATE crawls
 SET status='running',
     started_at = IFNULL(started_at, CURRENT_TIMESTAMP),
     updated_at = CURRENT_TIMESTAMP,
     error_message = NULL
-WHERE id = ?;
+WHERE id = ? AND (status IN ('queued', 'running', 'paused') OR (status = 'error' AND started_at IS NOT NULL));
 
 -- name: SetCrawlDone :exec
 UPDATE crawls
@@ -45,6 +59,12 @@ SET status='done',
     updated_at = CURRENT_TIMESTAMP
 WHERE id = ?;
 
+-- name: SetCrawlPaused :exec
+UPDATE crawls
+SET status='paused',
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
 -- name: SetCrawlStopped :exec
 UPDATE crawls
 SET status='stopped',
@@ -53,12 +73,23 @@ SET status='stopped',
 WHERE id = ?;
 
 -- name: SetCrawlError :exec
+-- A stopped crawl stays stopped when the attempt it interrupted fails
 UPDATE crawls
 SET status='error',
     finished_at = IFNULL(finished_at, CURRENT_TIMESTAMP),
     updated_at = CURRENT_TIMESTAMP,
     error_message = ?
-WHERE id = ?;
+WHERE id = ? AND status <> 'stopped';
+
+-- name: FailActiveCrawls :execrows
+-- Crawls run by the local executor are lost when the process stops, they are failed when it starts again
+UPDATE crawls
+SET status='error',
+    finished_at = IFNULL(finished_at, CURRENT_TIMESTAMP),
+    updated_at = CURRENT_TIMESTAMP,
+    error_message = sqlc.arg(error_message)
+WHERE status IN ('queued', 'running', 'paused')
+  AND LEFT(workflow_id, CHAR_LENGTH(sqlc.arg(prefix))) = sqlc.arg(prefix);
 
 
 -- name: UpdateCrawlResult :exec
@@ -76,8 +107,122 @@ SET
     internal_links_count = ?,
     external_links_count = ?,
     inaccessible_links_count = ?,
+    broken_anchors_count = ?,
     has_login_form = ?,
     error_message = ?,
     finished_at = ?,
     updated_at = CURRENT_TIMESTAMP
-WHERE id = ?;
\ No newline at end of file
+WHERE id = ?;
+
+-- name: UpdateCrawlLinkCounts :exec
+UPDATE crawls
+SET
+    internal_links_count = ?,
+    external_links_count = ?,
+    inaccessible_links_count = ?,
+    broken_anchors_count = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
+-- name: UpdateCrawlFingerprint :exec
+UPDATE crawls
+SET content_hash = ?,
+    simhash = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
+-- name: UpdateCrawlFormSummary :exec
+UPDATE crawls
+SET form_types = ?,
+    flagged_forms_count = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
+-- name: UpdateCrawlOutcome :exec
+UPDATE crawls
+SET outcome = ?,
+    mime_type = ?,
+    charset = ?,
+    content_length = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
+-- name: UpdateCrawlSnapshot :exec
+UPDATE crawls
+SET snapshot_hash = ?,
+    snapshot_size = ?,
+    response_headers = ?,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
+-- name: GetCrawlSnapshotByIdAndUserId :one
+SELECT c.id, c.url_id, c.mime_type, c.charset, c.snapshot_hash, c.snapshot_size, c.response_headers, c.finished_at
+FROM crawls c
+JOIN urls u ON u.id = c.url_id
+WHERE c.id = ? AND u.user_id = ?;
+
+-- name: UpdateCrawlAnalysis :exec
+UPDATE crawls
+SET
+    html_version = ?,
+    page_title = ?,
+    h1_count = ?,
+    h2_count = ?,
+    h3_count = ?,
+    h4_count = ?,
+    h5_count = ?,
+    h6_count = ?,
+    has_login_form = ?,
+    analysis_version = ?,
+    analyzed_at = CURRENT_TIMESTAMP,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
+-- name: SetCrawlAnalysisVersion :exec
+UPDATE crawls
+SET analysis_version = ?,
+    analyzed_at = CURRENT_TIMESTAMP,
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
+-- name: ListCrawlsForReanalysis :many
+SELECT c.id, u.normalized_url, c.snapshot_hash, c.response_headers
+FROM crawls c
+JOIN urls u ON u.id = c.url_id
+WHERE c.status = 'done'
+  AND c.snapshot_hash IS NOT NULL
+  AND c.analysis_version < sqlc.arg(target_version)
+  AND c.id > sqlc.arg(cursor)
+  AND (sqlc.arg(user_filter) = '' OR u.user_id = sqlc.arg(user_filter))
+ORDER BY c.id
+LIMIT ?;
+
+-- name: GetCrawlForReanalysis :one
+SELECT c.id, u.normalized_url, c.snapshot_hash, c.response_headers
+FROM crawls c
+JOIN urls u ON u.id = c.url_id
+WHERE c.id = sqlc.arg(id)
+  AND c.status = 'done'
+  AND c.snapshot_hash IS NOT NULL
+  AND c.analysis_version < sqlc.arg(target_version)
+  AND (sqlc.arg(user_filter) = '' OR u.user_id = sqlc.arg(user_filter));
+
+-- name: UpdateCrawlLinkCacheStats :exec
+UPDATE crawls
+SET link_cache_hits = ?, link_cache_misses = ?
+WHERE id = ?;
+
+-- name: GetLinkCacheStatsByUserId :one
+SELECT CAST(COALESCE(SUM(c.link_cache_hits), 0) AS SIGNED) AS hits,
+       CAST(COALESCE(SUM(c.link_cache_misses), 0) AS SIGNED) AS misses
+FROM crawls c
+JOIN urls u ON u.id = c.url_id
+WHERE u.user_id = ?;
+
+-- name: MoveRecoveredLinkCounts :exec
+UPDATE crawls
+SET inaccessible_links_count = GREATEST(CAST(inaccessible_links_count AS SIGNED) - sqlc.arg(occurrences), 0),
+    internal_links_count = internal_links_count + IF(sqlc.arg(is_internal), sqlc.arg(occurrences), 0),
+    external_links_count = external_links_count + IF(sqlc.arg(is_internal), 0, sqlc.arg(occurrences)),
+    updated_at = CURRENT_TIMESTAMP
+WHERE id = sqlc.arg(id);
@@ -0,0 +1,28 @@
+-- name: EnsureHostRateLimit :exec
+INSERT IGNORE INTO host_rate_limits (host) VALUES (?);
+
+-- name: GetHostRateLimitForUpdate :one
+SELECT host, next_slot_ms, blocked_until_ms, crawl_delay_ms, crawl_delay_expires_ms, updated_at
+FROM host_rate_limits
+WHERE host = ?
+FOR UPDATE;
+
+-- name: UpdateHostNextSlot :exec
+UPDATE host_rate_limits
+SET next_slot_ms = ?
+WHERE host = ?;
+
+-- name: ExtendHostBlockedUntil :exec
+UPDATE host_rate_limits
+SET blocked_until_ms = GREATEST(blocked_until_ms, sqlc.arg(blocked_until_ms))
+WHERE host = sqlc.arg(host);
+
+-- name: GetHostCrawlDelay :one
+SELECT crawl_delay_ms, crawl_delay_expires_ms
+FROM host_rate_limits
+WHERE host = ?;
+
+-- name: UpdateHostCrawlDelay :exec
+UPDATE host_rate_limits
+SET crawl_delay_ms = ?, crawl_delay_expires_ms = ?
+WHERE host = ?;
@@ -0,0 +1,23 @@
+-- name: GetCachedLinkStatus :one
+SELECT status_code, reason, redirect_url
+FROM link_status_cache
+WHERE url_hash = UNHEX(MD5(sqlc.arg(absolute_url))) AND expires_at > NOW();
+
+-- name: UpsertCachedLinkStatus :exec
+INSERT INTO link_status_cache (
+    url_hash, absolute_url, status_code, reason, redirect_url, checked_at, expires_at
+) VALUES (
+    UNHEX(MD5(sqlc.arg(absolute_url))), sqlc.arg(absolute_url), sqlc.arg(status_code), sqlc.arg(reason), sqlc.arg(redirect_url), NOW(), NOW() + INTERVAL sqlc.arg(ttl_seconds) SECOND
+)
+ON DUPLICATE KEY UPDATE
+    absolute_url = VALUES(absolute_url),
+    status_code = VALUES(status_code),
+    reason = VALUES(reason),
+    redirect_url = VALUES(redirect_url),
+    checked_at = VALUES(checked_at),
+    expires_at = VALUES(expires_at);
+
+-- name: DeleteExpiredLinkStatuses :execrows
+DELETE FROM link_status_cache
+WHERE expires_at < NOW()
+LIMIT ?;
@@ -0,0 +1,30 @@
+-- name: LockUserQuota :one
+-- Serializes the actions of a user that count towards their quotas until the transaction ends
+SELECT id
+FROM users
+WHERE id = ?
+FOR UPDATE;
+
+-- name: GetUserPlan :one
+SELECT plan
+FROM users
+WHERE id = ? LIMIT 1;
+
+-- name: CountUrlsForUser :one
+SELECT COUNT(*)
+FROM urls
+WHERE user_id = ?;
+
+-- name: CountCrawlsForUserSince :one
+-- Crawls that failed or were stopped before they started do not count
+SELECT COUNT(*)
+FROM crawls c
+JOIN urls u ON u.id = c.url_id
+WHERE u.user_id = ? AND c.queued_at >= ? AND (c.started_at IS NOT NULL OR c.status = 'queued');
+
+-- name: GetLatestScheduledCrawlQueuedAt :one
+SELECT c.queued_at
+FROM crawls c
+WHERE c.url_id = ? AND c.priority IN ('scheduled', 'backfill') AND (c.started_at IS NOT NULL OR c.status = 'queued')
+ORDER BY c.queued_at DESC
+LIMIT 1;
@@ -0,0 +1,27 @@
+-- name: GetRequestProfileByUrlId :one
+SELECT url_id, user_agent, basic_auth_username, encrypted_secrets, apply_to_internal_links,
+       login_url, login_username_field, login_password_field, login_username, created_at, updated_at
+FROM url_request_profiles
+WHERE url_id = ?;
+
+-- name: UpsertRequestProfile :exec
+INSERT INTO url_request_profiles (
+    url_id, user_agent, basic_auth_username, encrypted_secrets, apply_to_internal_links,
+    login_url, login_username_field, login_password_field, login_username
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?
+)
+ON DUPLICATE KEY UPDATE
+    user_agent = VALUES(user_agent),
+    basic_auth_username = VALUES(basic_auth_username),
+    encrypted_secrets = VALUES(encrypted_secrets),
+    apply_to_internal_links = VALUES(apply_to_internal_links),
+    login_url = VALUES(login_url),
+    login_username_field = VALUES(login_username_field),
+    login_password_field = VALUES(login_password_field),
+    login_username = VALUES(login_username),
+    updated_at = CURRENT_TIMESTAMP;
+
+-- name: DeleteRequestProfileByUrlId :exec
+DELETE FROM url_request_profiles
+WHERE url_id = ?;
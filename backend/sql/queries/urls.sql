@@ -32,6 +32,9 @@ SELECT
     u.created_at as url_created_at,
     c.id as crawl_id,
     c.status,
+    c.outcome,
+    c.mime_type,
+    c.content_length,
     c.workflow_id,
     c.queued_at,
     c.started_at,
@@ -47,7 +50,10 @@ SELECT
     c.internal_links_count,
     c.external_links_count,
     c.inaccessible_links_count,
+    c.broken_anchors_count,
     c.has_login_form,
+    c.form_types,
+    c.flagged_forms_count,
     c.error_message,
     c.created_at as crawl_created_at,
     c.updated_at as crawl_updated_at
@@ -97,8 +103,12 @@ ORDER BY
   CASE WHEN sqlc.arg(sort_by)='external_links_count'     AND sqlc.arg(sort_dir)='desc' THEN c.external_links_count END DESC,
   CASE WHEN sqlc.arg(sort_by)='inaccessible_links_count' AND sqlc.arg(sort_dir)='asc'  THEN c.inaccessible_links_count END ASC,
   CASE WHEN sqlc.arg(sort_by)='inaccessible_links_count' AND sqlc.arg(sort_dir)='desc' THEN c.inaccessible_links_count END DESC,
+  CASE WHEN sqlc.arg(sort_by)='broken_anchors_count'     AND sqlc.arg(sort_dir)='asc'  THEN c.broken_anchors_count END ASC,
+  CASE WHEN sqlc.arg(sort_by)='broken_anchors_count'     AND sqlc.arg(sort_dir)='desc' THEN c.broken_anchors_count END DESC,
   CASE WHEN sqlc.arg(sort_by)='has_login_form'           AND sqlc.arg(sort_dir)='asc'  THEN c.has_login_form END ASC,
   CASE WHEN sqlc.arg(sort_by)='has_login_form'           AND sqlc.arg(sort_dir)='desc' THEN c.has_login_form END DESC,
+  CASE WHEN sqlc.arg(sort_by)='flagged_forms_count'      AND sqlc.arg(sort_dir)='asc'  THEN c.flagged_forms_count END ASC,
+  CASE WHEN sqlc.arg(sort_by)='flagged_forms_count'      AND sqlc.arg(sort_dir)='desc' THEN c.flagged_forms_count END DESC,
   -- Default fallback sort when no conditions match
   u.created_at DESC
 
@@ -115,4 +125,25 @@ INSERT INTO urls (
 
 -- name: DeleteURLByIdAndUserId :exec
 DELETE FROM urls
-WHERE id = ? AND user_id = ?;
\ No newline at end of file
+WHERE id = ? AND user_id = ?;
+
+-- name: GetLatestCrawlFingerprintsByUser :many
+SELECT 
+    u.id as url_id,
+    u.normalized_url,
+    c.id as crawl_id,
+    c.page_title,
+    c.content_hash,
+    c.simhash
+FROM urls u
+JOIN crawls c 
+  ON u.id = c.url_id 
+ AND c.id = (
+    SELECT c2.id 
+    FROM crawls c2 
+    WHERE c2.url_id = u.id AND c2.status = 'done'
+    ORDER BY c2.created_at DESC 
+    LIMIT 1
+)
+WHERE u.user_id = ?
+ORDER BY u.created_at DESC;
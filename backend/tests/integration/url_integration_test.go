@@ -93,7 +93,7 @@ userService := user.NewUserService(userRepo, cfg)
 userHandler := user.NewUserHandler(userService)
 
 urlRepo := url.NewRepo(database)
-urlService := url.NewService(urlRepo, cfg)
+urlService := url.NewService(urlRepo, cfg, nil)
 urlHandler := url.NewHandler(urlService)
 
 // Initialize Temporal (optional for this test)
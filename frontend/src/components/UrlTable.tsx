@@ -1,5 +1,5 @@
 import React from 'react';
-import type { CrawlResult, SortColumn } from '../types/dashboard';
+import type { CrawlProgress, CrawlResult, SortColumn } from '../types/dashboard';
 
 interface SortInfo {
   column?: SortColumn;
@@ -8,6 +8,7 @@ interface SortInfo {
 
 interface UrlTableProps {
   data: CrawlResult[];
+  progress?: Record<string, CrawlProgress>;
   sortableColumns: Array<{ key: SortColumn; label: string }>;
   currentSort: SortInfo;
   onSort: (column: SortColumn) => void;
@@ -26,6 +27,7 @@ interface UrlTableProps {
 
 export const UrlTable: React.FC<UrlTableProps> = ({
   data,
+  progress = {},
   sortableColumns,
   currentSort,
   onSort,  
@@ -51,6 +53,8 @@ export const UrlTable: React.FC<UrlTableProps> = ({
         return 'bg-blue-100 text-blue-800';
       case 'queued':
         return 'bg-yellow-100 text-yellow-800';
+      case 'paused':
+        return 'bg-orange-100 text-orange-800';
       case 'failed':
         return 'bg-red-100 text-red-800';
       case 'cancelled':
@@ -201,6 +205,9 @@ export const UrlTable: React.FC<UrlTableProps> = ({
                 <td className="px-6 py-4 whitespace-nowrap">
                   <span className={`px-2 py-1 text-xs font-medium rounded-full ${getStatusColor(item.status)}`}>
                     {item.status || 'Not crawled'}
+                    {item.status === 'running' && progress[item.url_id] && progress[item.url_id].crawl_id === item.crawl_id && (
+                      <> · {progress[item.url_id].percent}%</>
+                    )}
                   </span>
                 </td>
                 <td className="px-6 py-4 whitespace-nowrap text-sm text-gray-900">
@@ -1,10 +1,13 @@
-import { useEffect, useRef } from 'react';
+import { useEffect, useRef, useState } from 'react';
 import { useQueryClient } from '@tanstack/react-query';
+import type { CrawlProgress } from '../types/dashboard';
 
 interface SSENotification {
   type: string;
   url_id?: string;
   user_id?: string;
+  status?: string;
+  progress?: CrawlProgress;
   timestamp: string;
 }
 
@@ -13,6 +16,8 @@ const API_BASE_URL = import.meta.env.VITE_API_BASE_URL || 'http://localhost:7070
 export const useCrawlUpdates = (token: string | null) => {
   const queryClient = useQueryClient();
   const eventSourceRef = useRef<EventSource | null>(null);
+  // Latest progress of the running crawls, keyed by URL ID
+  const [progress, setProgress] = useState<Record<string, CrawlProgress>>({});
 
   useEffect(() => {
     if (!token) return;
@@ -58,6 +63,13 @@ export const useCrawlUpdates = (token: string | null) => {
               });
             }
             break;
+          case 'crawl_progress':
+            if (notification.url_id && notification.progress) {
+              const urlId = notification.url_id;
+              const update = notification.progress;
+              setProgress((current) => ({ ...current, [urlId]: update }));
+            }
+            break;
           case 'ping':
             // Keep-alive ping, no action needed
             console.log('Received ping from server');
@@ -95,5 +107,6 @@ export const useCrawlUpdates = (token: string | null) => {
 
   return {
     isConnected: eventSourceRef.current?.readyState === EventSource.OPEN,
+    progress,
   };
 };
\ No newline at end of file
@@ -23,7 +23,7 @@ export const Dashboard: React.FC = () => {
   const [selectedUrls, setSelectedUrls] = useState<string[]>([]);
 
   // Initialize SSE connection for real-time crawl updates
-  const { isConnected } = useCrawlUpdates(token);
+  const { isConnected, progress } = useCrawlUpdates(token);
 
   const { data, isLoading, error, refetch } = useDashboardData(filters);
   const createUrlMutation = useCreateUrl();
@@ -219,6 +219,7 @@ export const Dashboard: React.FC = () => {
         <>
           <UrlTable
             data={data?.urls || []}
+            progress={progress}
             sortableColumns={sortableColumns}
             currentSort={{ column: filters.sort_by, order: filters.sort_order }}
             onSort={handleSort}
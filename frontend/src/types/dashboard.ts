@@ -4,10 +4,13 @@ export interface CrawlResult {
   domain: string;
   url_created_at: string | null;
   crawl_id?: string | null;
-  status?: 'queued' | 'running' | 'completed' | 'failed' | 'cancelled' | null;
+  status?: 'queued' | 'running' | 'paused' | 'completed' | 'failed' | 'cancelled' | null;
   queued_at?: string | null;
   started_at?: string | null;
   finished_at?: string | null;
+  outcome?: string | null;
+  mime_type?: string | null;
+  content_length?: number | null;
   html_version?: string | null;
   page_title?: string | null;
   h1_count?: number | null;
@@ -19,7 +22,10 @@ export interface CrawlResult {
   internal_links_count?: number | null;
   external_links_count?: number | null;
   inaccessible_links_count?: number | null;
+  broken_anchors_count?: number | null;
   has_login_form?: boolean | null;
+  form_types?: string[] | null;
+  flagged_forms_count?: number | null;
   error_message?: string | null;
   crawl_created_at?: string | null;
   crawl_updated_at?: string | null;
@@ -77,4 +83,12 @@ export interface BatchCrawlResponse {
   success_count: number;
   failed_count: number;
   message: string;
-}
\ No newline at end of file
+}
+
+export interface CrawlProgress {
+  crawl_id: string;
+  phase: 'queued' | 'fetching' | 'checking_links' | 'paused' | 'finalizing' | 'done';
+  links_checked: number;
+  links_total: number;
+  percent: number;
+}